@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/intel/goresctrl/pkg/rdt"
@@ -71,6 +72,7 @@ func subCmdInfo(args []string) error {
 	// Parse command line args
 	flags := flag.NewFlagSet("info", flag.ExitOnError)
 	addGlobalFlags(flags)
+	tasks := flags.Bool("tasks", false, "list the tasks of the classes and monitoring groups")
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
@@ -93,12 +95,18 @@ func subCmdInfo(args []string) error {
 	fmt.Println("Classes:")
 	for _, cls := range rdt.GetClasses() {
 		fmt.Printf("  - %s\n", cls.Name())
+		if *tasks {
+			printTasks(cls, "    ")
+		}
 
 		mon := cls.GetMonGroups()
 		if len(mon) > 0 {
 			fmt.Println("    Monitoring groups:")
 			for _, grp := range mon {
 				fmt.Printf("      - %s\n", grp.Name())
+				if *tasks {
+					printTasks(grp, "        ")
+				}
 			}
 		}
 	}
@@ -106,13 +114,43 @@ func subCmdInfo(args []string) error {
 	return nil
 }
 
+func printTasks(grp rdt.ResctrlGroup, indent string) {
+	ext, ok := grp.(rdt.ResctrlGroupExt)
+	if !ok {
+		fmt.Printf("%sTasks: not available\n", indent)
+		return
+	}
+	pids, err := ext.GetPidsWithComm()
+	if err != nil {
+		fmt.Printf("%sTasks: %v\n", indent, err)
+		return
+	}
+	if len(pids) == 0 {
+		return
+	}
+
+	ids := make([]int, 0, len(pids))
+	for pid := range pids {
+		id, _ := strconv.Atoi(pid)
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	fmt.Printf("%sTasks:\n", indent)
+	for _, id := range ids {
+		pid := strconv.Itoa(id)
+		fmt.Printf("%s  - %s (%s)\n", indent, pid, pids[pid])
+	}
+}
+
 func subCmdConfigure(args []string) error {
 	// Parse command line args
 	flags := flag.NewFlagSet("configure", flag.ExitOnError)
 	addGlobalFlags(flags)
 
-	configFile := flags.String("config-file", "", "path to rdt configuration file")
+	configFile := flags.String("config-file", "", "path to rdt configuration file, multiple comma-separated files are merged in order")
 	force := flags.Bool("force", false, "force configuration, delete non-empty resctrl groups")
+	verbose := flags.Bool("v", false, "print the resulting allocations")
 
 	if err := flags.Parse(args); err != nil {
 		return err
@@ -128,10 +166,14 @@ func subCmdConfigure(args []string) error {
 	}
 
 	fmt.Println("Configuring resctrl filesystem...")
-	if err := rdt.SetConfigFromFile(*configFile, *force); err != nil {
+	if err := rdt.SetConfigFromFiles(strings.Split(*configFile, ","), *force); err != nil {
 		return err
 	}
 
+	if *verbose {
+		fmt.Print(rdt.FormatAllocationTable())
+	}
+
 	fmt.Println("Done!")
 
 	return nil
@@ -23,6 +23,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	goresctrlpath "github.com/intel/goresctrl/pkg/path"
 	"github.com/intel/goresctrl/pkg/sst"
@@ -37,9 +38,15 @@ var (
 type subCmd func([]string) error
 
 var subCmds = map[string]subCmd{
-	"info": subCmdInfo,
-	"bf":   subCmdBF,
-	"cp":   subCmdCP,
+	"info":      subCmdInfo,
+	"pp":        subCmdPP,
+	"bf":        subCmdBF,
+	"cp":        subCmdCP,
+	"tf":        subCmdTF,
+	"profile":   subCmdProfile,
+	"uncore":    subCmdUncore,
+	"telemetry": subCmdTelemetry,
+	"topology":  subCmdTopology,
 }
 
 func main() {
@@ -108,14 +115,40 @@ func str2slice(str string) []int {
 	return s
 }
 
+func printFeatures(pkgId ...int) error {
+	infomap, err := sst.GetPackageInfo(pkgId...)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(infomap))
+	for id := range infomap {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		fmt.Printf("Package %d: %s\n", id, infomap[id].Features())
+	}
+
+	return nil
+}
+
 func subCmdInfo(args []string) error {
+	var features bool
+
 	// Parse command line args
 	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	flags.BoolVar(&features, "features", false, "print a summary of the SST features of each package")
 	addGlobalFlags(flags)
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
 
+	if features {
+		return printFeatures(str2slice(packageIds)...)
+	}
+
 	return printPackageInfo(str2slice(packageIds)...)
 }
 
@@ -149,18 +182,49 @@ func disableBF(pkgId ...int) error {
 	return printPackageInfo(pkgId...)
 }
 
+func previewBF(pkgId ...int) error {
+	infomap, err := sst.GetPackageInfo(pkgId...)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(infomap))
+	for id := range infomap {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		info := infomap[id]
+		if !info.BFSupported {
+			fmt.Printf("Package %d: BF not supported\n", id)
+			continue
+		}
+		fmt.Printf("Package %d: high priority cores %s (BF enabled: %v)\n", id, info.BFCores, info.BFEnabled)
+	}
+
+	return nil
+}
+
 func subCmdBF(args []string) error {
-	var enable, disable bool
+	var enable, disable, preview bool
 
 	flags := flag.NewFlagSet("bf", flag.ExitOnError)
 	flags.BoolVar(&enable, "enable", false, "enable feature")
 	flags.BoolVar(&disable, "disable", false, "disable feature")
+	flags.BoolVar(&preview, "preview", false, "show the high priority cores without changing anything")
 	addGlobalFlags(flags)
 
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
 
+	pkgs := str2slice(packageIds)
+
+	if preview {
+		return previewBF(pkgs...)
+	}
+
 	if (!enable && !disable) || (enable && disable) {
 		fmt.Printf("Please provide either -enable or -disable flag\n")
 		return nil
@@ -168,8 +232,6 @@ func subCmdBF(args []string) error {
 
 	var err error
 
-	pkgs := str2slice(packageIds)
-
 	if enable {
 		err = enableBF(pkgs...)
 	} else {
@@ -179,6 +241,66 @@ func subCmdBF(args []string) error {
 	return err
 }
 
+func enableTF(pkgId ...int) error {
+	if len(pkgId) == 0 {
+		fmt.Printf("Enabling TF for all packages\n")
+	} else {
+		fmt.Printf("Enabling TF for package(s) %v\n", pkgId)
+	}
+
+	err := sst.EnableTF(pkgId...)
+	if err != nil {
+		return err
+	}
+
+	return printPackageInfo(pkgId...)
+}
+
+func disableTF(pkgId ...int) error {
+	if len(pkgId) == 0 {
+		fmt.Printf("Disabling TF for all packages\n")
+	} else {
+		fmt.Printf("Disabling TF for package(s) %v\n", pkgId)
+	}
+
+	err := sst.DisableTF(pkgId...)
+	if err != nil {
+		return err
+	}
+
+	return printPackageInfo(pkgId...)
+}
+
+func subCmdTF(args []string) error {
+	var enable, disable bool
+
+	flags := flag.NewFlagSet("tf", flag.ExitOnError)
+	flags.BoolVar(&enable, "enable", false, "enable feature")
+	flags.BoolVar(&disable, "disable", false, "disable feature")
+	addGlobalFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if (!enable && !disable) || (enable && disable) {
+		fmt.Printf("Please provide either -enable or -disable flag\n")
+		return nil
+	}
+
+	pkgs := str2slice(packageIds)
+
+	var err error
+
+	if enable {
+		err = enableTF(pkgs...)
+	} else {
+		err = disableTF(pkgs...)
+	}
+
+	return err
+}
+
 func getPackage(packageStr string, cpus utils.IDSet) (map[int]*sst.SstPackageInfo, *sst.SstPackageInfo, []int, error) {
 	var infomap map[int]*sst.SstPackageInfo
 	var info *sst.SstPackageInfo
@@ -317,7 +439,7 @@ func subCmdCP(args []string) error {
 		cpu2Clos := make(sst.ClosCPUSet, 1)
 		cpu2Clos[clos] = cpus
 
-		if err := sst.ConfigureCP(info, priority, &cpu2Clos); err != nil {
+		if err := sst.ConfigureCPPriority(info, sst.CPPriorityType(priority), &cpu2Clos); err != nil {
 			return err
 		}
 
@@ -382,3 +504,212 @@ func subCmdCP(args []string) error {
 
 	return nil
 }
+
+func subCmdPP(args []string) error {
+	var level int
+
+	flags := flag.NewFlagSet("pp", flag.ExitOnError)
+	flags.IntVar(&level, "level", -1, "SST-PP level to switch to")
+	addGlobalFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if level < 0 {
+		fmt.Printf("Please provide the -level flag\n")
+		return nil
+	}
+
+	pkgs := str2slice(packageIds)
+	if len(pkgs) == 0 {
+		infomap, err := sst.GetPackageInfo()
+		if err != nil {
+			return err
+		}
+		for id := range infomap {
+			pkgs = append(pkgs, id)
+		}
+		sort.Ints(pkgs)
+	}
+
+	for _, id := range pkgs {
+		fmt.Printf("Setting PP level %d on package %d\n", level, id)
+
+		if err := sst.SetPPLevel(id, level); err != nil {
+			return err
+		}
+	}
+
+	return printPackageInfo(pkgs...)
+}
+
+func subCmdProfile(args []string) error {
+	var name string
+
+	flags := flag.NewFlagSet("profile", flag.ExitOnError)
+	addGlobalFlags(flags)
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s profile [options] <name>\n\n", os.Args[0])
+		flags.PrintDefaults()
+
+		fmt.Fprintf(os.Stderr, "\nAvailable profiles:\n")
+		for _, p := range sst.GetProfiles() {
+			fmt.Fprintf(os.Stderr, "  %-12s %s\n", p.Name, p.Description)
+		}
+	}
+
+	// Accept the profile name both before and after the options
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = flags.Arg(0)
+	}
+	if name == "" {
+		flags.Usage()
+		return fmt.Errorf("profile name not specified")
+	}
+
+	pkgs := str2slice(packageIds)
+	if len(pkgs) == 0 {
+		fmt.Printf("Applying profile %q on all packages\n", name)
+
+		if err := sst.ApplyProfileAll(name); err != nil {
+			return err
+		}
+		return printPackageInfo()
+	}
+
+	for _, id := range pkgs {
+		fmt.Printf("Applying profile %q on package %d\n", name, id)
+
+		if err := sst.ApplyProfile(id, name); err != nil {
+			return err
+		}
+	}
+
+	return printPackageInfo(pkgs...)
+}
+
+func subCmdUncore(args []string) error {
+	var minFreq, maxFreq int
+
+	flags := flag.NewFlagSet("uncore", flag.ExitOnError)
+	flags.IntVar(&minFreq, "min", 0, "Uncore minimum frequency kHz")
+	flags.IntVar(&maxFreq, "max", 0, "Uncore maximum frequency kHz")
+	addGlobalFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	pkgs := str2slice(packageIds)
+	if len(pkgs) == 0 {
+		return fmt.Errorf("No packages set, use -package option")
+	}
+
+	if minFreq != 0 || maxFreq != 0 {
+		if minFreq == 0 || maxFreq == 0 {
+			return fmt.Errorf("Please provide both -min and -max flags")
+		}
+		for _, pkg := range pkgs {
+			fmt.Printf("Setting uncore frequency of package %d to %d-%d kHz\n", pkg, minFreq, maxFreq)
+			if err := sst.SetUncoreFreq(pkg, minFreq, maxFreq); err != nil {
+				return err
+			}
+		}
+	}
+
+	info := make(map[int][]sst.UncoreFreq, len(pkgs))
+	for _, pkg := range pkgs {
+		freqs, err := sst.GetUncoreFreq(pkg)
+		if err != nil {
+			return err
+		}
+		info[pkg] = freqs
+	}
+	fmt.Println(utils.DumpJSON(info))
+
+	return nil
+}
+
+func subCmdTelemetry(args []string) error {
+	var interval time.Duration
+
+	flags := flag.NewFlagSet("telemetry", flag.ExitOnError)
+	flags.DurationVar(&interval, "interval", time.Second, "Sampling interval for calculating package power")
+	addGlobalFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	pkgs := str2slice(packageIds)
+	if len(pkgs) == 0 {
+		return fmt.Errorf("No packages set, use -package option")
+	}
+
+	type telemetry struct {
+		sst.PackageTelemetry
+		Power float64 `json:",omitempty"`
+	}
+
+	prev := make(map[int]sst.PackageTelemetry, len(pkgs))
+	for _, pkg := range pkgs {
+		t, err := sst.GetPackageTelemetry(pkg)
+		if err != nil {
+			return err
+		}
+		prev[pkg] = t
+	}
+
+	time.Sleep(interval)
+
+	info := make(map[int]telemetry, len(pkgs))
+	for _, pkg := range pkgs {
+		t, err := sst.GetPackageTelemetry(pkg)
+		if err != nil {
+			return err
+		}
+		info[pkg] = telemetry{PackageTelemetry: t}
+		if power, err := t.PowerSince(prev[pkg]); err == nil {
+			info[pkg] = telemetry{PackageTelemetry: t, Power: power}
+		}
+	}
+	fmt.Println(utils.DumpJSON(info))
+
+	return nil
+}
+
+func subCmdTopology(args []string) error {
+	flags := flag.NewFlagSet("topology", flag.ExitOnError)
+	addGlobalFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	pkgs := str2slice(packageIds)
+	if len(pkgs) == 0 {
+		return fmt.Errorf("No packages set, use -package option")
+	}
+
+	info := make(map[int][]sst.CPUMapping, len(pkgs))
+	for _, pkg := range pkgs {
+		mapping, err := sst.GetCPUTopologyMapping(pkg)
+		if err != nil {
+			return err
+		}
+		info[pkg] = mapping
+	}
+	fmt.Println(utils.DumpJSON(info))
+
+	return nil
+}
@@ -45,7 +45,8 @@ limitations under the License.
 //	    # I/O scheduler weight for all devices
 //	    # that are not explicitly mentioned in following items.
 //	    # This will be written to cgroups(.bfq).weight.
-//	    # Weights range from 10 to 1000, the default is 100.
+//	    # Weights range from 1 to 1000 with bfq and from 10 to
+//	    # 1000 with cfq, the default is 100.
 //
 //	    - Weight: 80
 //
@@ -59,6 +60,9 @@ limitations under the License.
 //	      ThrottleWriteBps: 10M  # max write bytes per second
 //	      ThrottleReadIOPS: 10k  # max read io operations per second
 //	      ThrottleWriteIOPS: 5k  # max write io operations per second
+//	                             # fractions are rounded to the nearest
+//	                             # integer (e.g. 1.5k is 1500), 0 removes
+//	                             # the limit and negative values are invalid
 //	      Weight: 50             # I/O scheduler (cfq/bfq) weight for
 //	                             # these devices will be written to
 //	                             # cgroups(.bfq).weight_device
@@ -104,9 +108,11 @@ import (
 	"errors"
 	"fmt"
 	stdlog "log"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -123,6 +129,28 @@ const (
 	// sysfsBlockDeviceIOSchedulerPaths expands (with glob) to block device scheduler files.
 	// If modified, check how to parse device node from expanded paths.
 	sysfsBlockDeviceIOSchedulerPaths = "/sys/block/*/queue/scheduler"
+	// sysfsBlockDeviceRotationalPaths expands (with glob) to block device rotational flag files.
+	sysfsBlockDeviceRotationalPaths = "/sys/block/*/queue/rotational"
+)
+
+// weightRange is the range of valid proportional I/O weights.
+type weightRange struct {
+	min, max int64
+}
+
+var (
+	// ioSchedulerWeightRanges contains the valid weight ranges of the
+	// I/O schedulers that support proportional weights.
+	ioSchedulerWeightRanges = map[string]weightRange{
+		"bfq": {min: 1, max: 1000},
+		"cfq": {min: 10, max: 1000},
+	}
+	// defaultWeightRange is used when the I/O scheduler of a device is not
+	// known. It is valid for all supported I/O schedulers.
+	defaultWeightRange = weightRange{min: 10, max: 1000}
+	// anyWeightRange contains the weights that are valid for at least one
+	// supported I/O scheduler.
+	anyWeightRange = weightRange{min: 1, max: 1000}
 )
 
 // tBlockDeviceInfo holds information on a block device to be configured.
@@ -144,6 +172,14 @@ var log grclog.Logger = grclog.NewLoggerWrapper(stdlog.New(os.Stderr, "[ blockio
 // corresponding cgroups blockio controller parameters.
 var classBlockIO = map[string]BlockIOParameters{}
 
+// appliedClasses records the classes that have been applied, i.e. converted
+// to OCI parameters with OciLinuxBlockIO().
+var appliedClasses = map[string]struct{}{}
+
+// disabledClasses records the classes whose parameters have been
+// temporarily disabled with SetClassEnabled().
+var disabledClasses = map[string]struct{}{}
+
 // SetLogger sets the logger instance to be used by the package.
 // Examples:
 //
@@ -218,6 +254,103 @@ func GetClasses() []string {
 	return classNames
 }
 
+// GetClassesWithParameters returns the resolved parameters of all block I/O
+// classes. The returned parameters are copies that can be modified freely.
+func GetClassesWithParameters() map[string]BlockIOParameters {
+	classes := make(map[string]BlockIOParameters, len(classBlockIO))
+	for name, params := range classBlockIO {
+		classes[name] = params.clone()
+	}
+	return classes
+}
+
+// GetUnusedClasses returns the names of block I/O classes that have never
+// been applied with OciLinuxBlockIO(). Applied classes are remembered over
+// configuration changes as they may still be in use by existing containers.
+func GetUnusedClasses() []string {
+	classNames := []string{}
+	for name := range classBlockIO {
+		if _, ok := appliedClasses[name]; !ok {
+			classNames = append(classNames, name)
+		}
+	}
+	sort.Strings(classNames)
+	return classNames
+}
+
+// RemoveClass removes a block I/O class from the current configuration and
+// forgets whether it has been applied or disabled.
+func RemoveClass(name string) {
+	delete(classBlockIO, name)
+	delete(appliedClasses, name)
+	delete(disabledClasses, name)
+}
+
+// SetClassEnabled enables or disables the parameters of a block I/O class
+// without removing the class. OciLinuxBlockIO() of a disabled class returns
+// parameters that reset the configured limits of the class instead of
+// setting them. The enabled state is remembered over configuration changes.
+func SetClassEnabled(name string, enabled bool) error {
+	if _, ok := classBlockIO[name]; !ok {
+		return fmt.Errorf("no block I/O class %q", name)
+	}
+	if enabled {
+		delete(disabledClasses, name)
+	} else {
+		disabledClasses[name] = struct{}{}
+	}
+	return nil
+}
+
+// GetWeightRange returns the range of valid I/O weights of a block device,
+// e.g. "/dev/sda", based on its currently active I/O scheduler. An error is
+// returned if the I/O scheduler of the device cannot be detected or if it
+// does not support proportional weights.
+func GetWeightRange(device string) (min, max int64, err error) {
+	currentIOSchedulers, err := getCurrentIOSchedulers()
+	if err != nil {
+		return 0, 0, err
+	}
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	ios, ok := currentIOSchedulers[device]
+	if !ok {
+		return 0, 0, fmt.Errorf("I/O scheduler of block device %#v not found", device)
+	}
+	r, ok := ioSchedulerWeightRanges[ios]
+	if !ok {
+		return 0, 0, fmt.Errorf("I/O scheduler %#v of block device %#v does not support weights", ios, device)
+	}
+	return r.min, r.max, nil
+}
+
+// commonWeightRange returns the range of weights that is valid for all block
+// devices whose I/O scheduler supports proportional weights.
+func commonWeightRange(currentIOSchedulers map[string]string) weightRange {
+	var common *weightRange
+	for _, ios := range currentIOSchedulers {
+		r, ok := ioSchedulerWeightRanges[ios]
+		if !ok {
+			continue
+		}
+		if common == nil {
+			common = &weightRange{min: r.min, max: r.max}
+			continue
+		}
+		if r.min > common.min {
+			common.min = r.min
+		}
+		if r.max < common.max {
+			common.max = r.max
+		}
+	}
+	if common == nil {
+		return defaultWeightRange
+	}
+	return *common
+}
+
 // getCurrentIOSchedulers returns currently active I/O scheduler used for each block device in the system.
 // Returns schedulers in a map: {"/dev/sda": "bfq"}
 func getCurrentIOSchedulers() (map[string]string, error) {
@@ -263,17 +396,25 @@ func devicesParametersToCgBlockIO(dps []DevicesParameters, currentIOSchedulers m
 	for _, dp := range dps {
 		var err error
 		var weight, throttleReadBps, throttleWriteBps, throttleReadIOPS, throttleWriteIOPS int64
-		weight, err = parseAndValidateQuantity("Weight", dp.Weight, -1, 10, 1000)
+		// Default weight applies to all devices, device specific weights
+		// are checked against the I/O scheduler of each device below
+		wr := anyWeightRange
+		if dp.Devices == nil && dp.Rotational == nil {
+			wr = commonWeightRange(currentIOSchedulers)
+		}
+		weight, err = parseAndValidateQuantity("Weight", dp.Weight, -1, wr.min, wr.max)
+		errs = append(errs, err)
+		maxBps, err := parseAndValidateQuantity("MaxBps", dp.MaxBps, -1, 1, -1)
 		errs = append(errs, err)
-		throttleReadBps, err = parseAndValidateQuantity("ThrottleReadBps", dp.ThrottleReadBps, -1, 0, -1)
+		throttleReadBps, err = parseAndValidateBps("ThrottleReadBps", dp.ThrottleReadBps, maxBps)
 		errs = append(errs, err)
-		throttleWriteBps, err = parseAndValidateQuantity("ThrottleWriteBps", dp.ThrottleWriteBps, -1, 0, -1)
+		throttleWriteBps, err = parseAndValidateBps("ThrottleWriteBps", dp.ThrottleWriteBps, maxBps)
 		errs = append(errs, err)
 		throttleReadIOPS, err = parseAndValidateQuantity("ThrottleReadIOPS", dp.ThrottleReadIOPS, -1, 0, -1)
 		errs = append(errs, err)
 		throttleWriteIOPS, err = parseAndValidateQuantity("ThrottleWriteIOPS", dp.ThrottleWriteIOPS, -1, 0, -1)
 		errs = append(errs, err)
-		if dp.Devices == nil {
+		if dp.Devices == nil && dp.Rotational == nil {
 			if weight > -1 {
 				blkio.Weight = weight
 			}
@@ -282,24 +423,38 @@ func devicesParametersToCgBlockIO(dps []DevicesParameters, currentIOSchedulers m
 					dp.ThrottleReadBps, dp.ThrottleWriteBps, dp.ThrottleReadIOPS, dp.ThrottleWriteIOPS))
 			}
 		} else {
-			blockDevices, err := currentPlatform.configurableBlockDevices(dp.Devices)
+			blockDevices, err := resolveBlockDevices(dp)
 			if err != nil {
 				// Problems in matching block device wildcards and resolving symlinks
 				// are worth reporting, but must not block configuring blkio where possible.
 				log.Warnf("%v", err)
 			}
 			if len(blockDevices) == 0 {
-				log.Warnf("no matches on any of Devices: %v, parameters ignored", dp.Devices)
+				if dp.Rotational != nil {
+					log.Warnf("no matches on any of Devices: %v with rotational %v, parameters ignored", dp.Devices, *dp.Rotational)
+				} else {
+					log.Warnf("no matches on any of Devices: %v, parameters ignored", dp.Devices)
+				}
 			}
 			for _, blockDeviceInfo := range blockDevices {
 				if weight != -1 {
+					wr := defaultWeightRange
 					if ios, found := currentIOSchedulers[blockDeviceInfo.DevNode]; found {
-						if ios != "bfq" && ios != "cfq" {
+						if r, ok := ioSchedulerWeightRanges[ios]; ok {
+							wr = r
+						} else {
+							wr = anyWeightRange
 							log.Warnf("weight has no effect on device %#v due to "+
 								"incompatible I/O scheduler %#v (bfq or cfq required)", blockDeviceInfo.DevNode, ios)
 						}
 					}
-					blkio.WeightDevice.Update(blockDeviceInfo.Major, blockDeviceInfo.Minor, weight)
+					if weight < wr.min {
+						errs = append(errs, fmt.Errorf("value of \"Weight\" (%d) smaller than minimum (%d) on device %#v", weight, wr.min, blockDeviceInfo.DevNode))
+					} else if weight > wr.max {
+						errs = append(errs, fmt.Errorf("value of \"Weight\" (%d) bigger than maximum (%d) on device %#v", weight, wr.max, blockDeviceInfo.DevNode))
+					} else {
+						blkio.WeightDevice.Update(blockDeviceInfo.Major, blockDeviceInfo.Minor, weight)
+					}
 				}
 				if throttleReadBps != -1 {
 					blkio.ThrottleReadBpsDevice.Update(blockDeviceInfo.Major, blockDeviceInfo.Minor, throttleReadBps)
@@ -319,7 +474,58 @@ func devicesParametersToCgBlockIO(dps []DevicesParameters, currentIOSchedulers m
 	return blkio, errors.Join(errs...)
 }
 
+// resolveBlockDevices returns the block devices selected by the Devices
+// wildcards and the Rotational flag of DevicesParameters.
+func resolveBlockDevices(dp DevicesParameters) ([]tBlockDeviceInfo, error) {
+	if dp.Rotational == nil {
+		return currentPlatform.configurableBlockDevices(dp.Devices)
+	}
+
+	devNodes, err := currentPlatform.rotationalBlockDevices(*dp.Rotational)
+	if err != nil {
+		return nil, err
+	}
+	if dp.Devices == nil {
+		return currentPlatform.configurableBlockDevices(devNodes)
+	}
+
+	blockDevices, err := currentPlatform.configurableBlockDevices(dp.Devices)
+	matching := make([]tBlockDeviceInfo, 0, len(blockDevices))
+	for _, bdi := range blockDevices {
+		for _, devNode := range devNodes {
+			if bdi.DevNode == devNode {
+				matching = append(matching, bdi)
+				break
+			}
+		}
+	}
+	return matching, err
+}
+
+// parseAndValidateBps parses a bandwidth throttling value. The value is
+// either a quantity, like "64M", or a percentage of maxBps, like "50%". The
+// percentage is resolved to bytes per second, rounded to the nearest integer.
+func parseAndValidateBps(fieldName string, fieldContent string, maxBps int64) (int64, error) {
+	if !strings.HasSuffix(fieldContent, "%") {
+		return parseAndValidateQuantity(fieldName, fieldContent, -1, 0, -1)
+	}
+	if maxBps < 0 {
+		return -1, fmt.Errorf("percentage in %#v (%#v) requires MaxBps", fieldName, fieldContent)
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(fieldContent, "%")), 64)
+	if err != nil {
+		return -1, fmt.Errorf("syntax error in %#v (%#v)", fieldName, fieldContent)
+	}
+	if pct < 0 || pct > 100 {
+		return -1, fmt.Errorf("value of %#v (%#v) out of range, must be between 0%% and 100%%", fieldName, fieldContent)
+	}
+	return int64(math.Round(float64(maxBps) * pct / 100)), nil
+}
+
 // parseAndValidateQuantity parses quantities, like "64 M", and validates that they are in given range.
+// Fractional values are rounded to the nearest integer, e.g. "1.5M" is 1500000
+// and "2.5" is 3. Note that for throttling parameters 0 is a valid value and
+// it means removing the limit.
 func parseAndValidateQuantity(fieldName string, fieldContent string,
 	defaultValue int64, min int64, max int64) (int64, error) {
 	// Returns field content
@@ -330,9 +536,19 @@ func parseAndValidateQuantity(fieldName string, fieldContent string,
 	if err != nil {
 		return defaultValue, fmt.Errorf("syntax error in %#v (%#v)", fieldName, fieldContent)
 	}
-	value := qty.Value()
-	if min != -1 && min > value {
-		return defaultValue, fmt.Errorf("value of %#v (%#v) smaller than minimum (%#v)", fieldName, value, min)
+	// Compare the unrounded quantity against the minimum so that
+	// negative fractions, like "-0.4", are not rounded into range.
+	if min != -1 && qty.Cmp(*resource.NewQuantity(min, resource.DecimalSI)) < 0 {
+		return defaultValue, fmt.Errorf("value of %#v (%v) smaller than minimum (%#v)", fieldName, qty.String(), min)
+	}
+	value, ok := qty.AsInt64()
+	if !ok {
+		// Fractional value (or out of int64 range)
+		f := math.Round(qty.AsApproximateFloat64())
+		if f > math.MaxInt64 || f < math.MinInt64 {
+			return defaultValue, fmt.Errorf("value of %#v (%#v) out of range", fieldName, fieldContent)
+		}
+		value = int64(f)
 	}
 	if max != -1 && value > max {
 		return defaultValue, fmt.Errorf("value of %#v (%#v) bigger than maximum (%#v)", fieldName, value, max)
@@ -343,6 +559,7 @@ func parseAndValidateQuantity(fieldName string, fieldContent string,
 // platformInterface includes functions that access the system. Enables mocking the system.
 type platformInterface interface {
 	configurableBlockDevices(devWildcards []string) ([]tBlockDeviceInfo, error)
+	rotationalBlockDevices(rotational bool) ([]string, error)
 }
 
 // defaultPlatform versions of platformInterface functions access the underlying system.
@@ -436,3 +653,33 @@ func (dpm defaultPlatform) configurableBlockDevices(devWildcards []string) ([]tB
 	}
 	return blockDevices, errors.Join(errs...)
 }
+
+// rotationalBlockDevices returns the device nodes of the block devices whose
+// rotational flag matches the given one.
+func (dpm defaultPlatform) rotationalBlockDevices(rotational bool) ([]string, error) {
+	glob := goresctrlpath.Path(sysfsBlockDeviceRotationalPaths)
+	rotationalFiles, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, fmt.Errorf("error in rotational flag wildcards %#v: %w", glob, err)
+	}
+
+	want := "0"
+	if rotational {
+		want = "1"
+	}
+
+	devNodes := []string{}
+	for _, rotationalFile := range rotationalFiles {
+		data, err := os.ReadFile(rotationalFile)
+		if err != nil {
+			// A block device may be disconnected.
+			log.Errorf("failed to read rotational flag %#v: %v\n", rotationalFile, err)
+			continue
+		}
+		if strings.TrimSpace(string(data)) == want {
+			devName := filepath.Base(filepath.Dir(filepath.Dir(rotationalFile)))
+			devNodes = append(devNodes, "/dev/"+devName)
+		}
+	}
+	return devNodes, nil
+}
@@ -138,6 +138,57 @@ func TestClassNames(t *testing.T) {
 		classes)
 }
 
+func TestClassesWithParameters(t *testing.T) {
+	classBlockIO = map[string]BlockIOParameters{
+		"a": BlockIOParameters{
+			Weight: 100,
+			ThrottleReadBpsDevice: DeviceRates{
+				{Major: 11, Minor: 12, Rate: 1000},
+			},
+		},
+		"b": NewBlockIOParameters(),
+	}
+	classes := GetClassesWithParameters()
+	testutils.VerifyDeepEqual(t, "classes with parameters", classBlockIO, classes)
+
+	// Modifying the returned parameters must not affect the configuration
+	classes["a"].ThrottleReadBpsDevice[0].Rate = 2000
+	if rate := classBlockIO["a"].ThrottleReadBpsDevice[0].Rate; rate != 1000 {
+		t.Errorf("configured rate changed to %d via returned parameters", rate)
+	}
+
+	classBlockIO = map[string]BlockIOParameters{}
+	testutils.VerifyDeepEqual(t, "classes with parameters", map[string]BlockIOParameters{}, GetClassesWithParameters())
+}
+
+// TestUnusedClasses: unit test for GetUnusedClasses() and RemoveClass().
+func TestUnusedClasses(t *testing.T) {
+	classBlockIO = map[string]BlockIOParameters{
+		"a": NewBlockIOParameters(),
+		"b": NewBlockIOParameters(),
+		"c": NewBlockIOParameters(),
+	}
+	appliedClasses = map[string]struct{}{}
+	testutils.VerifyStringSlices(t, []string{"a", "b", "c"}, GetUnusedClasses())
+
+	if _, err := OciLinuxBlockIO("b"); err != nil {
+		t.Fatalf("OciLinuxBlockIO failed: %v", err)
+	}
+	if _, err := OciLinuxBlockIO("x"); err == nil {
+		t.Fatalf("OciLinuxBlockIO of non-existent class succeeded")
+	}
+	testutils.VerifyStringSlices(t, []string{"a", "c"}, GetUnusedClasses())
+
+	RemoveClass("a")
+	RemoveClass("b")
+	RemoveClass("x")
+	testutils.VerifyStringSlices(t, []string{"c"}, GetClasses())
+	testutils.VerifyStringSlices(t, []string{"c"}, GetUnusedClasses())
+
+	classBlockIO = map[string]BlockIOParameters{}
+	appliedClasses = map[string]struct{}{}
+}
+
 // TestGetCurrentIOSchedulers: unit test for getCurrentIOSchedulers().
 func TestGetCurrentIOSchedulers(t *testing.T) {
 	currentIOSchedulers, err := getCurrentIOSchedulers()
@@ -150,6 +201,29 @@ func TestGetCurrentIOSchedulers(t *testing.T) {
 	}
 }
 
+// TestGetWeightRange: unit test for GetWeightRange().
+func TestGetWeightRange(t *testing.T) {
+	currentIOSchedulers, err := getCurrentIOSchedulers()
+	testutils.VerifyError(t, err, 0, nil)
+	for blockDev, ioScheduler := range currentIOSchedulers {
+		min, max, err := GetWeightRange(blockDev)
+		r, ok := ioSchedulerWeightRanges[ioScheduler]
+		if !ok {
+			if err == nil {
+				t.Errorf("expected error for block device %#v with I/O scheduler %#v", blockDev, ioScheduler)
+			}
+			continue
+		}
+		testutils.VerifyError(t, err, 0, nil)
+		if min != r.min || max != r.max {
+			t.Errorf("unexpected weight range %d-%d for I/O scheduler %#v", min, max, ioScheduler)
+		}
+	}
+	if _, _, err := GetWeightRange("/dev/nonexistent-block-device"); err == nil {
+		t.Errorf("expected error for nonexistent block device")
+	}
+}
+
 // TestConfigurableBlockDevices: unit tests for configurableBlockDevices().
 func TestConfigurableBlockDevices(t *testing.T) {
 	sysfsBlockDevs, err := filepath.Glob("/sys/block/*")
@@ -325,6 +399,172 @@ func TestDevicesParametersToCgBlockIO(t *testing.T) {
 				"(-2) smaller than minimum",
 			},
 		},
+		{
+			name: "weights validated against the I/O scheduler",
+			dps: []DevicesParameters{
+				{
+					Weight: "5",
+				},
+				{
+					Devices: []string{"/dev/sda", "/dev/sdb", "/dev/sdc"},
+					Weight:  "5",
+				},
+			},
+			iosched: map[string]string{"/dev/sda": "bfq", "/dev/sdb": "cfq", "/dev/sdc": "none"},
+			// default weight 5 is invalid for cfq used by /dev/sdb
+			expectedErrorCount: 2,
+			expectedErrorSubstrings: []string{
+				"(5) smaller than minimum (10)",
+				"(5) smaller than minimum (10) on device \"/dev/sdb\"",
+			},
+		},
+		{
+			name: "small weight with bfq",
+			dps: []DevicesParameters{
+				{
+					Weight: "5",
+				},
+				{
+					Devices: []string{"/dev/sda"},
+					Weight:  "1",
+				},
+			},
+			iosched: map[string]string{"/dev/sda": "bfq", "/dev/sdb": "mq-deadline"},
+			expectedOci: &BlockIOParameters{
+				Weight: 5,
+				WeightDevice: DeviceWeights{
+					{Major: 11, Minor: 12, Weight: 1},
+				},
+			},
+		},
+		{
+			name: "fractional and zero throttling values",
+			dps: []DevicesParameters{
+				{
+					Devices:           []string{"/dev/sda"},
+					ThrottleReadBps:   "1.5M",
+					ThrottleWriteBps:  "0",
+					ThrottleReadIOPS:  "2.5",
+					ThrottleWriteIOPS: "0.4",
+				},
+			},
+			iosched: map[string]string{"/dev/sda": "bfq"},
+			expectedOci: &BlockIOParameters{
+				Weight: -1,
+				ThrottleReadBpsDevice: DeviceRates{
+					{Major: 11, Minor: 12, Rate: 1500000},
+				},
+				ThrottleWriteBpsDevice: DeviceRates{
+					{Major: 11, Minor: 12, Rate: 0},
+				},
+				ThrottleReadIOPSDevice: DeviceRates{
+					{Major: 11, Minor: 12, Rate: 3},
+				},
+				ThrottleWriteIOPSDevice: DeviceRates{
+					{Major: 11, Minor: 12, Rate: 0},
+				},
+			},
+		},
+		{
+			name: "negative throttling values",
+			dps: []DevicesParameters{
+				{
+					Devices:          []string{"/dev/sda"},
+					ThrottleReadBps:  "-1M",
+					ThrottleWriteBps: "-0.4",
+				},
+			},
+			expectedErrorCount: 2,
+			expectedErrorSubstrings: []string{
+				"(-1M) smaller than minimum",
+				"(-400m) smaller than minimum",
+			},
+		},
+		{
+			name: "devices selected by rotational flag",
+			dps: []DevicesParameters{
+				{
+					Rotational:      &[]bool{true}[0],
+					ThrottleReadBps: "10M",
+				},
+				{
+					Rotational:      &[]bool{false}[0],
+					ThrottleReadBps: "100M",
+				},
+				{
+					Devices:          []string{"/dev/sda", "/dev/sdb"},
+					Rotational:       &[]bool{false}[0],
+					ThrottleWriteBps: "50M",
+				},
+			},
+			expectedOci: &BlockIOParameters{
+				Weight: -1,
+				ThrottleReadBpsDevice: DeviceRates{
+					{Major: 11, Minor: 12, Rate: 10000000},
+					{Major: 21, Minor: 22, Rate: 100000000},
+					{Major: 31, Minor: 32, Rate: 100000000},
+				},
+				ThrottleWriteBpsDevice: DeviceRates{
+					{Major: 21, Minor: 22, Rate: 50000000},
+				},
+			},
+		},
+		{
+			name: "throttling as percentage of MaxBps",
+			dps: []DevicesParameters{
+				{
+					Devices:          []string{"/dev/sda"},
+					MaxBps:           "200M",
+					ThrottleReadBps:  "50%",
+					ThrottleWriteBps: "12.5%",
+				},
+				{
+					Devices:          []string{"/dev/sdb"},
+					MaxBps:           "1G",
+					ThrottleReadBps:  "100M",
+					ThrottleWriteBps: "0%",
+				},
+			},
+			expectedOci: &BlockIOParameters{
+				Weight: -1,
+				ThrottleReadBpsDevice: DeviceRates{
+					{Major: 11, Minor: 12, Rate: 100000000},
+					{Major: 21, Minor: 22, Rate: 100000000},
+				},
+				ThrottleWriteBpsDevice: DeviceRates{
+					{Major: 11, Minor: 12, Rate: 25000000},
+					{Major: 21, Minor: 22, Rate: 0},
+				},
+			},
+		},
+		{
+			name: "invalid throttling percentages",
+			dps: []DevicesParameters{
+				{
+					Devices:          []string{"/dev/sda"},
+					ThrottleReadBps:  "50%",
+					ThrottleWriteBps: "10%",
+				},
+				{
+					Devices:          []string{"/dev/sdb"},
+					MaxBps:           "1G",
+					ThrottleReadBps:  "150%",
+					ThrottleWriteBps: "x%",
+				},
+				{
+					Devices: []string{"/dev/sdc"},
+					MaxBps:  "0",
+				},
+			},
+			expectedErrorCount: 5,
+			expectedErrorSubstrings: []string{
+				"percentage in \"ThrottleReadBps\" (\"50%\") requires MaxBps",
+				"percentage in \"ThrottleWriteBps\" (\"10%\") requires MaxBps",
+				"\"ThrottleReadBps\" (\"150%\") out of range",
+				"syntax error in \"ThrottleWriteBps\" (\"x%\")",
+				"value of \"MaxBps\" (0) smaller than minimum",
+			},
+		},
 		{
 			name: "throttling without listing Devices",
 			dps: []DevicesParameters{
@@ -384,3 +624,12 @@ func (mpf mockPlatform) configurableBlockDevices(devWildcards []string) ([]tBloc
 	}
 	return blockDevices, nil
 }
+
+// rotationalBlockDevices mock has rotational /dev/sda and non-rotational
+// /dev/sdb and /dev/sdc.
+func (mpf mockPlatform) rotationalBlockDevices(rotational bool) ([]string, error) {
+	if rotational {
+		return []string{"/dev/sda"}, nil
+	}
+	return []string{"/dev/sdb", "/dev/sdc"}, nil
+}
@@ -64,6 +64,40 @@ func NewBlockIOParameters() BlockIOParameters {
 	}
 }
 
+// clone returns a deep copy of BlockIOParameters.
+func (p BlockIOParameters) clone() BlockIOParameters {
+	c := p
+	c.WeightDevice = append(DeviceWeights(nil), p.WeightDevice...)
+	c.ThrottleReadBpsDevice = append(DeviceRates(nil), p.ThrottleReadBpsDevice...)
+	c.ThrottleWriteBpsDevice = append(DeviceRates(nil), p.ThrottleWriteBpsDevice...)
+	c.ThrottleReadIOPSDevice = append(DeviceRates(nil), p.ThrottleReadIOPSDevice...)
+	c.ThrottleWriteIOPSDevice = append(DeviceRates(nil), p.ThrottleWriteIOPSDevice...)
+	return c
+}
+
+// reset returns parameters that clear the settings of p: the weight is left
+// unset and the device weights and rates are set to zero.
+func (p BlockIOParameters) reset() BlockIOParameters {
+	r := NewBlockIOParameters()
+	for _, w := range p.WeightDevice {
+		r.WeightDevice.Append(w.Major, w.Minor, 0)
+	}
+	for _, rates := range []struct {
+		src DeviceRates
+		dst *DeviceRates
+	}{
+		{p.ThrottleReadBpsDevice, &r.ThrottleReadBpsDevice},
+		{p.ThrottleWriteBpsDevice, &r.ThrottleWriteBpsDevice},
+		{p.ThrottleReadIOPSDevice, &r.ThrottleReadIOPSDevice},
+		{p.ThrottleWriteIOPSDevice, &r.ThrottleWriteIOPSDevice},
+	} {
+		for _, rate := range rates.src {
+			rates.dst.Append(rate.Major, rate.Minor, 0)
+		}
+	}
+	return r
+}
+
 // DeviceParameters interface provides functions common to DeviceWeights and DeviceRates.
 type DeviceParameters interface {
 	Append(maj, min, val int64)
@@ -24,10 +24,20 @@ type Config struct {
 
 // DevicesParameters defines Block IO parameters for a set of devices.
 type DevicesParameters struct {
-	Devices           []string `json:",omitempty"`
-	ThrottleReadBps   string   `json:",omitempty"`
-	ThrottleWriteBps  string   `json:",omitempty"`
-	ThrottleReadIOPS  string   `json:",omitempty"`
-	ThrottleWriteIOPS string   `json:",omitempty"`
-	Weight            string   `json:",omitempty"`
+	Devices []string `json:",omitempty"`
+	// Rotational selects devices by their rotational flag
+	// (/sys/block/*/queue/rotational): true for spinning disks, false
+	// for SSDs and other non-rotational devices. Without Devices it
+	// selects all matching block devices, with Devices it narrows down
+	// the devices listed there.
+	Rotational *bool `json:",omitempty"`
+	// MaxBps is the maximum bandwidth of the devices, e.g. "500M". It
+	// enables expressing ThrottleReadBps and ThrottleWriteBps as
+	// percentages of the device bandwidth, e.g. "50%".
+	MaxBps            string `json:",omitempty"`
+	ThrottleReadBps   string `json:",omitempty"`
+	ThrottleWriteBps  string `json:",omitempty"`
+	ThrottleReadIOPS  string `json:",omitempty"`
+	ThrottleWriteIOPS string `json:",omitempty"`
+	Weight            string `json:",omitempty"`
 }
@@ -28,6 +28,10 @@ func OciLinuxBlockIO(class string) (*oci.LinuxBlockIO, error) {
 	if !ok {
 		return nil, fmt.Errorf("no OCI BlockIO parameters for class %#v", class)
 	}
+	appliedClasses[class] = struct{}{}
+	if _, ok := disabledClasses[class]; ok {
+		blockio = blockio.reset()
+	}
 	ociBlockio := oci.LinuxBlockIO{}
 	if blockio.Weight != -1 {
 		w := uint16(blockio.Weight)
@@ -118,6 +118,49 @@ func TestOciLinuxBlockIO(t *testing.T) {
 	}
 }
 
+// TestSetClassEnabled: unit tests for SetClassEnabled().
+func TestSetClassEnabled(t *testing.T) {
+	classBlockIO = map[string]BlockIOParameters{
+		"throttled": BlockIOParameters{
+			Weight: 10,
+			WeightDevice: DeviceWeights{
+				{Major: 20, Minor: 21, Weight: 22},
+			},
+			ThrottleReadBpsDevice: DeviceRates{
+				{Major: 30, Minor: 31, Rate: 32},
+			},
+			ThrottleWriteIOPSDevice: DeviceRates{
+				{Major: 60, Minor: 61, Rate: 62},
+			},
+		},
+	}
+	defer func() {
+		disabledClasses = map[string]struct{}{}
+	}()
+
+	testutils.VerifyError(t, SetClassEnabled("foobar", false), 1, []string{"foobar"})
+
+	if err := SetClassEnabled("throttled", false); err != nil {
+		t.Fatalf("SetClassEnabled() failed: %v", err)
+	}
+	gotBlockIO, err := OciLinuxBlockIO("throttled")
+	testutils.VerifyNoError(t, err)
+	testutils.VerifyDeepEqual(t, "OCI BlockIO of disabled class", &oci.LinuxBlockIO{
+		WeightDevice:            []oci.LinuxWeightDevice{linuxWeightDevice([3]uint16{20, 21, 0})},
+		ThrottleReadBpsDevice:   []oci.LinuxThrottleDevice{linuxThrottleDevice([3]uint64{30, 31, 0})},
+		ThrottleWriteIOPSDevice: []oci.LinuxThrottleDevice{linuxThrottleDevice([3]uint64{60, 61, 0})},
+	}, gotBlockIO)
+	testutils.VerifyDeepEqual(t, "class parameters", int64(32), classBlockIO["throttled"].ThrottleReadBpsDevice[0].Rate)
+
+	if err := SetClassEnabled("throttled", true); err != nil {
+		t.Fatalf("SetClassEnabled() failed: %v", err)
+	}
+	gotBlockIO, err = OciLinuxBlockIO("throttled")
+	testutils.VerifyNoError(t, err)
+	testutils.VerifyDeepEqual(t, "OCI BlockIO of enabled class",
+		[]oci.LinuxThrottleDevice{linuxThrottleDevice([3]uint64{30, 31, 32})}, gotBlockIO.ThrottleReadBpsDevice)
+}
+
 func linuxWeightDevice(triplet [3]uint16) oci.LinuxWeightDevice {
 	wd := oci.LinuxWeightDevice{}
 	wd.Major = int64(triplet[0])
@@ -66,6 +66,23 @@ func (b bitmask) listStr() string {
 	return str
 }
 
+// ranges returns the contiguous blocks of bits set in the bitmask, in
+// ascending order
+func (b bitmask) ranges() []bitmask {
+	ret := []bitmask{}
+	for b != 0 {
+		lsb := b.lsbOne()
+		numOnes := (b >> uint(lsb)).lsbZero()
+		r := bitmask(((uint64(1) << uint(numOnes)) - 1) << uint(lsb))
+		if numOnes == 64 {
+			r = ^bitmask(0)
+		}
+		ret = append(ret, r)
+		b &^= r
+	}
+	return ret
+}
+
 // listStrToBitmask parses a string containing a human-readable list of bit
 // numbers into a bitmask
 func listStrToBitmask(str string) (bitmask, error) {
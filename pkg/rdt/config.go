@@ -18,6 +18,7 @@ package rdt
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/bits"
@@ -25,24 +26,63 @@ import (
 	"strconv"
 	"strings"
 
+	"sigs.k8s.io/yaml"
+
 	grclog "github.com/intel/goresctrl/pkg/log"
 	"github.com/intel/goresctrl/pkg/utils"
 )
 
+// ConfigVersion is the current version of the configuration format.
+const ConfigVersion = 1
+
 // Config is the user-specified RDT configuration.
 type Config struct {
-	Options    Options `json:"options"`
-	Partitions map[string]struct {
-		L2Allocation CatConfig `json:"l2Allocation"`
-		L3Allocation CatConfig `json:"l3Allocation"`
-		MBAllocation MbaConfig `json:"mbAllocation"`
-		Classes      map[string]struct {
-			L2Allocation CatConfig         `json:"l2Allocation"`
-			L3Allocation CatConfig         `json:"l3Allocation"`
-			MBAllocation MbaConfig         `json:"mbAllocation"`
-			Kubernetes   KubernetesOptions `json:"kubernetes"`
-		} `json:"classes"`
-	} `json:"partitions"`
+	// Version is the version of the configuration format. Zero is
+	// treated as the current version. Configurations using an older format
+	// can be converted with MigrateConfig().
+	Version    int                        `json:"version,omitempty"`
+	Options    Options                    `json:"options"`
+	Partitions map[string]PartitionConfig `json:"partitions"`
+}
+
+// PartitionConfig is the user-specified configuration of one partition.
+type PartitionConfig struct {
+	L2Allocation CatConfig `json:"l2Allocation"`
+	L3Allocation CatConfig `json:"l3Allocation"`
+	MBAllocation MbaConfig `json:"mbAllocation"`
+	// MBProportional makes the percentage based MB allocations of the classes
+	// relative shares that are normalized to the MB allocation of the
+	// partition.
+	MBProportional bool `json:"mbProportional"`
+	// SharedL3 allows the absolute L3 allocation of the partition to overlap
+	// with the L3 allocations of other partitions that also have SharedL3 set.
+	// Cache isolation between the overlapping partitions is not guaranteed.
+	SharedL3 bool                   `json:"sharedL3"`
+	Classes  map[string]ClassConfig `json:"classes"`
+}
+
+// ClassConfig is the user-specified configuration of one class.
+type ClassConfig struct {
+	L2Allocation CatConfig         `json:"l2Allocation"`
+	L3Allocation CatConfig         `json:"l3Allocation"`
+	MBAllocation MbaConfig         `json:"mbAllocation"`
+	Kubernetes   KubernetesOptions `json:"kubernetes"`
+	// Annotations are default annotations of the monitoring groups created
+	// under the class.
+	Annotations map[string]string `json:"annotations"`
+	// MonitoringOnly makes the class a plain monitoring group whose schemata
+	// is never written, i.e. it keeps the allocation it was created with. No
+	// allocation may be specified for such a class.
+	MonitoringOnly bool `json:"monitoringOnly"`
+	// Closid requests a specific CLOSID for the class. The kernel assigns the
+	// lowest free CLOSID to a new resctrl group, so classes requesting a
+	// CLOSID are created first, in ascending order of the requested id. The
+	// result can only be verified if resctrl is mounted with the "debug"
+	// option.
+	Closid *uint64 `json:"closid"`
+	// MonitorGroups are monitoring groups created under the class. They are
+	// never pruned, even if they have no tasks.
+	MonitorGroups map[string]MonitorGroupOptions `json:"monitorGroups"`
 }
 
 // CatConfig contains the L2 or L3 cache allocation configuration for one partition or class.
@@ -85,6 +125,11 @@ type MbProportion string
 // - percentage range, e.g. `50-60%`
 // - bit numbers, e.g. `0-5`, `2,3`, must contain one contiguous block of bits set
 // - hex bitmask, e.g. `0xff0`, must contain one contiguous block of bits set
+// - bits to exclude, e.g. `^0-1` or `^0x3`, allocating all bits of the
+// partition except the given ones, the result must be one contiguous block
+// - number of cache ways, e.g. `ways:4`, taken from the lowest bits of the
+// partition
+// - fraction of the partition's cache ways, `half` or `quarter`, rounded down
 type CacheProportion string
 
 // CacheIdAll is a special cache id used to denote a default, used as a
@@ -107,7 +152,7 @@ type classSet map[string]*classConfig
 
 // partitionConfig is the final configuration of one partition
 type partitionConfig struct {
-	CAT map[cacheLevel]catSchema
+	CAT map[CacheLevel]catSchema
 	MB  mbSchema
 }
 
@@ -115,9 +160,17 @@ type partitionConfig struct {
 // the Linux resctrl interface
 type classConfig struct {
 	Partition  string
-	CATSchema  map[cacheLevel]catSchema
+	CATSchema  map[CacheLevel]catSchema
 	MBSchema   mbSchema
 	Kubernetes KubernetesOptions
+	// Annotations are inherited by monitoring groups of the class
+	Annotations map[string]string
+	// MonitoringOnly classes have no allocation of their own
+	MonitoringOnly bool
+	// Closid is the CLOSID requested for the class, if any
+	Closid *uint64
+	// MonGroups are the monitoring groups created from the configuration
+	MonGroups map[string]MonitorGroupOptions
 }
 
 // Options contains common settings.
@@ -125,16 +178,65 @@ type Options struct {
 	L2 CatOptions `json:"l2"`
 	L3 CatOptions `json:"l3"`
 	MB MbOptions  `json:"mb"`
+	// NamespaceClasses makes class names local to their partition. When
+	// set, each class is named "<partition>/<class>" so that the same class
+	// name may be used in multiple partitions.
+	NamespaceClasses bool `json:"namespaceClasses"`
+	// StrictRootClass makes SetConfig() fail with ErrRootClassMissing if the
+	// root class has unexpectedly disappeared from the runtime data,
+	// instead of re-adding it with a warning.
+	StrictRootClass bool `json:"strictRootClass"`
 }
 
 // CatOptions contains the common settings for cache allocation.
 type CatOptions struct {
 	Optional bool
+	// RootReserve is a percentage of the cache, e.g. "10%", that is left
+	// out of the partition allocations and reserved for the root class.
+	// The reserved cache ways are the lowest bits of the bitmask.
+	RootReserve CacheProportion `json:"rootReserve"`
+	// CheckShareable enables checking that the partition allocations do
+	// not overlap the shareable bits of the cache, i.e. cache ways that the
+	// hardware may share with other agents, like integrated graphics.
+	// Overlaps are reported as warnings unless FailOnShareable is set.
+	CheckShareable bool `json:"checkShareable"`
+	// FailOnShareable makes an overlap detected with CheckShareable an
+	// error.
+	FailOnShareable bool `json:"failOnShareable"`
+	// ReportUnused enables logging the cache bits that are allocated to a
+	// partition but not used by any of its classes.
+	ReportUnused bool `json:"reportUnused"`
 }
 
 // MbOptions contains the common settings for memory bandwidth allocation.
 type MbOptions struct {
 	Optional bool
+	// Proportional sets mbProportional for all partitions, i.e. makes the
+	// percentage based MB allocations of the classes relative shares of
+	// the MB allocation of their partition.
+	Proportional bool `json:"proportional"`
+	// Strict rejects MB allocation specs that would otherwise be silently
+	// ignored: values for the inactive MBA mode (percentage or MBps),
+	// multiple values of the same unit, values with an unrecognized unit and
+	// proportional allocation in MBps mode.
+	Strict bool `json:"strict"`
+	// ConvertUnits makes MB allocations that only specify a value for the
+	// inactive MBA mode usable by converting the value to the unit of the
+	// active mode, i.e. '%' to MBps or vice versa. MaxBandwidthMBps must
+	// be set for the conversion.
+	ConvertUnits bool `json:"convertUnits"`
+	// MaxBandwidthMBps is the memory bandwidth, in MBps, that corresponds
+	// to a 100% allocation. It is only used by ConvertUnits and there is no
+	// way to detect it from the system.
+	MaxBandwidthMBps uint64 `json:"maxBandwidthMBps"`
+}
+
+// MonitorGroupOptions contains the settings of a monitoring group created
+// from the configuration.
+type MonitorGroupOptions struct {
+	// Annotations of the monitoring group, overriding the default
+	// annotations of the class.
+	Annotations map[string]string `json:"annotations"`
 }
 
 // KubernetesOptions contains per-class settings for the Kubernetes-related functionality.
@@ -145,7 +247,7 @@ type KubernetesOptions struct {
 
 // catSchema represents a cache part of the schemata of a class (i.e. resctrl group)
 type catSchema struct {
-	Lvl   cacheLevel
+	Lvl   CacheLevel
 	Alloc catSchemaRaw
 }
 
@@ -172,10 +274,28 @@ type cacheAllocation interface {
 // bitmask
 type catAbsoluteAllocation bitmask
 
+// catExcludeAllocation represents an allocation of everything but the
+// explicitly specified bits of the available bitmask
+type catExcludeAllocation bitmask
+
 // catPctAllocation represents a relative (percentage) share of the available
 // bitmask
 type catPctAllocation uint64
 
+// catWaysAllocation represents a number of cache ways (bits) at the low end
+// of the available bitmask
+type catWaysAllocation uint64
+
+// catFractionAllocation represents a fraction (1/n) of the cache ways of the
+// available bitmask, the value being the denominator
+type catFractionAllocation uint64
+
+// cacheFractions are the fractions accepted in cache allocations
+var cacheFractions = map[string]catFractionAllocation{
+	"half":    2,
+	"quarter": 4,
+}
+
 // catPctRangeAllocation represents a percentage range of the available bitmask
 type catPctRangeAllocation struct {
 	lowPct  uint64
@@ -195,7 +315,7 @@ const (
 )
 
 // cat returns CAT options for the specified cache level.
-func (o Options) cat(lvl cacheLevel) CatOptions {
+func (o Options) cat(lvl CacheLevel) CatOptions {
 	switch lvl {
 	case L2:
 		return o.L2
@@ -205,6 +325,50 @@ func (o Options) cat(lvl cacheLevel) CatOptions {
 	return CatOptions{}
 }
 
+// rootReserveMask returns the bitmask of the cache ways reserved for the root
+// class, or zero if there is no reservation.
+func (o CatOptions) rootReserveMask(lvl CacheLevel) (bitmask, error) {
+	if o.RootReserve == "" {
+		return 0, nil
+	}
+
+	minBits := info.cat[lvl].minCbmBits()
+	a, err := o.RootReserve.parse(minBits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s root reserve: %v", lvl, err)
+	}
+	pct, ok := a.(catPctAllocation)
+	if !ok {
+		return 0, fmt.Errorf("invalid %s root reserve %q: only percentage is supported", lvl, o.RootReserve)
+	}
+	if pct == 0 {
+		return 0, nil
+	}
+
+	// Round up, reserving at least the minimum number of bits
+	bitsTotal := uint64(info.cat[lvl].cbmMask().lsbZero())
+	numBits := (uint64(pct)*bitsTotal + 99) / 100
+	if numBits < minBits {
+		numBits = minBits
+	}
+	if numBits+minBits > bitsTotal {
+		return 0, fmt.Errorf("%s root reserve %q leaves no cache for partitions", lvl, o.RootReserve)
+	}
+	return bitmask(1<<numBits - 1), nil
+}
+
+// catSchemaTypes returns the schema types in use for a cache level, i.e.
+// code and data if CDP is enabled, otherwise unified.
+func catSchemaTypes(lvl CacheLevel) []catSchemaType {
+	switch {
+	case info.cat[lvl].unified.Supported():
+		return []catSchemaType{catSchemaTypeUnified}
+	case info.cat[lvl].data.Supported() || info.cat[lvl].code.Supported():
+		return []catSchemaType{catSchemaTypeCode, catSchemaTypeData}
+	}
+	return nil
+}
+
 func (t catSchemaType) toResctrlStr() string {
 	if t == catSchemaTypeUnified {
 		return ""
@@ -217,16 +381,139 @@ const (
 	mbSuffixMbps = "MBps"
 )
 
-func newCatSchema(typ cacheLevel) catSchema {
+func newCatSchema(typ CacheLevel) catSchema {
 	return catSchema{
 		Lvl:   typ,
 		Alloc: make(map[uint64]catAllocation),
 	}
 }
 
+// schemata returns the resctrl schemata lines of a class, one line per
+// resource
+func (c *classConfig) schemata(name string, partition *partitionConfig, options Options) ([]string, error) {
+	schemata := []string{}
+
+	if c.MonitoringOnly {
+		return schemata, nil
+	}
+
+	// Handle cache allocation
+	for _, lvl := range []CacheLevel{L2, L3} {
+		types := catSchemaTypes(lvl)
+		if len(types) == 0 && c.CATSchema[lvl].Alloc != nil && !options.cat(lvl).Optional {
+			return nil, fmt.Errorf("%s cache allocation for %q specified in configuration but not supported by system", lvl, name)
+		}
+		// The root class always has the reserved cache ways
+		reserve := bitmask(0)
+		if isRootClass(name) && len(types) > 0 {
+			var err error
+			if reserve, err = options.cat(lvl).rootReserveMask(lvl); err != nil {
+				return nil, err
+			}
+		}
+		for _, typ := range types {
+			schema, err := c.CATSchema[lvl].toStr(typ, partition.CAT[lvl], reserve)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q allocation for class %q: %v", lvl, typ, name, err)
+			}
+			schemata = append(schemata, schema)
+		}
+	}
+
+	// Handle memory bandwidth allocation
+	switch {
+	case info.mb.Supported():
+		schemata = append(schemata, c.MBSchema.toStr(partition.MB))
+	default:
+		if c.MBSchema != nil && !options.MB.Optional {
+			return nil, fmt.Errorf("memory bandwidth allocation for %q specified in configuration but not supported by system", name)
+		}
+	}
+
+	return schemata, nil
+}
+
+// effectiveCatMask returns the cache bitmask of a class for one cache id,
+// including the root reserve in case of the root class.
+func (c *classConfig) effectiveCatMask(name string, lvl CacheLevel, id uint64, typ catSchemaType,
+	partition *partitionConfig, options Options) (bitmask, error) {
+	mask, err := c.CATSchema[lvl].effectiveMask(id, typ, partition.CAT[lvl])
+	if err != nil || !isRootClass(name) {
+		return mask, err
+	}
+	reserve, err := options.cat(lvl).rootReserveMask(lvl)
+	if err != nil {
+		return 0, err
+	}
+	return mask | reserve, nil
+}
+
+// defaultClassConfig returns a class and partition configuration
+// corresponding to full (100%) allocation of all resources, i.e. the default
+// state of a resctrl group
+func defaultClassConfig() (*classConfig, *partitionConfig) {
+	class := &classConfig{
+		CATSchema: map[CacheLevel]catSchema{
+			L2: {Lvl: L2},
+			L3: {Lvl: L3},
+		},
+	}
+	partition := &partitionConfig{
+		CAT: map[CacheLevel]catSchema{
+			L2: newCatSchema(L2),
+			L3: newCatSchema(L3),
+		},
+		MB: mbSchema{},
+	}
+	return class, partition
+}
+
+// classAllocation contains the resolved allocation of one class
+type classAllocation struct {
+	class     *classConfig
+	partition *partitionConfig
+}
+
+// resetsRootClass returns true if SetConfig() resets the root class to full
+// allocation, i.e. if the configuration does not have any classes.
+// Otherwise a root class not specified in the configuration is left
+// untouched.
+func (c config) resetsRootClass() bool {
+	return len(c.Classes) == 0
+}
+
+// classAllocation returns the allocation of a class as applied by
+// SetConfig(). The root class is only managed by the configuration if it is
+// specified in it, or if it is reset, see resetsRootClass().
+func (c config) classAllocation(name string) (classAllocation, bool) {
+	name = unaliasClassName(name)
+	if class, ok := c.Classes[name]; ok {
+		return classAllocation{class, c.Partitions[class.Partition]}, true
+	}
+	if name == RootClassName && c.resetsRootClass() {
+		class, partition := defaultClassConfig()
+		return classAllocation{class, partition}, true
+	}
+	return classAllocation{}, false
+}
+
+// classAllocations returns the allocations of all classes managed by the
+// configuration, see classAllocation().
+func (c config) classAllocations() map[string]classAllocation {
+	ret := make(map[string]classAllocation, len(c.Classes)+1)
+	for name := range c.Classes {
+		ret[name], _ = c.classAllocation(name)
+	}
+	if a, ok := c.classAllocation(RootClassName); ok {
+		ret[RootClassName] = a
+	}
+	return ret
+}
+
 // toStr returns the CAT schema in a format accepted by the Linux kernel
-// resctrl (schemata) interface
-func (s catSchema) toStr(typ catSchemaType, baseSchema catSchema) (string, error) {
+// resctrl (schemata) interface. The extra bits are added to the allocation
+// of each cache id.
+func (s catSchema) toStr(typ catSchemaType, baseSchema catSchema, extra bitmask) (string, error) {
 	schema := string(s.Lvl) + typ.toResctrlStr() + ":"
 	sep := ""
 
@@ -234,28 +521,15 @@ func (s catSchema) toStr(typ catSchemaType, baseSchema catSchema) (string, error
 	ids := append([]uint64{}, info.cat[s.Lvl].cacheIds...)
 	utils.SortUint64s(ids)
 
-	minBits := info.cat[s.Lvl].minCbmBits()
 	for _, id := range ids {
-		// Default to 100%
-		bmask := info.cat[s.Lvl].cbmMask()
-
-		if base, ok := baseSchema.Alloc[id]; ok {
-			baseMask, ok := base.getEffective(typ).(catAbsoluteAllocation)
-			if !ok {
-				return "", fmt.Errorf("BUG: basemask not of type catAbsoluteAllocation")
-			}
-			bmask = bitmask(baseMask)
+		bmask, err := s.effectiveMask(id, typ, baseSchema)
+		if err != nil {
+			return "", err
 		}
-
-		if s.Alloc != nil {
-			var err error
-
-			masks := s.Alloc[id]
-			overlayMask := masks.getEffective(typ)
-
-			bmask, err = overlayMask.Overlay(bmask, minBits)
-			if err != nil {
-				return "", err
+		if extra != 0 {
+			bmask |= extra
+			if err := verifyCatBaseMask(bmask, 0); err != nil {
+				return "", fmt.Errorf("%s allocation for cache id %d not contiguous with the root reserve %#x: %v", s.Lvl, id, extra, err)
 			}
 		}
 		schema += fmt.Sprintf("%s%d=%x", sep, id, bmask)
@@ -265,6 +539,30 @@ func (s catSchema) toStr(typ catSchemaType, baseSchema catSchema) (string, error
 	return schema + "\n", nil
 }
 
+// effectiveMask returns the cache bitmask of one cache id, i.e. the
+// allocation applied on the base (partition) schema
+func (s catSchema) effectiveMask(id uint64, typ catSchemaType, baseSchema catSchema) (bitmask, error) {
+	// Default to 100%
+	bmask := info.cat[s.Lvl].cbmMask()
+
+	if base, ok := baseSchema.Alloc[id]; ok {
+		baseMask, ok := base.getEffective(typ).(catAbsoluteAllocation)
+		if !ok {
+			return 0, fmt.Errorf("BUG: basemask not of type catAbsoluteAllocation")
+		}
+		bmask = bitmask(baseMask)
+	}
+
+	if s.Alloc != nil {
+		masks := s.Alloc[id]
+		overlayMask := masks.getEffective(typ)
+
+		return overlayMask.Overlay(bmask, info.cat[s.Lvl].minCbmBits())
+	}
+
+	return bmask, nil
+}
+
 func (a catAllocation) get(typ catSchemaType) cacheAllocation {
 	switch typ {
 	case catSchemaTypeCode:
@@ -322,11 +620,122 @@ func (a catAbsoluteAllocation) Overlay(baseMask bitmask, minBits uint64) (bitmas
 	return bmask, nil
 }
 
+// Overlay function of the cacheAllocation interface
+func (a catExcludeAllocation) Overlay(baseMask bitmask, minBits uint64) (bitmask, error) {
+	if err := verifyCatBaseMask(baseMask, minBits); err != nil {
+		return 0, err
+	}
+
+	// Treat our bitmask relative to the basemask
+	exclude := bitmask(a) << baseMask.lsbOne()
+	bmask := baseMask &^ exclude
+
+	if bmask == 0 {
+		return 0, fmt.Errorf("excluding %#x from basemask %#x leaves no bits", exclude, baseMask)
+	}
+	numOnes := bits.OnesCount64(uint64(bmask))
+	if numOnes != bmask.msbOne()-bmask.lsbOne()+1 {
+		return 0, fmt.Errorf("excluding %#x from basemask %#x results in non-contiguous bitmask %#x", exclude, baseMask, bmask)
+	}
+	if uint64(numOnes) < minBits {
+		return 0, fmt.Errorf("excluding %#x from basemask %#x leaves fewer than %d bits", exclude, baseMask, minBits)
+	}
+
+	return bmask, nil
+}
+
+// Overlay function of the cacheAllocation interface
+func (a catWaysAllocation) Overlay(baseMask bitmask, minBits uint64) (bitmask, error) {
+	if err := verifyCatBaseMask(baseMask, minBits); err != nil {
+		return 0, err
+	}
+
+	numBits := uint64(bits.OnesCount64(uint64(baseMask)))
+	if uint64(a) > numBits {
+		return 0, fmt.Errorf("%d cache ways requested but basemask %#x only has %d", a, baseMask, numBits)
+	}
+	if uint64(a) < minBits {
+		return 0, fmt.Errorf("%d cache ways requested, minimum is %d", a, minBits)
+	}
+
+	return bitmask(((1 << uint64(a)) - 1) << baseMask.lsbOne()), nil
+}
+
+// Overlay function of the cacheAllocation interface
+func (a catFractionAllocation) Overlay(baseMask bitmask, minBits uint64) (bitmask, error) {
+	if err := verifyCatBaseMask(baseMask, minBits); err != nil {
+		return 0, err
+	}
+
+	// Round down, but guarantee the minimum number of ways
+	numBits := uint64(bits.OnesCount64(uint64(baseMask))) / uint64(a)
+	if numBits < minBits {
+		numBits = minBits
+	}
+	if numBits == 0 {
+		numBits = 1
+	}
+
+	return catWaysAllocation(numBits).Overlay(baseMask, minBits)
+}
+
+func (a catFractionAllocation) String() string {
+	for name, v := range cacheFractions {
+		if v == a {
+			return name
+		}
+	}
+	return fmt.Sprintf("1/%d", uint64(a))
+}
+
+// cacheAllocationStr returns a cache allocation in human-readable form
+func cacheAllocationStr(a cacheAllocation) string {
+	switch v := a.(type) {
+	case catWaysAllocation:
+		return fmt.Sprintf("ways:%d", v)
+	case catFractionAllocation:
+		return v.String()
+	case catAbsoluteAllocation:
+		return fmt.Sprintf("%#x", bitmask(v))
+	case catExcludeAllocation:
+		return fmt.Sprintf("^%#x", bitmask(v))
+	case catPctAllocation:
+		return fmt.Sprintf("%d%%", v)
+	case catPctRangeAllocation:
+		return fmt.Sprintf("%d-%d%%", v.lowPct, v.highPct)
+	}
+	return "-"
+}
+
+// mbAllocationStr returns a memory bandwidth allocation value in
+// human-readable form
+func mbAllocationStr(v uint64) string {
+	if info.mb.mbpsEnabled {
+		return fmt.Sprintf("%d%s", v, mbSuffixMbps)
+	}
+	return fmt.Sprintf("%d%s", v, mbSuffixPct)
+}
+
 // MarshalJSON implements the Marshaler interface of "encoding/json"
 func (a catAbsoluteAllocation) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("\"%#x\"", a)), nil
 }
 
+// MarshalJSON implements the Marshaler interface of "encoding/json"
+func (a catExcludeAllocation) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"^%#x\"", a)), nil
+}
+
+// MarshalJSON implements the Marshaler interface of "encoding/json"
+func (a catWaysAllocation) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"ways:%d\"", a)), nil
+}
+
+// MarshalJSON implements the Marshaler interface of "encoding/json"
+func (a catFractionAllocation) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", a.String())), nil
+}
+
 // Overlay function of the cacheAllocation interface
 func (a catPctAllocation) Overlay(baseMask bitmask, minBits uint64) (bitmask, error) {
 	return catPctRangeAllocation{highPct: uint64(a)}.Overlay(baseMask, minBits)
@@ -422,42 +831,78 @@ func (s mbSchema) toStr(base map[uint64]uint64) string {
 	utils.SortUint64s(ids)
 
 	for _, id := range ids {
-		baseAllocation, ok := base[id]
-		if !ok {
-			if info.mb.mbpsEnabled {
-				baseAllocation = math.MaxUint32
-			} else {
-				baseAllocation = 100
+		value := s.effectiveValue(id, base)
+		if !info.mb.mbpsEnabled {
+			if requested := s.requestedPct(id, base); requested != value {
+				log.Debugf("MB allocation of cache id %d rounded from %d%% to %d%% (granularity %d%%)",
+					id, requested, value, info.mb.bandwidthGran)
 			}
 		}
+		schema += fmt.Sprintf("%s%d=%d", sep, id, value)
+		sep = ";"
+	}
 
-		value := uint64(0)
+	return schema + "\n"
+}
+
+// effectiveValue returns the memory bandwidth allocation of one cache id,
+// i.e. the allocation applied on the base (partition) schema
+func (s mbSchema) effectiveValue(id uint64, base map[uint64]uint64) uint64 {
+	baseAllocation, ok := base[id]
+	if !ok {
 		if info.mb.mbpsEnabled {
-			value = math.MaxUint32
-			if s != nil {
-				value = s[id]
-			}
-			// Limit to given base value
-			if value > baseAllocation {
-				value = baseAllocation
-			}
+			baseAllocation = math.MaxUint32
 		} else {
-			allocation := uint64(100)
-			if s != nil {
-				allocation = s[id]
-			}
-			value = allocation * baseAllocation / 100
-			// Guarantee minimum bw so that writing out the schemata does not fail
-			if value < info.mb.minBandwidth {
-				value = info.mb.minBandwidth
-			}
+			baseAllocation = 100
 		}
+	}
 
-		schema += fmt.Sprintf("%s%d=%d", sep, id, value)
-		sep = ";"
+	value := uint64(0)
+	if info.mb.mbpsEnabled {
+		value = math.MaxUint32
+		if s != nil {
+			value = s[id]
+		}
+		// Limit to given base value
+		if value > baseAllocation {
+			value = baseAllocation
+		}
+	} else {
+		value = roundMBPct(s.requestedPct(id, base))
 	}
 
-	return schema + "\n"
+	return value
+}
+
+// requestedPct returns the memory bandwidth allocation of one cache id in
+// percentage mode, before rounding it to the bandwidth granularity.
+func (s mbSchema) requestedPct(id uint64, base map[uint64]uint64) uint64 {
+	baseAllocation, ok := base[id]
+	if !ok {
+		baseAllocation = 100
+	}
+	allocation := uint64(100)
+	if s != nil {
+		allocation = s[id]
+	}
+	return allocation * baseAllocation / 100
+}
+
+// roundMBPct rounds a memory bandwidth percentage to the nearest multiple of
+// the bandwidth granularity of the hardware so that the value written to the
+// schemata is the one taking effect, without the kernel rounding it.
+func roundMBPct(value uint64) uint64 {
+	if gran := info.mb.bandwidthGran; gran > 1 {
+		value = (value + gran/2) / gran * gran
+		if value > 100 {
+			value = 100
+		}
+	}
+	// Guarantee minimum bw so that writing out the schemata does not fail
+	if value < info.mb.minBandwidth {
+		value = info.mb.minBandwidth
+	}
+	return value
 }
 
 // listStrToArray parses a string containing a human-readable list of numbers
@@ -513,76 +958,388 @@ func (c *Config) resolve() (config, error) {
 	}
 
 	conf.Classes, err = c.resolveClasses()
+	if err != nil {
+		return conf, err
+	}
+
+	if err := c.normalizeClassMB(conf.Classes); err != nil {
+		return conf, err
+	}
 
-	return conf, err
+	for _, lvl := range []CacheLevel{L2, L3} {
+		if !c.Options.cat(lvl).ReportUnused {
+			continue
+		}
+		unused, err := conf.unusedCatBits(lvl)
+		if err != nil {
+			return conf, err
+		}
+		for _, msg := range unused {
+			log.Infof("%s", msg)
+		}
+	}
+
+	return conf, nil
 }
 
-// resolvePartitions tries to resolve the requested resource allocations of
-// partitions
-func (c *Config) resolvePartitions() (partitionSet, error) {
-	// Initialize empty partition configuration
-	conf := make(partitionSet, len(c.Partitions))
-	for name := range c.Partitions {
-		conf[name] = &partitionConfig{
-			CAT: map[cacheLevel]catSchema{
-				L2: newCatSchema(L2),
-				L3: newCatSchema(L3),
-			},
-			MB: make(mbSchema, len(info.mb.cacheIds))}
+// freeCatRanges returns the contiguous blocks of cache ways of a cache id
+// that are not allocated to any partition, nor reserved for the root class.
+func (c config) freeCatRanges(lvl CacheLevel, id uint64) ([]bitmask, error) {
+	types := catSchemaTypes(lvl)
+	if len(types) == 0 {
+		return nil, fmt.Errorf("%s cache allocation not supported", lvl)
+	}
+	found := false
+	for _, i := range info.cat[lvl].cacheIds {
+		if i == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid %s cache id %d", lvl, id)
 	}
 
-	// Resolve L2 partition allocations
-	err := c.resolveCatPartitions(L2, conf)
+	used, err := c.Options.cat(lvl).rootReserveMask(lvl)
 	if err != nil {
 		return nil, err
 	}
+	for _, partition := range c.Partitions {
+		for _, typ := range types {
+			mask, err := catSchema{Lvl: lvl}.effectiveMask(id, typ, partition.CAT[lvl])
+			if err != nil {
+				return nil, err
+			}
+			used |= mask
+		}
+	}
 
-	// Try to resolve L3 partition allocations
-	err = c.resolveCatPartitions(L3, conf)
-	if err != nil {
-		return nil, err
+	return (info.cat[lvl].cbmMask() &^ used).ranges(), nil
+}
+
+// unusedCatBits returns a description of the cache bits that are allocated
+// to a partition but not used by any of its classes, per partition, cache id
+// and schema type.
+func (c config) unusedCatBits(lvl CacheLevel) ([]string, error) {
+	pnames := make([]string, 0, len(c.Partitions))
+	for name := range c.Partitions {
+		pnames = append(pnames, name)
 	}
+	sort.Strings(pnames)
 
-	// Try to resolve MB partition allocations
-	err = c.resolveMBPartitions(conf)
-	if err != nil {
-		return nil, err
+	cnames := make([]string, 0, len(c.Classes))
+	for name := range c.Classes {
+		cnames = append(cnames, name)
 	}
+	sort.Strings(cnames)
 
-	return conf, nil
-}
+	ids := append([]uint64{}, info.cat[lvl].cacheIds...)
+	utils.SortUint64s(ids)
 
-// resolveCatPartitions tries to resolve requested cache allocations between partitions
-func (c *Config) resolveCatPartitions(lvl cacheLevel, conf partitionSet) error {
-	if len(c.Partitions) == 0 {
-		return nil
+	ret := []string{}
+	for _, pname := range pnames {
+		pschema, ok := c.Partitions[pname].CAT[lvl]
+		if !ok || pschema.Alloc == nil {
+			continue
+		}
+		for _, id := range ids {
+			for _, typ := range catSchemaTypes(lvl) {
+				base, ok := pschema.Alloc[id].getEffective(typ).(catAbsoluteAllocation)
+				if !ok {
+					continue
+				}
+				used := bitmask(0)
+				for _, cname := range cnames {
+					class := c.Classes[cname]
+					if class.Partition != pname || class.MonitoringOnly {
+						continue
+					}
+					if class.CATSchema[lvl].Alloc == nil {
+						used |= bitmask(base)
+						continue
+					}
+					mask, err := class.CATSchema[lvl].effectiveMask(id, typ, pschema)
+					if err != nil {
+						return nil, err
+					}
+					used |= mask
+				}
+				if unused := bitmask(base) &^ used; unused != 0 {
+					msg := fmt.Sprintf("%s bits %#x of partition %q on cache id %d not used by any class (partition mask %#x, used by classes %#x)",
+						lvl, unused, pname, id, bitmask(base), used)
+					if typ != catSchemaTypeUnified {
+						msg = fmt.Sprintf("%s (%s)", msg, typ)
+					}
+					ret = append(ret, msg)
+				}
+			}
+		}
 	}
+	return ret, nil
+}
 
-	// Resolve partitions in sorted order for reproducibility
+// ValidateStatic checks the internal consistency of the configuration without
+// accessing the system, i.e. it does not require Initialize() to be called.
+// It checks the validity and uniqueness of class names, the syntax of all
+// allocation specs and that partition cache allocations do not overlap or
+// exceed 100%. Checks that depend on the hardware, like the number of cache
+// ids and bits available, are only done when the configuration is taken into
+// use with SetConfig().
+func (c *Config) ValidateStatic() error {
 	names := make([]string, 0, len(c.Partitions))
 	for name := range c.Partitions {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 
-	resolver := newCacheResolver(lvl, names)
-
-	// Parse requested allocations from user config and load the resolver
-	for _, name := range names {
-		var allocations catSchema
-		var err error
-		switch lvl {
-		case L2:
-			allocations, err = c.Partitions[name].L2Allocation.toSchema(L2)
-		case L3:
-			allocations, err = c.Partitions[name].L3Allocation.toSchema(L3)
+	for _, lvl := range []CacheLevel{L2, L3} {
+		if err := c.validateCatPartitions(lvl, names); err != nil {
+			return err
 		}
+	}
 
-		if err != nil {
-			return fmt.Errorf("failed to parse %s allocation request for partition %q: %v", lvl, name, err)
+	classes := make(map[string]struct{})
+	closids := make(map[uint64]string)
+	for _, bname := range names {
+		partition := c.Partitions[bname]
+		if err := partition.MBAllocation.validate(); err != nil {
+			return fmt.Errorf("failed to resolve MB allocation for partition %q: %v", bname, err)
 		}
 
-		resolver.requests[name] = allocations.Alloc
+		for gname, class := range partition.Classes {
+			gname, err := c.className(bname, gname)
+			if err != nil {
+				return err
+			}
+			if _, ok := classes[gname]; ok {
+				return fmt.Errorf("class names must be unique, %q defined multiple times", gname)
+			}
+			classes[gname] = struct{}{}
+
+			if class.MonitoringOnly && (class.L2Allocation != nil || class.L3Allocation != nil || class.MBAllocation != nil) {
+				return fmt.Errorf("allocation specified for monitoring-only class %q", gname)
+			}
+
+			if err := verifyClosidRequest(gname, class.Closid, closids); err != nil {
+				return err
+			}
+
+			for mgName := range class.MonitorGroups {
+				if !isQualifiedMonGroupName(mgName) {
+					return fmt.Errorf("invalid monitoring group name %q in class %q", mgName, gname)
+				}
+			}
+
+			if _, _, err := class.L2Allocation.parse(0); err != nil {
+				return fmt.Errorf("failed to resolve L2 allocation for class %q: %v", gname, err)
+			}
+			if class.L2Allocation != nil && partition.L2Allocation == nil {
+				return fmt.Errorf("L2 allocation missing from partition %q but class %q specifies L2 schema", bname, gname)
+			}
+
+			if _, _, err := class.L3Allocation.parse(0); err != nil {
+				return fmt.Errorf("failed to resolve L3 allocation for class %q: %v", gname, err)
+			}
+			if class.L3Allocation != nil && partition.L3Allocation == nil {
+				return fmt.Errorf("L3 allocation missing from partition %q but class %q specifies L3 schema", bname, gname)
+			}
+
+			if err := class.MBAllocation.validate(); err != nil {
+				return fmt.Errorf("failed to resolve MB allocation for class %q: %v", gname, err)
+			}
+			if class.MBAllocation != nil && partition.MBAllocation == nil {
+				return fmt.Errorf("MB allocation missing from partition %q but class %q specifies MB schema", bname, gname)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyClosidRequest checks the CLOSID requested for a class. The CLOSIDs
+// requested so far are recorded in closids.
+func verifyClosidRequest(name string, closid *uint64, closids map[uint64]string) error {
+	if closid == nil {
+		return nil
+	}
+	if isRootClass(name) {
+		return fmt.Errorf("CLOSID cannot be requested for the root class, it always has CLOSID 0")
+	}
+	if *closid == 0 {
+		return fmt.Errorf("CLOSID 0 requested for class %q is reserved for the root class", name)
+	}
+	if other, ok := closids[*closid]; ok {
+		return fmt.Errorf("CLOSID %d requested for both class %q and class %q", *closid, other, name)
+	}
+	closids[*closid] = name
+	return nil
+}
+
+// creationOrder returns the names of the classes in the order in which their
+// resctrl groups are to be created. Classes requesting a CLOSID come first,
+// in ascending order of the id, the rest sorted by name.
+func (s classSet) creationOrder() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := s[names[i]].Closid, s[names[j]].Closid
+		switch {
+		case a != nil && b != nil:
+			return *a < *b
+		case a != nil || b != nil:
+			return a != nil
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// validateCatPartitions checks the cache allocation requests of partitions
+// without information about the system. All cache ids explicitly specified
+// in the configuration are checked. The default allocations ("all") are
+// checked if specified for any partition.
+func (c *Config) validateCatPartitions(lvl CacheLevel, names []string) error {
+	explicit := make(map[string]catSchemaRaw, len(names))
+	defaults := make(map[string]catAllocation, len(names))
+	ids := utils.NewIDSet()
+	checkDefaults := false
+	shared := c.sharedPartitions(lvl, names)
+
+	for _, name := range names {
+		var cc CatConfig
+		switch lvl {
+		case L2:
+			cc = c.Partitions[name].L2Allocation
+		case L3:
+			cc = c.Partitions[name].L3Allocation
+		}
+		if cc == nil {
+			continue
+		}
+
+		var err error
+		explicit[name], defaults[name], err = cc.parse(0)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s allocation request for partition %q: %v", lvl, name, err)
+		}
+		for id := range explicit[name] {
+			ids.Add(utils.ID(id))
+		}
+		if _, ok := cc[CacheIdAll]; ok {
+			checkDefaults = true
+		}
+	}
+
+	check := func(id string, get func(name string) catAllocation) error {
+		for _, typ := range []catSchemaType{catSchemaTypeUnified, catSchemaTypeCode, catSchemaTypeData} {
+			reqs := make([]cacheAllocation, len(names))
+			for i, name := range names {
+				reqs[i] = get(name).get(typ)
+			}
+			if err := checkPartitionCatRequests(lvl, id, typ, names, shared, reqs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, id := range ids.SortedMembers() {
+		err := check(strconv.Itoa(int(id)), func(name string) catAllocation {
+			if a, ok := explicit[name][uint64(id)]; ok {
+				return a
+			}
+			return defaults[name]
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if checkDefaults {
+		err := check(CacheIdAll, func(name string) catAllocation { return defaults[name] })
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolvePartitions tries to resolve the requested resource allocations of
+// partitions
+func (c *Config) resolvePartitions() (partitionSet, error) {
+	// Initialize empty partition configuration
+	conf := make(partitionSet, len(c.Partitions))
+	for name := range c.Partitions {
+		conf[name] = &partitionConfig{
+			CAT: map[CacheLevel]catSchema{
+				L2: newCatSchema(L2),
+				L3: newCatSchema(L3),
+			},
+			MB: make(mbSchema, len(info.mb.cacheIds))}
+	}
+
+	// Resolve L2 partition allocations
+	err := c.resolveCatPartitions(L2, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try to resolve L3 partition allocations
+	err = c.resolveCatPartitions(L3, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try to resolve MB partition allocations
+	err = c.resolveMBPartitions(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// resolveCatPartitions tries to resolve requested cache allocations between partitions
+func (c *Config) resolveCatPartitions(lvl CacheLevel, conf partitionSet) error {
+	if len(c.Partitions) == 0 {
+		return nil
+	}
+
+	// Resolve partitions in sorted order for reproducibility
+	names := make([]string, 0, len(c.Partitions))
+	for name := range c.Partitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolver := newCacheResolver(lvl, names)
+	resolver.shared = c.sharedPartitions(lvl, names)
+	if len(catSchemaTypes(lvl)) > 0 {
+		reserve, err := c.Options.cat(lvl).rootReserveMask(lvl)
+		if err != nil {
+			return err
+		}
+		resolver.reserve = reserve
+	}
+
+	// Parse requested allocations from user config and load the resolver
+	for _, name := range names {
+		var allocations catSchema
+		var err error
+		switch lvl {
+		case L2:
+			allocations, err = c.Partitions[name].L2Allocation.toSchema(L2)
+		case L3:
+			allocations, err = c.Partitions[name].L3Allocation.toSchema(L3)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to parse %s allocation request for partition %q: %v", lvl, name, err)
+		}
+
+		resolver.requests[name] = allocations.Alloc
 	}
 
 	// Run resolver fo partition allocations
@@ -599,6 +1356,12 @@ func (c *Config) resolveCatPartitions(lvl cacheLevel, conf partitionSet) error {
 		conf[name].CAT[lvl] = grant
 	}
 
+	if opts := c.Options.cat(lvl); opts.CheckShareable {
+		if err := checkShareable(lvl, names, grants, opts.FailOnShareable); err != nil {
+			return err
+		}
+	}
+
 	heading := fmt.Sprintf("actual (and requested) %s allocations per partition and cache id:", lvl)
 	infoStr := ""
 	for name, partition := range resolver.requests {
@@ -629,18 +1392,55 @@ func (c *Config) resolveCatPartitions(lvl cacheLevel, conf partitionSet) error {
 	return nil
 }
 
+// checkShareable checks the granted partition allocations against the
+// shareable bits of the cache. Overlaps are logged as warnings, or returned
+// as an error if fail is set.
+func checkShareable(lvl CacheLevel, names []string, grants map[string]catSchema, fail bool) error {
+	shareable := info.cat[lvl].getInfo().shareableBits
+	if shareable == 0 {
+		return nil
+	}
+
+	errs := []error{}
+	for _, name := range names {
+		for _, id := range info.cat[lvl].cacheIds {
+			for _, typ := range catSchemaTypes(lvl) {
+				mask, ok := grants[name].Alloc[id].getEffective(typ).(catAbsoluteAllocation)
+				if !ok {
+					continue
+				}
+				if overlap := bitmask(mask) & shareable; overlap != 0 {
+					msg := fmt.Sprintf("%s allocation of partition %q on cache id %d overlaps shareable bits: %#x (partition mask %#x, shareable bits %#x)",
+						lvl, name, id, overlap, bitmask(mask), shareable)
+					if typ != catSchemaTypeUnified {
+						msg = fmt.Sprintf("%s (%s)", msg, typ)
+					}
+					if !fail {
+						log.Warnf("%s", msg)
+						continue
+					}
+					errs = append(errs, errors.New(msg))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // cacheResolver is a helper for resolving exclusive (partition) cache // allocation requests
 type cacheResolver struct {
-	lvl        cacheLevel
+	lvl        CacheLevel
 	ids        []uint64
 	minBits    uint64
 	bitsTotal  uint64
 	partitions []string
 	requests   map[string]catSchemaRaw
 	grants     map[string]catSchema
+	reserve    bitmask // bits reserved for the root class
+	shared     []bool  // partitions allowed to overlap, indexed like partitions
 }
 
-func newCacheResolver(lvl cacheLevel, partitions []string) *cacheResolver {
+func newCacheResolver(lvl CacheLevel, partitions []string) *cacheResolver {
 	r := &cacheResolver{
 		lvl:        lvl,
 		ids:        info.cat[lvl].cacheIds,
@@ -681,22 +1481,16 @@ func (r *cacheResolver) resolveID(id uint64) error {
 
 // resolveType resolve one schema type for one cache id
 func (r *cacheResolver) resolveType(id uint64, typ catSchemaType) error {
-	// Sanity check: if any partition has l3 allocation of this schema type
-	// configured check that all other partitions have it, too
-	nils := []string{}
-	for _, partition := range r.partitions {
-		if r.requests[partition][id].get(typ) == nil {
-			nils = append(nils, partition)
-		}
+	reqs := make([]cacheAllocation, len(r.partitions))
+	for i, partition := range r.partitions {
+		reqs[i] = r.requests[partition][id].get(typ)
 	}
-	if len(nils) > 0 && len(nils) != len(r.partitions) {
-		return fmt.Errorf("some partitions (%s) missing %s %q allocation request for cache id %d",
-			strings.Join(nils, ", "), r.lvl, typ, id)
+	if err := checkPartitionCatRequests(r.lvl, strconv.FormatUint(id, 10), typ, r.partitions, r.shared, reqs); err != nil {
+		return err
 	}
 
 	// Act depending on the type of the first request in the list
-	a := r.requests[r.partitions[0]][id].get(typ)
-	switch a.(type) {
+	switch reqs[0].(type) {
 	case catAbsoluteAllocation:
 		return r.resolveAbsolute(id, typ)
 	case nil:
@@ -706,36 +1500,116 @@ func (r *cacheResolver) resolveType(id uint64, typ catSchemaType) error {
 	return nil
 }
 
+// sharedPartitions returns the sharing flags of the named partitions for a
+// cache level. Only L3 allocations can be shared, nil is returned for other
+// levels.
+func (c *Config) sharedPartitions(lvl CacheLevel, names []string) []bool {
+	if lvl != L3 {
+		return nil
+	}
+	shared := make([]bool, len(names))
+	for i, name := range names {
+		shared[i] = c.Partitions[name].SharedL3
+	}
+	return shared
+}
+
+// checkPartitionCatRequests does a sanity check of the (exclusive) cache
+// allocation requests of partitions for one schema type of one cache id. The
+// requests must all be either absolute or relative, absolute requests must
+// not overlap and relative requests must not exceed 100% in total. Absolute
+// requests of partitions marked in shared may overlap with each other, but not
+// with the other partitions.
+func checkPartitionCatRequests(lvl CacheLevel, id string, typ catSchemaType, partitions []string, shared []bool, reqs []cacheAllocation) error {
+	// If any partition has allocation of this schema type configured check
+	// that all other partitions have it, too
+	nils := []string{}
+	for i, partition := range partitions {
+		if reqs[i] == nil {
+			nils = append(nils, partition)
+		}
+	}
+	if len(nils) > 0 {
+		if len(nils) != len(partitions) {
+			return fmt.Errorf("some partitions (%s) missing %s %q allocation request for cache id %s",
+				strings.Join(nils, ", "), lvl, typ, id)
+		}
+		return nil
+	}
+
+	// Act depending on the type of the first request in the list
+	switch reqs[0].(type) {
+	case catAbsoluteAllocation:
+		exclusiveMask := bitmask(0)
+		sharedMask := bitmask(0)
+		for i, req := range reqs {
+			a, ok := req.(catAbsoluteAllocation)
+			if !ok {
+				return fmt.Errorf("error resolving %s allocation for cache id %s: mixing absolute and relative allocations between partitions not supported", lvl, id)
+			}
+			isShared := shared != nil && shared[i]
+			mask := exclusiveMask
+			if !isShared {
+				mask |= sharedMask
+			}
+			if bitmask(a)&mask > 0 {
+				return fmt.Errorf("overlapping %s partition allocation requests for cache id %s", lvl, id)
+			}
+			if isShared {
+				sharedMask |= bitmask(a)
+			} else {
+				exclusiveMask |= bitmask(a)
+			}
+		}
+	default:
+		for i, partition := range partitions {
+			if shared != nil && shared[i] {
+				return fmt.Errorf("shared %s partition %q requires an absolute allocation", lvl, partition)
+			}
+		}
+		percentageTotal := uint64(0)
+		for _, req := range reqs {
+			switch a := req.(type) {
+			case catPctAllocation:
+				percentageTotal += uint64(a)
+			case catAbsoluteAllocation:
+				return fmt.Errorf("error resolving %s allocation for cache id %s: mixing "+
+					"relative and absolute allocations between partitions not supported", lvl, id)
+			case catPctRangeAllocation:
+				return fmt.Errorf("percentage ranges in partition allocation not supported")
+			case catExcludeAllocation:
+				return fmt.Errorf("exclude allocations in partition allocation not supported")
+			case catWaysAllocation, catFractionAllocation:
+				return fmt.Errorf("cache ways and fractions in partition allocation not supported")
+			default:
+				return fmt.Errorf("BUG: unknown cacheAllocation type %T", a)
+			}
+		}
+		if percentageTotal > 100 {
+			return fmt.Errorf("accumulated %s %q partition allocation requests for cache id %s exceeds 100%% (%d%%)", lvl, typ, id, percentageTotal)
+		}
+	}
+
+	return nil
+}
+
 func (r *cacheResolver) resolveRelative(id uint64, typ catSchemaType) error {
 	type reqHelper struct {
 		name string
 		req  uint64
 	}
 
-	// Sanity check:
-	// 1. allocation requests are of the same type (relative)
-	// 2. total allocation requested for this cache id does not exceed 100 percent
-	// Additionally fill a helper structure for sorting partitions
+	// Fill a helper structure for sorting partitions. The requests have
+	// already been checked by checkPartitionCatRequests()
 	percentageTotal := uint64(0)
 	reqs := make([]reqHelper, 0, len(r.partitions))
 	for _, partition := range r.partitions {
-		switch a := r.requests[partition][id].get(typ).(type) {
-		case catPctAllocation:
-			percentageTotal += uint64(a)
-			reqs = append(reqs, reqHelper{name: partition, req: uint64(a)})
-		case catAbsoluteAllocation:
-			return fmt.Errorf("error resolving %s allocation for cache id %d: mixing "+
-				"relative and absolute allocations between partitions not supported", r.lvl, id)
-		case catPctRangeAllocation:
-			return fmt.Errorf("percentage ranges in partition allocation not supported")
-		default:
-			return fmt.Errorf("BUG: unknown cacheAllocation type %T", a)
-		}
+		a := r.requests[partition][id].get(typ).(catPctAllocation)
+		percentageTotal += uint64(a)
+		reqs = append(reqs, reqHelper{name: partition, req: uint64(a)})
 	}
 	if percentageTotal < 100 {
 		log.Infof("requested total %s %q partition allocation for cache id %d <100%% (%d%%)", r.lvl, typ, id, percentageTotal)
-	} else if percentageTotal > 100 {
-		return fmt.Errorf("accumulated %s %q partition allocation requests for cache id %d exceeds 100%% (%d%%)", r.lvl, typ, id, percentageTotal)
 	}
 
 	// Sort partition allocations. We want to resolve smallest allocations
@@ -747,7 +1621,8 @@ func (r *cacheResolver) resolveRelative(id uint64, typ catSchemaType) error {
 
 	// Calculate number of bits granted to each partition.
 	grants := make(map[string]uint64, len(r.partitions))
-	bitsTotal := percentageTotal * uint64(r.bitsTotal) / 100
+	reserveBits := uint64(bits.OnesCount64(uint64(r.reserve)))
+	bitsTotal := percentageTotal * (r.bitsTotal - reserveBits) / 100
 	bitsAvailable := bitsTotal
 	for i, req := range reqs {
 		percentageAvailable := bitsAvailable * percentageTotal / bitsTotal
@@ -775,8 +1650,9 @@ func (r *cacheResolver) resolveRelative(id uint64, typ catSchemaType) error {
 		bitsAvailable -= numBits
 	}
 
-	// Construct the actual bitmasks for each partition
-	lsbID := uint64(0)
+	// Construct the actual bitmasks for each partition, above the reserved
+	// bits
+	lsbID := reserveBits
 	for _, partition := range r.partitions {
 		// Compose the actual bitmask
 		v := r.grants[partition].Alloc[id].set(typ, catAbsoluteAllocation(bitmask(((1<<grants[partition])-1)<<lsbID)))
@@ -789,20 +1665,12 @@ func (r *cacheResolver) resolveRelative(id uint64, typ catSchemaType) error {
 }
 
 func (r *cacheResolver) resolveAbsolute(id uint64, typ catSchemaType) error {
-	// Just sanity check:
-	// 1. allocation requests of the correct type (absolute)
-	// 2. allocations do not overlap
-	mask := bitmask(0)
+	// The requests have already been checked by checkPartitionCatRequests()
 	for _, partition := range r.partitions {
-		a, ok := r.requests[partition][id].get(typ).(catAbsoluteAllocation)
-		if !ok {
-			return fmt.Errorf("error resolving %s allocation for cache id %d: mixing absolute and relative allocations between partitions not supported", r.lvl, id)
-		}
-		if bitmask(a)&mask > 0 {
-			return fmt.Errorf("overlapping %s partition allocation requests for cache id %d", r.lvl, id)
+		a := r.requests[partition][id].get(typ).(catAbsoluteAllocation)
+		if bitmask(a)&r.reserve != 0 {
+			return fmt.Errorf("%s allocation %#x of partition %q for cache id %d overlaps the root reserve %#x", r.lvl, a, partition, id, r.reserve)
 		}
-		mask |= bitmask(a)
-
 		r.grants[partition].Alloc[id] = r.grants[partition].Alloc[id].set(typ, a)
 	}
 
@@ -813,7 +1681,12 @@ func (r *cacheResolver) resolveAbsolute(id uint64, typ catSchemaType) error {
 func (c *Config) resolveMBPartitions(conf partitionSet) error {
 	// We use percentage values directly from the user conf
 	for name, partition := range c.Partitions {
-		allocations, err := partition.MBAllocation.toSchema()
+		if c.Options.MB.Strict {
+			if err := partition.MBAllocation.checkStrict(c.Options.MB); err != nil {
+				return fmt.Errorf("invalid MB allocation for partition %q: %v", name, err)
+			}
+		}
+		allocations, err := partition.MBAllocation.toSchema(c.Options.MB)
 		if err != nil {
 			return fmt.Errorf("failed to resolve MB allocation for partition %q: %v", name, err)
 		}
@@ -832,22 +1705,45 @@ func (c *Config) resolveMBPartitions(conf partitionSet) error {
 // resolveClasses tries to resolve class allocations of all partitions
 func (c *Config) resolveClasses() (classSet, error) {
 	classes := make(classSet)
+	closids := make(map[uint64]string)
 
 	for bname, partition := range c.Partitions {
 		for gname, class := range partition.Classes {
-			gname = unaliasClassName(gname)
-
-			if !IsQualifiedClassName(gname) {
-				return classes, fmt.Errorf("unqualified class name %q (must not be '.' or '..' and must not contain '/' or newline)", gname)
+			gname, err := c.className(bname, gname)
+			if err != nil {
+				return classes, err
 			}
 			if _, ok := classes[gname]; ok {
 				return classes, fmt.Errorf("class names must be unique, %q defined multiple times", gname)
 			}
 
-			var err error
+			if err := verifyClosidRequest(gname, class.Closid, closids); err != nil {
+				return classes, err
+			}
+			if class.Closid != nil && info.numClosids > 0 && *class.Closid >= info.numClosids {
+				return classes, fmt.Errorf("CLOSID %d requested for class %q out of range (%d CLOSIDs available)", *class.Closid, gname, info.numClosids)
+			}
+			for mgName := range class.MonitorGroups {
+				if !isQualifiedMonGroupName(mgName) {
+					return classes, fmt.Errorf("invalid monitoring group name %q in class %q", mgName, gname)
+				}
+			}
+
 			gc := &classConfig{Partition: bname,
-				CATSchema:  make(map[cacheLevel]catSchema),
-				Kubernetes: class.Kubernetes}
+				CATSchema:      make(map[CacheLevel]catSchema),
+				Kubernetes:     class.Kubernetes,
+				Annotations:    class.Annotations,
+				MonitoringOnly: class.MonitoringOnly,
+				Closid:         class.Closid,
+				MonGroups:      class.MonitorGroups}
+
+			if class.MonitoringOnly {
+				if class.L2Allocation != nil || class.L3Allocation != nil || class.MBAllocation != nil {
+					return classes, fmt.Errorf("allocation specified for monitoring-only class %q", gname)
+				}
+				classes[gname] = gc
+				continue
+			}
 
 			gc.CATSchema[L2], err = class.L2Allocation.toSchema(L2)
 			if err != nil {
@@ -865,7 +1761,12 @@ func (c *Config) resolveClasses() (classSet, error) {
 				return classes, fmt.Errorf("L3 allocation missing from partition %q but class %q specifies L3 schema", bname, gname)
 			}
 
-			gc.MBSchema, err = class.MBAllocation.toSchema()
+			if c.Options.MB.Strict {
+				if err := class.MBAllocation.checkStrict(c.Options.MB); err != nil {
+					return classes, fmt.Errorf("invalid MB allocation for class %q: %v", gname, err)
+				}
+			}
+			gc.MBSchema, err = class.MBAllocation.toSchema(c.Options.MB)
 			if err != nil {
 				return classes, fmt.Errorf("failed to resolve MB allocation for class %q: %v", gname, err)
 			}
@@ -880,29 +1781,119 @@ func (c *Config) resolveClasses() (classSet, error) {
 	return classes, nil
 }
 
+// className returns the effective name of a class in a partition, verifying
+// that the name is valid
+func (c *Config) className(partition, class string) (string, error) {
+	name := unaliasClassName(class)
+
+	if !IsQualifiedClassName(name) {
+		return "", fmt.Errorf("unqualified class name %q (must not be '.' or '..' and must not contain '/' or newline)", name)
+	}
+	if c.Options.NamespaceClasses && !isRootClass(name) {
+		if partition == "" || !IsQualifiedClassName(partition) {
+			return "", fmt.Errorf("unqualified partition name %q, must be usable as a class namespace (must not be empty, '.' or '..' and must not contain '/' or newline)", partition)
+		}
+		name = namespacedClassName(partition, name)
+		if isRootClass(name) {
+			return "", fmt.Errorf("namespaced class name %q conflicts with the root class", name)
+		}
+	}
+	return name, nil
+}
+
+// normalizeClassMB scales the MB allocations of classes in partitions that
+// have MBProportional set, so that they divide the MB allocation of the
+// partition in proportion to the requested percentages. Classes without an MB
+// allocation count as 100%. Only percentage based MBA is affected.
+func (c *Config) normalizeClassMB(classes classSet) error {
+	for bname, partition := range c.Partitions {
+		if !partition.MBProportional && !c.Options.MB.Proportional {
+			continue
+		}
+		if info.mb.mbpsEnabled {
+			if c.Options.MB.Strict {
+				return fmt.Errorf("proportional MB allocation of partition %q not supported with MBps based memory bandwidth allocation", bname)
+			}
+			log.Infof("mbProportional of partition %q has no effect with MBps based memory bandwidth allocation", bname)
+			continue
+		}
+
+		members := []*classConfig{}
+		for _, class := range classes {
+			if class.Partition == bname && !class.MonitoringOnly {
+				members = append(members, class)
+			}
+		}
+
+		for _, id := range info.mb.cacheIds {
+			total := uint64(0)
+			for _, class := range members {
+				if class.MBSchema != nil {
+					total += class.MBSchema[id]
+				} else {
+					total += 100
+				}
+			}
+			if total == 0 {
+				continue
+			}
+
+			for _, class := range members {
+				if class.MBSchema == nil {
+					class.MBSchema = make(mbSchema, len(info.mb.cacheIds))
+					for _, i := range info.mb.cacheIds {
+						class.MBSchema[i] = 100
+					}
+				}
+				share := class.MBSchema[id] * 100 / total
+				if share == 0 {
+					share = 1
+				}
+				class.MBSchema[id] = share
+			}
+		}
+	}
+	return nil
+}
+
 // toSchema converts a cache allocation config to effective allocation schema covering all cache IDs
-func (c CatConfig) toSchema(lvl cacheLevel) (catSchema, error) {
+func (c CatConfig) toSchema(lvl CacheLevel) (catSchema, error) {
 	if c == nil {
 		return catSchema{Lvl: lvl}, nil
 	}
 
 	allocations := newCatSchema(lvl)
-	minBits := info.cat[lvl].minCbmBits()
 
+	explicit, defaultVal, err := c.parse(info.cat[lvl].minCbmBits())
+	if err != nil {
+		return allocations, err
+	}
+
+	for _, i := range info.cat[lvl].cacheIds {
+		if a, ok := explicit[i]; ok {
+			allocations.Alloc[i] = a
+		} else {
+			allocations.Alloc[i] = defaultVal
+		}
+	}
+
+	return allocations, nil
+}
+
+// parse parses a cache allocation config without information about the cache
+// ids present in the system. It returns the allocations of the explicitly
+// specified cache ids and the default allocation for all the rest.
+func (c CatConfig) parse(minBits uint64) (catSchemaRaw, catAllocation, error) {
 	d, ok := c[CacheIdAll]
 	if !ok {
 		d = CacheIdCatConfig{Unified: "100%"}
 	}
 	defaultVal, err := d.parse(minBits)
 	if err != nil {
-		return allocations, err
-	}
-
-	// Pre-fill with defaults
-	for _, i := range info.cat[lvl].cacheIds {
-		allocations.Alloc[i] = defaultVal
+		return nil, defaultVal, err
 	}
 
+	explicit := make(catSchemaRaw)
 	for key, val := range c {
 		if key == CacheIdAll {
 			continue
@@ -910,27 +1901,277 @@ func (c CatConfig) toSchema(lvl cacheLevel) (catSchema, error) {
 
 		ids, err := listStrToArray(key)
 		if err != nil {
-			return allocations, err
+			return nil, defaultVal, err
 		}
 
 		schemaVal, err := val.parse(minBits)
 		if err != nil {
-			return allocations, err
+			return nil, defaultVal, err
 		}
 
 		for _, id := range ids {
-			if _, ok := allocations.Alloc[uint64(id)]; ok {
-				allocations.Alloc[uint64(id)] = schemaVal
-			}
+			explicit[uint64(id)] = schemaVal
 		}
 	}
 
-	return allocations, nil
+	return explicit, defaultVal, nil
 }
 
 // catConfig is a helper for unmarshalling CatConfig
 type catConfig CatConfig
 
+// legacyConfigKeys maps legacy partition and class level field names to the
+// current ones.
+var legacyConfigKeys = []struct{ legacy, current string }{
+	{"l2Schema", "l2Allocation"},
+	{"l3Schema", "l3Allocation"},
+	{"mbSchema", "mbAllocation"},
+}
+
+// MigrateConfig converts raw configuration data in an older format to the
+// current one. Legacy field names are renamed to the canonical ones and the
+// version is set to ConfigVersion. The changes made are logged. The result is
+// verified to parse as a valid configuration.
+func MigrateConfig(data []byte) ([]byte, error) {
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration data: %v", err)
+	}
+
+	if v, ok := raw["version"]; ok && v != nil {
+		version, ok := v.(float64)
+		if !ok || version != float64(int(version)) {
+			return nil, fmt.Errorf("invalid configuration version %v", v)
+		}
+		if int(version) > ConfigVersion {
+			return nil, fmt.Errorf("unsupported configuration version %d (latest supported is %d)", int(version), ConfigVersion)
+		}
+	}
+
+	partitions, err := migrateConfigMap(raw["partitions"], "partitions")
+	if err != nil {
+		return nil, err
+	}
+	for pName, p := range partitions {
+		path := "partitions." + pName
+		partition, err := migrateConfigMap(p, path)
+		if err != nil {
+			return nil, err
+		}
+		if err := migrateLegacyKeys(partition, path); err != nil {
+			return nil, err
+		}
+		classes, err := migrateConfigMap(partition["classes"], path+".classes")
+		if err != nil {
+			return nil, err
+		}
+		for cName, c := range classes {
+			class, err := migrateConfigMap(c, path+".classes."+cName)
+			if err != nil {
+				return nil, err
+			}
+			if err := migrateLegacyKeys(class, path+".classes."+cName); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if v, ok := raw["version"]; !ok || v != float64(ConfigVersion) {
+		log.Infof("config migration: setting version to %d", ConfigVersion)
+		raw["version"] = ConfigVersion
+	}
+
+	out, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal migrated configuration: %v", err)
+	}
+	if _, err := parseConfigData(out); err != nil {
+		return nil, fmt.Errorf("migrated configuration is invalid: %v", err)
+	}
+	return out, nil
+}
+
+// migrateConfigMap is a helper for type-asserting a (possibly empty) map in
+// raw configuration data.
+func migrateConfigMap(v interface{}, path string) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid configuration data at %q: expected a map, got %T", path, v)
+	}
+	return m, nil
+}
+
+// migrateLegacyKeys renames legacy fields in one partition or class.
+func migrateLegacyKeys(m map[string]interface{}, path string) error {
+	for _, k := range legacyConfigKeys {
+		legacy, current := k.legacy, k.current
+		v, ok := m[legacy]
+		if !ok {
+			continue
+		}
+		if _, ok := m[current]; ok {
+			return fmt.Errorf("both %q and %q specified in %q", legacy, current, path)
+		}
+		log.Infof("config migration: renaming %s.%s to %s.%s", path, legacy, path, current)
+		m[current] = v
+		delete(m, legacy)
+	}
+	return nil
+}
+
+// mergeConfigData merges multiple raw configurations into one, later ones
+// overriding the earlier ones.
+func mergeConfigData(data ...[]byte) (*Config, error) {
+	merged := map[string]interface{}{}
+	for _, d := range data {
+		raw := map[string]interface{}{}
+		if err := yaml.Unmarshal(d, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration data: %v", err)
+		}
+		mergeRawConfig(merged, raw)
+	}
+
+	d, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal merged configuration: %v", err)
+	}
+	return parseConfigData(d)
+}
+
+// deepCopy returns a deep copy of the configuration.
+func (c *Config) deepCopy() *Config {
+	cp := *c
+	if c.Partitions != nil {
+		cp.Partitions = make(map[string]PartitionConfig, len(c.Partitions))
+		for name, p := range c.Partitions {
+			cp.Partitions[name] = p.deepCopy()
+		}
+	}
+	return &cp
+}
+
+// deepCopy returns a deep copy of the partition configuration.
+func (p PartitionConfig) deepCopy() PartitionConfig {
+	p.L2Allocation = p.L2Allocation.deepCopy()
+	p.L3Allocation = p.L3Allocation.deepCopy()
+	p.MBAllocation = p.MBAllocation.deepCopy()
+	if p.Classes != nil {
+		classes := make(map[string]ClassConfig, len(p.Classes))
+		for name, c := range p.Classes {
+			classes[name] = c.deepCopy()
+		}
+		p.Classes = classes
+	}
+	return p
+}
+
+// deepCopy returns a deep copy of the class configuration.
+func (c ClassConfig) deepCopy() ClassConfig {
+	c.L2Allocation = c.L2Allocation.deepCopy()
+	c.L3Allocation = c.L3Allocation.deepCopy()
+	c.MBAllocation = c.MBAllocation.deepCopy()
+	c.Annotations = copyAnnotations(c.Annotations)
+	if c.Closid != nil {
+		closid := *c.Closid
+		c.Closid = &closid
+	}
+	if c.MonitorGroups != nil {
+		groups := make(map[string]MonitorGroupOptions, len(c.MonitorGroups))
+		for name, g := range c.MonitorGroups {
+			groups[name] = MonitorGroupOptions{Annotations: copyAnnotations(g.Annotations)}
+		}
+		c.MonitorGroups = groups
+	}
+	return c
+}
+
+// deepCopy returns a deep copy of the cache allocation configuration.
+func (c CatConfig) deepCopy() CatConfig {
+	if c == nil {
+		return nil
+	}
+	cp := make(CatConfig, len(c))
+	for id, v := range c {
+		cp[id] = v
+	}
+	return cp
+}
+
+// deepCopy returns a deep copy of the memory bandwidth configuration.
+func (c MbaConfig) deepCopy() MbaConfig {
+	if c == nil {
+		return nil
+	}
+	cp := make(MbaConfig, len(c))
+	for id, v := range c {
+		cp[id] = append(CacheIdMbaConfig(nil), v...)
+	}
+	return cp
+}
+
+// copyAnnotations returns a copy of an annotation map.
+func copyAnnotations(a map[string]string) map[string]string {
+	if a == nil {
+		return nil
+	}
+	cp := make(map[string]string, len(a))
+	for k, v := range a {
+		cp[k] = v
+	}
+	return cp
+}
+
+// mergeRawConfig recursively merges src into dst. Allocations are merged
+// per cache id, other non-map values replace the old value.
+func mergeRawConfig(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if v == nil {
+			if _, ok := dst[k]; !ok {
+				dst[k] = nil
+			}
+			continue
+		}
+
+		switch k {
+		case "l2Allocation", "l3Allocation", "mbAllocation":
+			alloc := rawAllocation(dst[k])
+			for id, a := range rawAllocation(v) {
+				alloc[id] = a
+			}
+			dst[k] = alloc
+			continue
+		}
+
+		srcMap, srcOk := v.(map[string]interface{})
+		dstMap, dstOk := dst[k].(map[string]interface{})
+		if srcOk && dstOk {
+			mergeRawConfig(dstMap, srcMap)
+		} else {
+			dst[k] = v
+		}
+	}
+}
+
+// rawAllocation converts a raw cache or memory bandwidth allocation into the
+// per-cache id form
+func rawAllocation(v interface{}) map[string]interface{} {
+	ret := map[string]interface{}{}
+	switch a := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for id, alloc := range a {
+			ret[id] = alloc
+		}
+	default:
+		// Short form (a single string or list) applies to all cache ids
+		ret[CacheIdAll] = a
+	}
+	return ret
+}
+
 // UnmarshalJSON implements the Unmarshaler interface of "encoding/json"
 func (c *CatConfig) UnmarshalJSON(data []byte) error {
 	raw := new(interface{})
@@ -959,7 +2200,7 @@ func (c *CatConfig) UnmarshalJSON(data []byte) error {
 }
 
 // toSchema converts an MB allocation config to effective allocation schema covering all cache IDs
-func (c MbaConfig) toSchema() (mbSchema, error) {
+func (c MbaConfig) toSchema(opts MbOptions) (mbSchema, error) {
 	if c == nil {
 		return nil, nil
 	}
@@ -968,7 +2209,7 @@ func (c MbaConfig) toSchema() (mbSchema, error) {
 	if !ok {
 		d = CacheIdMbaConfig{"100" + mbSuffixPct, "4294967295" + mbSuffixMbps}
 	}
-	defaultVal, err := d.parse()
+	defaultVal, err := d.parse(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -989,7 +2230,7 @@ func (c MbaConfig) toSchema() (mbSchema, error) {
 			return nil, err
 		}
 
-		schemaVal, err := val.parse()
+		schemaVal, err := val.parse(opts)
 		if err != nil {
 			return nil, err
 		}
@@ -1004,6 +2245,22 @@ func (c MbaConfig) toSchema() (mbSchema, error) {
 	return allocations, nil
 }
 
+// validate checks the syntax of an MB allocation config without information
+// about the system
+func (c MbaConfig) validate() error {
+	for key, val := range c {
+		if key != CacheIdAll {
+			if _, err := listStrToArray(key); err != nil {
+				return err
+			}
+		}
+		if err := val.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // mbaConfig is a helper for unmarshalling MbaConfig
 type mbaConfig MbaConfig
 
@@ -1103,7 +2360,8 @@ func (c *CacheIdCatConfig) UnmarshalJSON(data []byte) error {
 
 // parse converts a per cache-id MBA configuration into effective value
 // to be used in the MBA schema
-func (c *CacheIdMbaConfig) parse() (uint64, error) {
+func (c *CacheIdMbaConfig) parse(opts MbOptions) (uint64, error) {
+	inactive := MbProportion("")
 	for _, v := range *c {
 		str := string(v)
 		if strings.HasSuffix(str, mbSuffixPct) {
@@ -1124,14 +2382,123 @@ func (c *CacheIdMbaConfig) parse() (uint64, error) {
 			}
 		} else {
 			log.Warnf("unrecognized MBA allocation unit in %q", str)
+			continue
+		}
+		if inactive == "" {
+			inactive = v
 		}
 	}
 
 	// No value for the active mode was specified
+	var err error
 	if info.mb.mbpsEnabled {
-		return 0, fmt.Errorf("missing 'MBps' value from mbSchema; required because 'mba_MBps' is enabled in the system")
+		err = fmt.Errorf("missing 'MBps' value from mbSchema; required because 'mba_MBps' is enabled in the system")
+	} else {
+		err = fmt.Errorf("missing '%%' value from mbSchema; required because percentage-based MBA allocation is enabled in the system")
+	}
+	if opts.ConvertUnits && inactive != "" {
+		if opts.MaxBandwidthMBps == 0 {
+			return 0, fmt.Errorf("%v (cannot convert %q, maxBandwidthMBps not set)", err, inactive)
+		}
+		return inactive.convert(opts.MaxBandwidthMBps)
+	}
+	return 0, err
+}
+
+// convert converts an MB allocation value to the unit of the active MBA mode,
+// i.e. a '%' value to MBps or an MBps value to '%', max being the bandwidth
+// in MBps corresponding to 100%.
+func (p MbProportion) convert(max uint64) (uint64, error) {
+	str := string(p)
+	if strings.HasSuffix(str, mbSuffixPct) {
+		pct, err := strconv.ParseUint(strings.TrimSuffix(str, mbSuffixPct), 10, 7)
+		if err != nil {
+			return 0, err
+		}
+		mbps := pct * max / 100
+		log.Debugf("converted MB allocation %q to %dMBps", str, mbps)
+		return mbps, nil
+	}
+
+	mbps, err := strconv.ParseUint(strings.TrimSuffix(str, mbSuffixMbps), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	pct := (mbps*100 + max/2) / max
+	if pct > 100 {
+		pct = 100
+	}
+	log.Debugf("converted MB allocation %q to %d%%", str, pct)
+	return pct, nil
+}
+
+// checkStrict checks that the MBA configuration has exactly one value for
+// the active MBA mode (percentage or MBps) for each cache id, and no values
+// that would be ignored. With unit conversion enabled a lone value for the
+// inactive mode is accepted, as it is converted instead of ignored.
+func (c MbaConfig) checkStrict(opts MbOptions) error {
+	ids := make([]string, 0, len(c))
+	for id := range c {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := c[id].checkStrict(opts); err != nil {
+			return fmt.Errorf("cache id %q: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (c CacheIdMbaConfig) checkStrict(opts MbOptions) error {
+	pct, mbps := 0, 0
+	for _, v := range c {
+		str := string(v)
+		switch {
+		case strings.HasSuffix(str, mbSuffixPct):
+			pct++
+		case strings.HasSuffix(str, mbSuffixMbps):
+			mbps++
+		default:
+			return fmt.Errorf("unrecognized MBA allocation unit in %q", str)
+		}
+	}
+
+	switch {
+	case pct > 1:
+		return fmt.Errorf("multiple '%%' values in %v", c)
+	case mbps > 1:
+		return fmt.Errorf("multiple 'MBps' values in %v", c)
+	case opts.ConvertUnits && (pct == 0 || mbps == 0):
+		// A lone value of either unit is converted if needed
+	case info.mb.mbpsEnabled && pct > 0:
+		return fmt.Errorf("'%%' value in %v has no effect because 'mba_MBps' is enabled in the system", c)
+	case !info.mb.mbpsEnabled && mbps > 0:
+		return fmt.Errorf("'MBps' value in %v has no effect because percentage-based MBA allocation is enabled in the system", c)
 	}
-	return 0, fmt.Errorf("missing '%%' value from mbSchema; required because percentage-based MBA allocation is enabled in the system")
+	return nil
+}
+
+// validate checks the syntax of a per cache-id MBA configuration without
+// information about the MBA mode (percentage or MBps) used in the system
+func (c *CacheIdMbaConfig) validate() error {
+	if len(*c) == 0 {
+		return fmt.Errorf("no value specified in mbSchema")
+	}
+	for _, v := range *c {
+		str := string(v)
+		if strings.HasSuffix(str, mbSuffixPct) {
+			if _, err := strconv.ParseUint(strings.TrimSuffix(str, mbSuffixPct), 10, 7); err != nil {
+				return err
+			}
+		} else if strings.HasSuffix(str, mbSuffixMbps) {
+			if _, err := strconv.ParseUint(strings.TrimSuffix(str, mbSuffixMbps), 10, 32); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // parse converts a string value into cacheAllocation type
@@ -1172,6 +2539,46 @@ func (c CacheProportion) parse(minBits uint64) (cacheAllocation, error) {
 		return allocation, nil
 	}
 
+	// Fractions of the available ways
+	if a, ok := cacheFractions[string(c)]; ok {
+		return a, nil
+	}
+
+	// Number of ways
+	if strings.HasPrefix(string(c), "ways:") {
+		n, err := strconv.ParseUint(strings.TrimPrefix(string(c), "ways:"), 10, 7)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number of cache ways in %q: %v", c, err)
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("invalid number of cache ways in %q: must be non-zero", c)
+		}
+		if n < minBits {
+			return nil, fmt.Errorf("invalid number of cache ways in %q: number of ways less than %d", c, minBits)
+		}
+		return catWaysAllocation(n), nil
+	}
+
+	// Exclude allocation, i.e. everything but the given bits
+	if c[0] == '^' {
+		if len(c) == 1 {
+			return nil, fmt.Errorf("invalid cache allocation %q: no bits to exclude", c)
+		}
+		var value uint64
+		var err error
+		if strings.HasPrefix(string(c[1:]), "0x") {
+			value, err = strconv.ParseUint(string(c[3:]), 16, 64)
+		} else {
+			var tmp bitmask
+			tmp, err = listStrToBitmask(string(c[1:]))
+			value = uint64(tmp)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return catExcludeAllocation(value), nil
+	}
+
 	// Absolute allocation
 	var value uint64
 	var err error
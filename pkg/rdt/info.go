@@ -18,31 +18,46 @@ package rdt
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+
+	goresctrlpath "github.com/intel/goresctrl/pkg/path"
+	"github.com/intel/goresctrl/pkg/utils"
 )
 
 // resctrlInfo contains information about the RDT support in the system
 type resctrlInfo struct {
 	resctrlPath      string
 	resctrlMountOpts map[string]struct{}
+	resctrlRoot      string // overridden mount point, see InitializeWithRoot()
+	mountInfoFile    string // mount info the resctrl mount was detected from
 	numClosids       uint64
-	cat              map[cacheLevel]catInfoAll
+	cat              map[CacheLevel]catInfoAll
 	l3mon            l3MonInfo
 	mb               mbInfo
 }
 
-type cacheLevel string
+// CacheLevel identifies a cache level that supports cache allocation.
+type CacheLevel string
 
 const (
-	L2 cacheLevel = "L2"
-	L3 cacheLevel = "L3"
+	// L2 is the level 2 cache.
+	L2 CacheLevel = "L2"
+	// L3 is the level 3 cache (LLC).
+	L3 CacheLevel = "L3"
 )
 
+// String returns the name of the cache level, as used in the resctrl
+// filesystem.
+func (l CacheLevel) String() string {
+	return string(l)
+}
+
 type catInfoAll struct {
 	cacheIds []uint64
 	unified  catInfo
@@ -69,6 +84,8 @@ type mbInfo struct {
 	mbpsEnabled   bool // true if MBA_MBps is enabled
 }
 
+const sysfsNodeBasepath = "sys/devices/system/node"
+
 var mountInfoPath string = "/proc/mounts"
 
 // getInfo is a helper method for a "unified API" for getting L3 information
@@ -94,11 +111,19 @@ func (i catInfoAll) minCbmBits() uint64 {
 	return i.getInfo().minCbmBits
 }
 
-func getRdtInfo() (*resctrlInfo, error) {
+// getRdtInfo detects the RDT support of the system. A non-empty root
+// overrides the resctrl mount point detected from mountInfoFile. An empty
+// mountInfoFile means the default mount info, or no mount options at all if
+// root is given.
+func getRdtInfo(root, mountInfoFile string) (*resctrlInfo, error) {
 	var err error
-	info := &resctrlInfo{cat: make(map[cacheLevel]catInfoAll)}
+	info := &resctrlInfo{
+		cat:           make(map[CacheLevel]catInfoAll),
+		resctrlRoot:   root,
+		mountInfoFile: mountInfoFile,
+	}
 
-	info.resctrlPath, info.resctrlMountOpts, err = getResctrlMountInfo()
+	info.resctrlPath, info.resctrlMountOpts, err = getResctrlMountInfo(root, mountInfoFile)
 	if err != nil {
 		return info, fmt.Errorf("failed to detect resctrl mount point: %v", err)
 	}
@@ -111,7 +136,7 @@ func getRdtInfo() (*resctrlInfo, error) {
 	}
 
 	// Check CAT feature available
-	for _, cl := range []cacheLevel{L2, L3} {
+	for _, cl := range []CacheLevel{L2, L3} {
 		cat := catInfoAll{}
 		catFeatures := map[string]*catInfo{
 			"":     &cat.unified,
@@ -158,6 +183,12 @@ func getRdtInfo() (*resctrlInfo, error) {
 		if err != nil {
 			return info, fmt.Errorf("failed to get MBA cache IDs: %v", err)
 		}
+
+		// Detect MBps mode directly from mount options as it's not visible
+		// in MB info directory
+		if _, ok := info.resctrlMountOpts["mba_MBps"]; ok {
+			info.mb.mbpsEnabled = true
+		}
 	}
 
 	return info, nil
@@ -239,16 +270,6 @@ func getMBInfo(basepath string) (mbInfo, uint64, error) {
 		return info, numClosids, err
 	}
 
-	// Detect MBps mode directly from mount options as it's not visible in MB
-	// info directory
-	_, mountOpts, err := getResctrlMountInfo()
-	if err != nil {
-		return info, numClosids, fmt.Errorf("failed to get resctrl mount options: %v", err)
-	}
-	if _, ok := mountOpts["mba_MBps"]; ok {
-		info.mbpsEnabled = true
-	}
-
 	return info, numClosids, nil
 }
 
@@ -292,10 +313,140 @@ func getCacheIds(basepath string, prefix string) ([]uint64, error) {
 	return ids, fmt.Errorf("no %s resources in root schemata", prefix)
 }
 
-func getResctrlMountInfo() (string, map[string]struct{}, error) {
+// CacheIDForCPU returns the id of the cache of the given level that a CPU
+// uses. The returned id corresponds to the cache ids used in the resctrl
+// schemata and in the configuration. The information is read from the cache
+// topology of the CPU in sysfs.
+func CacheIDForCPU(lvl CacheLevel, cpu utils.ID) (uint64, error) {
+	basepath := goresctrlpath.Path(utils.SysfsCpuBasepath, fmt.Sprintf("cpu%d", cpu), "cache")
+
+	dirs, err := os.ReadDir(basepath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache topology of cpu %d: %v", cpu, err)
+	}
+
+	for _, dir := range dirs {
+		if !strings.HasPrefix(dir.Name(), "index") {
+			continue
+		}
+		path := filepath.Join(basepath, dir.Name())
+
+		level, err := readFileString(filepath.Join(path, "level"))
+		if err != nil {
+			return 0, err
+		}
+		if "L"+level != string(lvl) {
+			continue
+		}
+
+		// Instruction caches are not controllable via resctrl
+		typ, err := readFileString(filepath.Join(path, "type"))
+		if err != nil {
+			return 0, err
+		}
+		if typ == "Instruction" {
+			continue
+		}
+
+		return readFileUint64(filepath.Join(path, "id"))
+	}
+	return 0, fmt.Errorf("no %s cache found for cpu %d", lvl, cpu)
+}
+
+// getMonL3Packages returns a mapping from the cache ids of the L3 monitoring
+// data to physical package ids. With Sub-NUMA Clustering (SNC) enabled,
+// multiple NUMA nodes share one L3 cache and resctrl reports L3 monitoring
+// data per NUMA node, i.e. the cache ids are NUMA node ids. Otherwise, the
+// cache ids are L3 cache ids. The topology is read from sysfs.
+func getMonL3Packages() (map[uint64]uint64, error) {
+	basepath := goresctrlpath.Path(sysfsNodeBasepath)
+
+	dirs, err := os.ReadDir(basepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NUMA topology: %v", err)
+	}
+
+	nodePkgs := map[uint64]uint64{}
+	l3Pkgs := map[uint64]uint64{}
+	l3Nodes := map[uint64]int{}
+	snc := false
+	for _, dir := range dirs {
+		if !strings.HasPrefix(dir.Name(), "node") {
+			continue
+		}
+		node, err := strconv.ParseUint(strings.TrimPrefix(dir.Name(), "node"), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		cpu, ok, err := getFirstNodeCPU(filepath.Join(basepath, dir.Name()))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			// Memory-only node
+			continue
+		}
+
+		pkg, err := readFileUint64(goresctrlpath.Path(utils.SysfsCpuBasepath, fmt.Sprintf("cpu%d", cpu), "topology", "physical_package_id"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read package id of cpu %d: %v", cpu, err)
+		}
+		l3, err := CacheIDForCPU(L3, cpu)
+		if err != nil {
+			return nil, err
+		}
+
+		nodePkgs[node] = pkg
+		l3Pkgs[l3] = pkg
+		l3Nodes[l3]++
+		if l3Nodes[l3] > 1 {
+			snc = true
+		}
+	}
+
+	if snc {
+		return nodePkgs, nil
+	}
+	return l3Pkgs, nil
+}
+
+// getFirstNodeCPU returns the lowest numbered cpu of a NUMA node.
+func getFirstNodeCPU(nodepath string) (utils.ID, bool, error) {
+	entries, err := os.ReadDir(nodepath)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read NUMA node topology: %v", err)
+	}
+
+	first, found := utils.ID(0), false
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "cpu") {
+			continue
+		}
+		cpu, err := strconv.Atoi(strings.TrimPrefix(e.Name(), "cpu"))
+		if err != nil {
+			// Skip other entries like cpulist and cpumap
+			continue
+		}
+		if !found || cpu < first {
+			first, found = cpu, true
+		}
+	}
+	return first, found, nil
+}
+
+func getResctrlMountInfo(root, mountInfoFile string) (string, map[string]struct{}, error) {
 	mountOptions := map[string]struct{}{}
 
-	f, err := os.Open(mountInfoPath)
+	// With an overridden root the mount info is optional and only used for
+	// getting the mount options
+	if mountInfoFile == "" {
+		if root != "" {
+			return root, mountOptions, nil
+		}
+		mountInfoFile = mountInfoPath
+	}
+
+	f, err := os.Open(mountInfoFile)
 	if err != nil {
 		return "", mountOptions, err
 	}
@@ -309,10 +460,47 @@ func getResctrlMountInfo() (string, map[string]struct{}, error) {
 			for _, opt := range opts {
 				mountOptions[opt] = struct{}{}
 			}
+			if root != "" {
+				return root, mountOptions, nil
+			}
 			return split[1], mountOptions, nil
 		}
 	}
-	return "", mountOptions, fmt.Errorf("resctrl not found in " + mountInfoPath)
+	if root != "" {
+		return root, mountOptions, nil
+	}
+	return "", mountOptions, fmt.Errorf("resctrl not found in " + mountInfoFile)
+}
+
+// checkResctrlMount checks that the resctrl filesystem is still mounted in
+// the same location and with the same options as detected in Initialize().
+// Mount options like "cdp" and "mba_MBps" change the resctrl interface so
+// the cached info would be stale.
+func checkResctrlMount() error {
+	path, opts, err := getResctrlMountInfo(info.resctrlRoot, info.mountInfoFile)
+	if err != nil {
+		return fmt.Errorf("failed to get resctrl mount info: %v", err)
+	}
+
+	if path != info.resctrlPath {
+		return fmt.Errorf("%w: mount point changed from %q to %q", ErrResctrlRemounted, info.resctrlPath, path)
+	}
+
+	oldOpts, newOpts := mountOptsStr(info.resctrlMountOpts), mountOptsStr(opts)
+	if oldOpts != newOpts {
+		return fmt.Errorf("%w: mount options changed from %q to %q", ErrResctrlRemounted, oldOpts, newOpts)
+	}
+
+	return nil
+}
+
+func mountOptsStr(opts map[string]struct{}) string {
+	s := make([]string, 0, len(opts))
+	for o := range opts {
+		s = append(s, o)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
 }
 
 func readFileUint64(path string) (uint64, error) {
@@ -338,3 +526,203 @@ func readFileString(path string) (string, error) {
 	data, err := os.ReadFile(path)
 	return strings.TrimSpace(string(data)), err
 }
+
+// InfoSnapshot is a serializable copy of the RDT capabilities of a system,
+// see DumpInfo() and LoadInfo().
+type InfoSnapshot struct {
+	ResctrlPath      string                     `json:"resctrlPath,omitempty"`
+	ResctrlMountOpts []string                   `json:"resctrlMountOpts,omitempty"`
+	NumClosids       uint64                     `json:"numClosids"`
+	Cat              map[string]CatInfoSnapshot `json:"cat,omitempty"`
+	L3Mon            *L3MonInfoSnapshot         `json:"l3Mon,omitempty"`
+	MB               *MBInfoSnapshot            `json:"mb,omitempty"`
+}
+
+// CatInfoSnapshot contains the cache allocation capabilities of one cache
+// level. Code and Data are present if CDP is enabled, Unified otherwise.
+type CatInfoSnapshot struct {
+	CacheIds []uint64                 `json:"cacheIds"`
+	Unified  *CatResourceInfoSnapshot `json:"unified,omitempty"`
+	Code     *CatResourceInfoSnapshot `json:"code,omitempty"`
+	Data     *CatResourceInfoSnapshot `json:"data,omitempty"`
+}
+
+// CatResourceInfoSnapshot contains the capabilities of one cache allocation
+// resource. Bitmasks are hex strings, as in the resctrl filesystem.
+type CatResourceInfoSnapshot struct {
+	CbmMask       string `json:"cbmMask"`
+	MinCbmBits    uint64 `json:"minCbmBits"`
+	ShareableBits string `json:"shareableBits"`
+}
+
+// L3MonInfoSnapshot contains the L3 monitoring capabilities.
+type L3MonInfoSnapshot struct {
+	NumRmids    uint64   `json:"numRmids"`
+	MonFeatures []string `json:"monFeatures"`
+}
+
+// MBInfoSnapshot contains the memory bandwidth allocation capabilities.
+type MBInfoSnapshot struct {
+	CacheIds      []uint64 `json:"cacheIds"`
+	BandwidthGran uint64   `json:"bandwidthGran"`
+	DelayLinear   uint64   `json:"delayLinear"`
+	MinBandwidth  uint64   `json:"minBandwidth"`
+	MBpsEnabled   bool     `json:"mbpsEnabled"`
+}
+
+// DumpInfo serializes the RDT capabilities detected by Initialize() into
+// JSON.
+func DumpInfo() ([]byte, error) {
+	if info == nil {
+		return nil, fmt.Errorf("rdt not initialized")
+	}
+	return json.MarshalIndent(info.snapshot(), "", "  ")
+}
+
+// LoadInfo replaces the RDT capabilities of the system with ones captured
+// with DumpInfo(), e.g. on another system. The package is left
+// uninitialized, i.e. the resctrl filesystem cannot be configured, but
+// configurations can be checked against the loaded capabilities with
+// DryRunConfig().
+func LoadInfo(data []byte) error {
+	s := InfoSnapshot{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("failed to parse RDT info: %v", err)
+	}
+
+	i, err := s.resctrlInfo()
+	if err != nil {
+		return err
+	}
+
+	rdt = nil
+	info = i
+
+	return nil
+}
+
+func (i *resctrlInfo) snapshot() *InfoSnapshot {
+	s := &InfoSnapshot{
+		ResctrlPath: i.resctrlPath,
+		NumClosids:  i.numClosids,
+	}
+
+	for o := range i.resctrlMountOpts {
+		s.ResctrlMountOpts = append(s.ResctrlMountOpts, o)
+	}
+	sort.Strings(s.ResctrlMountOpts)
+
+	if len(i.cat) > 0 {
+		s.Cat = make(map[string]CatInfoSnapshot, len(i.cat))
+	}
+	for lvl, c := range i.cat {
+		s.Cat[string(lvl)] = CatInfoSnapshot{
+			CacheIds: append([]uint64{}, c.cacheIds...),
+			Unified:  c.unified.snapshot(),
+			Code:     c.code.snapshot(),
+			Data:     c.data.snapshot(),
+		}
+	}
+
+	if i.l3mon.Supported() {
+		s.L3Mon = &L3MonInfoSnapshot{
+			NumRmids:    i.l3mon.numRmids,
+			MonFeatures: append([]string{}, i.l3mon.monFeatures...),
+		}
+	}
+
+	if i.mb.Supported() {
+		s.MB = &MBInfoSnapshot{
+			CacheIds:      append([]uint64{}, i.mb.cacheIds...),
+			BandwidthGran: i.mb.bandwidthGran,
+			DelayLinear:   i.mb.delayLinear,
+			MinBandwidth:  i.mb.minBandwidth,
+			MBpsEnabled:   i.mb.mbpsEnabled,
+		}
+	}
+
+	return s
+}
+
+func (i catInfo) snapshot() *CatResourceInfoSnapshot {
+	if !i.Supported() {
+		return nil
+	}
+	return &CatResourceInfoSnapshot{
+		CbmMask:       fmt.Sprintf("%x", uint64(i.cbmMask)),
+		MinCbmBits:    i.minCbmBits,
+		ShareableBits: fmt.Sprintf("%x", uint64(i.shareableBits)),
+	}
+}
+
+func (s *InfoSnapshot) resctrlInfo() (*resctrlInfo, error) {
+	i := &resctrlInfo{
+		resctrlPath:      s.ResctrlPath,
+		resctrlMountOpts: make(map[string]struct{}, len(s.ResctrlMountOpts)),
+		numClosids:       s.NumClosids,
+		cat:              make(map[CacheLevel]catInfoAll, len(s.Cat)),
+	}
+
+	for _, o := range s.ResctrlMountOpts {
+		i.resctrlMountOpts[o] = struct{}{}
+	}
+
+	for lvl, c := range s.Cat {
+		if CacheLevel(lvl) != L2 && CacheLevel(lvl) != L3 {
+			return nil, fmt.Errorf("invalid RDT info: unknown cache level %q", lvl)
+		}
+		var all catInfoAll
+		var err error
+		all.cacheIds = append([]uint64{}, c.CacheIds...)
+		if all.unified, err = c.Unified.catInfo(); err != nil {
+			return nil, fmt.Errorf("invalid RDT info of %s: %v", lvl, err)
+		}
+		if all.code, err = c.Code.catInfo(); err != nil {
+			return nil, fmt.Errorf("invalid RDT info of %s: %v", lvl, err)
+		}
+		if all.data, err = c.Data.catInfo(); err != nil {
+			return nil, fmt.Errorf("invalid RDT info of %s: %v", lvl, err)
+		}
+		i.cat[CacheLevel(lvl)] = all
+	}
+
+	if s.L3Mon != nil {
+		i.l3mon = l3MonInfo{
+			numRmids:    s.L3Mon.NumRmids,
+			monFeatures: append([]string{}, s.L3Mon.MonFeatures...),
+		}
+	}
+
+	if s.MB != nil {
+		i.mb = mbInfo{
+			cacheIds:      append([]uint64{}, s.MB.CacheIds...),
+			bandwidthGran: s.MB.BandwidthGran,
+			delayLinear:   s.MB.DelayLinear,
+			minBandwidth:  s.MB.MinBandwidth,
+			mbpsEnabled:   s.MB.MBpsEnabled,
+		}
+	}
+
+	return i, nil
+}
+
+func (s *CatResourceInfoSnapshot) catInfo() (catInfo, error) {
+	if s == nil {
+		return catInfo{}, nil
+	}
+
+	cbmMask, err := strconv.ParseUint(s.CbmMask, 16, 64)
+	if err != nil {
+		return catInfo{}, fmt.Errorf("invalid cbmMask %q: %v", s.CbmMask, err)
+	}
+	shareableBits, err := strconv.ParseUint(s.ShareableBits, 16, 64)
+	if err != nil {
+		return catInfo{}, fmt.Errorf("invalid shareableBits %q: %v", s.ShareableBits, err)
+	}
+
+	return catInfo{
+		cbmMask:       bitmask(cbmMask),
+		minCbmBits:    s.MinCbmBits,
+		shareableBits: bitmask(shareableBits),
+	}, nil
+}
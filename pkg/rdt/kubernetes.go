@@ -50,7 +50,7 @@ func ContainerClassFromAnnotations(containerName string, containerAnnotations, p
 		}
 
 		// Verify validity of class name
-		if !IsQualifiedClassName(clsName) {
+		if !IsQualifiedClassName(clsName) && !isNamespacedClassName(clsName) {
 			return "", fmt.Errorf("unqualified RDT class name %q", clsName)
 		}
 
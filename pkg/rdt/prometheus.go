@@ -17,7 +17,9 @@ limitations under the License.
 package rdt
 
 import (
+	"bytes"
 	"fmt"
+	"math/bits"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,6 +27,27 @@ import (
 
 var customLabels []string = []string{}
 
+var (
+	l3AllocatedWaysDesc = prometheus.NewDesc("rdt_l3_allocated_ways",
+		"number of L3 cache ways allocated to an RDT class",
+		[]string{"rdt_class", "cache_id", "type"}, nil)
+	mbAllocationPercentDesc = prometheus.NewDesc("rdt_mb_allocation_percent",
+		"memory bandwidth allocation of an RDT class in percent",
+		[]string{"rdt_class", "cache_id"}, nil)
+	classTasksDesc = prometheus.NewDesc("rdt_class_tasks",
+		"number of tasks assigned to an RDT class",
+		[]string{"rdt_class"}, nil)
+	classMonGroupsDesc = prometheus.NewDesc("rdt_class_mon_groups",
+		"number of monitoring groups of an RDT class",
+		[]string{"rdt_class"}, nil)
+	rmidsUsedDesc = prometheus.NewDesc("rdt_rmids_used",
+		"number of RMIDs in use by control and monitoring groups",
+		nil, nil)
+	rmidsTotalDesc = prometheus.NewDesc("rdt_rmids_total",
+		"number of RMIDs supported by the hardware",
+		nil, nil)
+)
+
 // collector implements prometheus.Collector interface
 type collector struct {
 	descriptors map[string]*prometheus.Desc
@@ -60,6 +83,12 @@ func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 			}
 		}
 	}
+	ch <- l3AllocatedWaysDesc
+	ch <- mbAllocationPercentDesc
+	ch <- classTasksDesc
+	ch <- classMonGroupsDesc
+	ch <- rmidsUsedDesc
+	ch <- rmidsTotalDesc
 }
 
 // Collect method of the prometheus.Collector interface
@@ -76,6 +105,9 @@ func (c collector) Collect(ch chan<- prometheus.Metric) {
 			}()
 		}
 	}
+	c.collectAllocationMetrics(ch)
+	c.collectClassMetrics(ch)
+	c.collectRmidMetrics(ch)
 	wg.Wait()
 }
 
@@ -122,3 +154,90 @@ func (c *collector) collectGroupMetrics(ch chan<- prometheus.Metric, mg MonGroup
 		}
 	}
 }
+
+// collectClassMetrics exports the number of tasks and monitoring groups of
+// each class. Failure to read the tasks of a class is reported as an invalid
+// metric, i.e. a collection error, for that class.
+func (c *collector) collectClassMetrics(ch chan<- prometheus.Metric) {
+	if rdt == nil {
+		return
+	}
+
+	for name, cls := range rdt.classes {
+		if n, err := cls.numTasks(); err != nil {
+			ch <- prometheus.NewInvalidMetric(classTasksDesc, fmt.Errorf("failed to read tasks of class %q: %w", name, err))
+		} else {
+			ch <- prometheus.MustNewConstMetric(classTasksDesc, prometheus.GaugeValue, float64(n), name)
+		}
+		ch <- prometheus.MustNewConstMetric(classMonGroupsDesc, prometheus.GaugeValue, float64(len(cls.monGroups)), name)
+	}
+}
+
+// numTasks returns the number of tasks assigned to the group. The tasks are
+// only counted, not parsed.
+func (r *resctrlGroup) numTasks() (int, error) {
+	data, err := readRdtFile(r.relPath("tasks"))
+	if err != nil {
+		return 0, err
+	}
+	return bytes.Count(data, []byte{'\n'}), nil
+}
+
+// collectRmidMetrics exports the RMID usage, i.e. how close the system is to
+// running out of monitoring groups.
+func (c *collector) collectRmidMetrics(ch chan<- prometheus.Metric) {
+	if info == nil || !info.l3mon.Supported() {
+		return
+	}
+
+	used, err := getRmidsInUse()
+	if err != nil {
+		log.Warnf("failed to count RMIDs in use: %v", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(rmidsUsedDesc, prometheus.GaugeValue, float64(used))
+	ch <- prometheus.MustNewConstMetric(rmidsTotalDesc, prometheus.GaugeValue, float64(info.l3mon.numRmids))
+}
+
+// collectAllocationMetrics exports the allocations of the classes, as
+// resolved from the active configuration.
+func (c *collector) collectAllocationMetrics(ch chan<- prometheus.Metric) {
+	if rdt == nil {
+		return
+	}
+
+	for name, a := range rdt.conf.classAllocations() {
+		if a.class.MonitoringOnly {
+			continue
+		}
+		class, partition := a.class, a.partition
+		for _, typ := range catSchemaTypes(L3) {
+			for _, id := range info.cat[L3].cacheIds {
+				mask, err := class.effectiveCatMask(name, L3, id, typ, partition, rdt.conf.Options)
+				if err != nil {
+					log.Warnf("failed to resolve L3 allocation of class %q: %v", name, err)
+					continue
+				}
+				ch <- prometheus.MustNewConstMetric(
+					l3AllocatedWaysDesc,
+					prometheus.GaugeValue,
+					float64(bits.OnesCount64(uint64(mask))),
+					name, fmt.Sprint(id), string(typ),
+				)
+			}
+		}
+
+		// MBps based allocation has no percentage to export
+		if info.mb.Supported() && !info.mb.mbpsEnabled {
+			for _, id := range info.mb.cacheIds {
+				ch <- prometheus.MustNewConstMetric(
+					mbAllocationPercentDesc,
+					prometheus.GaugeValue,
+					float64(class.MBSchema.effectiveValue(id, partition.MB)),
+					name, fmt.Sprint(id),
+				)
+			}
+		}
+	}
+}
@@ -47,17 +47,23 @@ package rdt
 import (
 	"errors"
 	"fmt"
+	"io"
 	stdlog "log"
+	"math/bits"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"sigs.k8s.io/yaml"
 
 	grclog "github.com/intel/goresctrl/pkg/log"
+	goresctrlpath "github.com/intel/goresctrl/pkg/path"
 	"github.com/intel/goresctrl/pkg/utils"
 )
 
@@ -69,13 +75,50 @@ const (
 	RootClassAlias = ""
 )
 
+// ErrResctrlRemounted is returned by SetConfig if the resctrl filesystem has
+// been re-mounted (e.g. with different mount options) after Initialize() was
+// called. Initialize() must be called again in this case.
+var ErrResctrlRemounted = errors.New("resctrl filesystem re-mounted, re-initialization needed")
+
+// ErrReadOnly is returned by all operations that would modify the resctrl
+// filesystem after the package has been initialized with
+// InitializeReadOnly().
+var ErrReadOnly = errors.New("rdt initialized in read-only mode")
+
+// ErrMonitoringUnsupported is returned by GetMonDataChecked() if monitoring
+// is not supported by the system.
+var ErrMonitoringUnsupported = errors.New("RDT monitoring not supported")
+
+// ErrRmidExhausted is returned by CreateMonGroup() if a new monitoring group
+// cannot be created because all RMIDs (monitoring ids) of the hardware are in
+// use.
+var ErrRmidExhausted = errors.New("RMIDs exhausted")
+
+// ErrClosidExhausted is returned by SetConfig() if the configuration has more
+// classes than there are CLOSIDs (class of service ids) available.
+var ErrClosidExhausted = errors.New("CLOSIDs exhausted")
+
+// ErrRootClassMissing is returned by SetConfig() if the root class has
+// disappeared from the runtime data and the StrictRootClass option is set.
+var ErrRootClassMissing = errors.New("root class missing from runtime data")
+
+// ErrProcessExited is returned by MigrateProcess() if the process exited
+// before its migration could be confirmed.
+var ErrProcessExited = errors.New("process exited")
+
+// RmidPressureThreshold is the fraction of RMIDs in use above which
+// CreateMonGroup() logs a warning about RMIDs running out.
+var RmidPressureThreshold = 0.9
+
 type control struct {
 	grclog.Logger
 
 	resctrlGroupPrefix string
+	readOnly           bool
 	conf               config
 	rawConf            Config
 	classes            map[string]*ctrlGroup
+	warnings           []string
 }
 
 var log grclog.Logger = grclog.NewLoggerWrapper(stdlog.New(os.Stderr, "[ rdt ] ", 0))
@@ -88,6 +131,10 @@ var rdt *control
 // configurable because of unit tests.
 var groupRemoveFunc func(string) error = os.Remove
 
+// Function for creating resctrl groups in the filesystem. This is
+// configurable because of unit tests.
+var groupCreateFunc func(string, os.FileMode) error = os.Mkdir
+
 // CtrlGroup defines the interface of one goresctrl managed RDT class. It maps
 // to one CTRL group directory in the goresctrl pseudo-filesystem.
 type CtrlGroup interface {
@@ -118,10 +165,15 @@ type ResctrlGroup interface {
 	// GetPids returns the process ids assigned to the group.
 	GetPids() ([]string, error)
 
-	// AddPids assigns the given process ids to the group.
+	// AddPids assigns the given process ids to the group. The ids are
+	// written to the resctrl tasks file as such, i.e. each of them may be
+	// a process or a thread id and only that one task is assigned.
 	AddPids(pids ...string) error
 
-	// GetMonData retrieves the monitoring data of the group.
+	// GetMonData retrieves the monitoring data of the group. For a
+	// CtrlGroup the data covers all tasks of the class, including the
+	// tasks of its monitoring groups, see
+	// CtrlGroupExt.GetClassTotalMonData().
 	GetMonData() MonData
 }
 
@@ -137,14 +189,119 @@ type MonGroup interface {
 	GetAnnotations() map[string]string
 }
 
+// ResctrlGroupExt extends ResctrlGroup with additional task management and
+// monitoring operations. All ResctrlGroups returned by this package implement
+// it, i.e. callers may type-assert a ResctrlGroup to ResctrlGroupExt.
+type ResctrlGroupExt interface {
+	ResctrlGroup
+
+	// GetPidsWithComm returns the process ids assigned to the group, mapped
+	// to their command name (/proc/<pid>/comm). Processes that have exited,
+	// or whose command name cannot be read, are omitted.
+	GetPidsWithComm() (map[string]string, error)
+
+	// AddPidsPerThread assigns all threads of the given processes to the
+	// group. The threads are read from /proc/<pid>/task. Only threads
+	// existing at the time of the call are captured: threads created
+	// later inherit the group of their parent thread but e.g. threads of
+	// a re-assigned process need to be re-synced by calling
+	// AddPidsPerThread again.
+	AddPidsPerThread(pids ...string) error
+
+	// RemovePids moves the given process ids back to the root
+	// (system/default) group. Tasks that do not exist (anymore) are
+	// ignored. All pids are attempted and the returned error lists the
+	// ones that could not be moved.
+	RemovePids(pids ...string) error
+
+	// GetMonDataChecked is like GetMonData but returns an error if the
+	// monitoring data cannot be retrieved, e.g. ErrMonitoringUnsupported
+	// if monitoring is not supported by the system.
+	GetMonDataChecked() (MonData, error)
+
+	// GetMonDataForCacheID retrieves the L3 monitoring data of the group for
+	// one cache id only.
+	GetMonDataForCacheID(id uint64) (MonLeafData, error)
+
+	// GetMonDataAggregated retrieves the monitoring data of the group,
+	// including L3 monitoring data aggregated per physical package.
+	GetMonDataAggregated() MonData
+}
+
+// CtrlGroupExt extends CtrlGroup with additional operations. All CtrlGroups
+// returned by this package implement it, i.e. callers may type-assert a
+// CtrlGroup to CtrlGroupExt.
+type CtrlGroupExt interface {
+	CtrlGroup
+	ResctrlGroupExt
+
+	// AddCgroup assigns all tasks of a cgroup to this CtrlGroup. The path
+	// may be absolute or relative to the unified cgroup mount point
+	// (/sys/fs/cgroup). The tasks of the cgroup are re-read and the ones
+	// that appeared during the operation are assigned, too. Returns the
+	// number of tasks assigned.
+	AddCgroup(cgroupPath string) (int, error)
+
+	// GetClassTotalMonData retrieves the monitoring data of all tasks of
+	// the class, i.e. the tasks assigned directly to the class and the
+	// tasks of all of its monitoring groups. This is what the kernel
+	// reports for the class and it equals GetMonDataChecked() of the
+	// CtrlGroup. Adding the monitoring data of the monitoring groups to it
+	// counts their tasks twice.
+	GetClassTotalMonData() (MonData, error)
+}
+
 // MonData contains monitoring stats of one monitoring group.
 type MonData struct {
 	L3 MonL3Data
+
+	// L3Package contains the L3 monitoring stats summed up per physical
+	// package. It is only populated by GetMonDataAggregated().
+	L3Package MonL3Data `json:",omitempty"`
 }
 
 // MonL3Data contains L3 monitoring stats of one monitoring group.
 type MonL3Data map[uint64]MonLeafData
 
+// MonBandwidth calculates memory bandwidth in bytes per second from two
+// samples of monitoring data taken interval apart. The result contains the
+// rate of each memory bandwidth counter (mbm_total_bytes, mbm_local_bytes)
+// per L3 cache id. Counters missing from either sample are skipped.
+//
+// The kernel extends the fixed-width hardware counters to 64 bits, so the
+// counters only wrap around at 2^64. A decreasing counter is treated as such
+// a wrap-around and the difference is calculated modulo 2^64. Note that a
+// counter reset, e.g. re-creation of the group between the samples, cannot
+// be distinguished from a wrap-around and results in a bogus rate.
+func MonBandwidth(prev, now MonData, interval time.Duration) map[uint64]map[string]float64 {
+	ret := map[uint64]map[string]float64{}
+	if interval <= 0 {
+		return ret
+	}
+
+	for id, data := range now.L3 {
+		prevData, ok := prev.L3[id]
+		if !ok {
+			continue
+		}
+		for feature, value := range data {
+			if !strings.HasPrefix(feature, "mbm_") {
+				continue
+			}
+			prevValue, ok := prevData[feature]
+			if !ok {
+				continue
+			}
+			if _, ok := ret[id]; !ok {
+				ret[id] = map[string]float64{}
+			}
+			// Unsigned subtraction handles the wrap-around
+			ret[id][feature] = float64(value-prevValue) / interval.Seconds()
+		}
+	}
+	return ret
+}
+
 // MonLeafData represents the raw numerical stats from one RDT monitor data leaf.
 type MonLeafData map[string]uint64
 
@@ -159,8 +316,10 @@ const (
 type ctrlGroup struct {
 	resctrlGroup
 
-	monPrefix string
-	monGroups map[string]*monGroup
+	monPrefix       string
+	monGroups       map[string]*monGroup
+	annotations     map[string]string   // default annotations of mon groups
+	staticMonGroups map[string]struct{} // mon groups from the config, never pruned
 }
 
 type monGroup struct {
@@ -170,9 +329,10 @@ type monGroup struct {
 }
 
 type resctrlGroup struct {
-	prefix string
-	name   string
-	parent *ctrlGroup // parent for MON groups
+	prefix   string
+	name     string
+	parent   *ctrlGroup // parent for MON groups
+	readOnly bool
 }
 
 // SetLogger sets the logger instance to be used by the package. This function
@@ -187,30 +347,143 @@ func SetLogger(l grclog.Logger) {
 // Initialize detects RDT from the system and initializes control interface of
 // the package.
 func Initialize(resctrlGroupPrefix string) error {
-	var err error
+	return initialize(resctrlGroupPrefix, "", "", false)
+}
+
+// InitializeReadOnly is like Initialize() but puts the package in a read-only
+// "observe" mode where the resctrl filesystem is never written to. Classes
+// are discovered from the resctrl filesystem and monitoring data and metrics
+// are available as usual, but operations modifying resctrl (e.g. SetConfig(),
+// AddPids() and creating or deleting monitoring groups) fail with
+// ErrReadOnly.
+func InitializeReadOnly(resctrlGroupPrefix string) error {
+	return initialize(resctrlGroupPrefix, "", "", true)
+}
+
+// InitializeStrict is like Initialize() but refuses to start if the resctrl
+// filesystem contains control groups with any of the given conflicting
+// prefixes, e.g. ones created by another controller or by a stale instance
+// using the same prefix. Only the class directories of the currently active
+// instance, if re-initializing, are not considered conflicting. The returned
+// error enumerates the conflicting groups and the package state is left
+// untouched.
+func InitializeStrict(resctrlGroupPrefix string, conflictPrefixes []string) error {
+	i, err := getRdtInfo("", "")
+	if err != nil {
+		return err
+	}
+
+	own := map[string]struct{}{}
+	if rdt != nil && info != nil && info.resctrlPath == i.resctrlPath {
+		for _, cls := range rdt.classes {
+			own[cls.relPath("")] = struct{}{}
+		}
+	}
+
+	// Prefixes may overlap, collect each conflicting group only once
+	found := map[string]struct{}{}
+	conflicts := []string{}
+	for _, prefix := range conflictPrefixes {
+		groups, err := resctrlGroupsFromFs(prefix, i.resctrlPath)
+		if err != nil {
+			return fmt.Errorf("failed to scan resctrl groups: %v", err)
+		}
+		for _, g := range groups {
+			if _, ok := found[g]; ok {
+				continue
+			}
+			if _, ok := own[g]; ok {
+				continue
+			}
+			found[g] = struct{}{}
+			conflicts = append(conflicts, g)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("conflicting resctrl groups found: %s", strings.Join(conflicts, ", "))
+	}
+
+	return initializeWithInfo(i, resctrlGroupPrefix, false)
+}
 
+func initialize(resctrlGroupPrefix, resctrlRootPath, mountInfoFile string, readOnly bool) error {
 	info = nil
 	rdt = nil
 
 	// Get info from the resctrl filesystem
-	info, err = getRdtInfo()
+	i, err := getRdtInfo(resctrlRootPath, mountInfoFile)
 	if err != nil {
 		return err
 	}
 
-	r := &control{Logger: log, resctrlGroupPrefix: resctrlGroupPrefix}
+	return initializeWithInfo(i, resctrlGroupPrefix, readOnly)
+}
+
+func initializeWithInfo(i *resctrlInfo, resctrlGroupPrefix string, readOnly bool) error {
+	var err error
+
+	info = i
+	rdt = nil
 
 	// NOTE: we lose monitoring group annotations (i.e. prometheus metrics
 	// labels) on re-init
-	if r.classes, err = r.classesFromResctrlFs(); err != nil {
-		return fmt.Errorf("failed to initialize classes from resctrl fs: %v", err)
+	rdt, err = newControl(resctrlGroupPrefix, readOnly)
+
+	return err
+}
+
+// Shutdown releases the package-level state set up by Initialize(). If
+// removeGroups is true all goresctrl-managed classes are removed from the
+// resctrl filesystem and the root class is reset to full allocation, like
+// when setting an empty configuration. Removal of classes that have tasks
+// assigned is refused unless force is true. The state is left intact if the
+// removal fails. Calling Shutdown() on an uninitialized package is a no-op.
+func Shutdown(removeGroups, force bool) error {
+	if rdt == nil {
+		info = nil
+		return nil
 	}
 
-	rdt = r
+	if removeGroups {
+		if err := rdt.setConfig(&Config{}, force); err != nil {
+			return fmt.Errorf("failed to remove resctrl groups: %w", err)
+		}
+	}
 
+	rdt = nil
+	info = nil
 	return nil
 }
 
+// InitializeWithRoot is like Initialize() but uses the resctrl filesystem
+// tree at resctrlRootPath instead of the one detected from the system. Mount
+// options are read from the resctrl entry of the given mount info file (in
+// /proc/mounts format), if any. An empty mountInfoFile means that no mount
+// options are in use. This is mainly intended for writing tests against a
+// fake resctrl tree. The settings only apply to this initialization, a later
+// Initialize() detects the resctrl filesystem from the system again.
+// Calling InitializeWithRoot() with empty resctrlRootPath and mountInfoFile
+// is equivalent to Initialize().
+func InitializeWithRoot(resctrlGroupPrefix, resctrlRootPath, mountInfoFile string) error {
+	return initialize(resctrlGroupPrefix, resctrlRootPath, mountInfoFile, false)
+}
+
+func newControl(resctrlGroupPrefix string, readOnly bool) (*control, error) {
+	var err error
+
+	c := &control{Logger: log, resctrlGroupPrefix: resctrlGroupPrefix, readOnly: readOnly}
+	if c.classes, err = c.classesFromResctrlFs(); err != nil {
+		return nil, fmt.Errorf("failed to initialize classes from resctrl fs: %v", err)
+	}
+	if err := checkResctrlGroupCount(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
 // DiscoverClasses discovers existing classes from the resctrl filesystem.
 // Makes it possible to discover gropus with another prefix than was set with
 // Initialize(). The original prefix is still used for monitoring groups.
@@ -230,17 +503,75 @@ func SetConfig(c *Config, force bool) error {
 	return fmt.Errorf("rdt not initialized")
 }
 
+// DryRunConfig resolves a configuration and returns the schemata that
+// SetConfig() would write for each class, without writing anything to the
+// resctrl filesystem. The schemata of a class is returned with one line per
+// resource. The RDT capabilities of the system must have been detected with
+// one of the Initialize functions. For validating configurations offline,
+// e.g. in CI, load the capabilities of the target system captured with
+// DumpInfo() using LoadInfo(), or use InitializeWithRoot() on a captured copy
+// of its resctrl filesystem (the info directory and root schemata). The root
+// class is only included if SetConfig() would write it, i.e. if it is
+// specified in the configuration or if the configuration has no classes at
+// all, in which case the root class is reset to full allocation.
+func DryRunConfig(c *Config) (map[string]string, error) {
+	if info == nil {
+		return nil, fmt.Errorf("rdt not initialized")
+	}
+
+	if c == nil {
+		c = &Config{}
+	}
+
+	conf, err := c.resolve()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	allocations := conf.classAllocations()
+
+	// The root class always consumes one CLOSID
+	classes := len(allocations)
+	if _, ok := allocations[RootClassName]; !ok {
+		classes++
+	}
+	if err := verifyClosidCount(classes, 0); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string, len(allocations))
+	for name, a := range allocations {
+		schemata, err := a.class.schemata(name, a.partition, conf.Options)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = strings.Join(schemata, "")
+	}
+	return ret, nil
+}
+
 // SetConfigFromData takes configuration as raw data, parses it and
 // reconfigures the resctrl filesystem.
 func SetConfigFromData(data []byte, force bool) error {
-	cfg := &Config{}
-	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
-		return fmt.Errorf("failed to parse configuration data: %v", err)
+	cfg, err := parseConfigData(data)
+	if err != nil {
+		return err
 	}
 
 	return SetConfig(cfg, force)
 }
 
+func parseConfigData(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration data: %v", err)
+	}
+	if cfg.Version > ConfigVersion {
+		return nil, fmt.Errorf("unsupported configuration version %d (latest supported is %d)", cfg.Version, ConfigVersion)
+	}
+	return cfg, nil
+}
+
 // SetConfigFromFile reads configuration from the filesystem and reconfigures
 // the resctrl filesystem.
 func SetConfigFromFile(path string, force bool) error {
@@ -257,6 +588,46 @@ func SetConfigFromFile(path string, force bool) error {
 	return nil
 }
 
+// SetConfigFromFiles reads configuration from multiple files, merges them and
+// reconfigures the resctrl filesystem. The files are merged in order so that
+// settings in later files override the earlier ones. Options, partitions and
+// classes are merged recursively. Cache and memory bandwidth allocations are
+// merged per cache id, the allocation of one cache id being replaced as a
+// whole. Null values do not override anything.
+func SetConfigFromFiles(paths []string, force bool) error {
+	cfg, err := readConfigFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	if err := SetConfig(cfg, force); err != nil {
+		return err
+	}
+
+	log.Infof("configuration successfully loaded from %q", paths)
+	return nil
+}
+
+func readConfigFiles(paths []string) (*Config, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no config files specified")
+	}
+
+	data := make([][]byte, len(paths))
+	for i, path := range paths {
+		var err error
+		if data[i], err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		// Check each file separately to get sensible error messages
+		if _, err := parseConfigData(data[i]); err != nil {
+			return nil, fmt.Errorf("invalid config file %q: %v", path, err)
+		}
+	}
+
+	return mergeConfigData(data...)
+}
+
 // GetClass returns one RDT class.
 func GetClass(name string) (CtrlGroup, bool) {
 	if rdt != nil {
@@ -273,6 +644,198 @@ func GetClasses() []CtrlGroup {
 	return []CtrlGroup{}
 }
 
+// ClassInfo contains the resolved configuration of one RDT class, i.e. the
+// schemata applied by the latest SetConfig().
+type ClassInfo struct {
+	// Partition is the name of the partition of the class. Empty for the
+	// root class of a configuration without classes.
+	Partition string
+	// L2 and L3 contain the schemata lines of the cache allocation, e.g.
+	// "L3:0=ff;1=ff", or separate code and data lines if CDP is enabled.
+	// Empty if cache allocation is not in use.
+	L2 []string
+	L3 []string
+	// MB is the schemata line of the memory bandwidth allocation, e.g.
+	// "MB:0=50;1=50". Empty if memory bandwidth allocation is not in use.
+	MB string
+	// MonitoringOnly is true for classes without allocation of their own.
+	MonitoringOnly bool
+}
+
+// GetClassConfig returns the resolved configuration of a class as applied by
+// the latest SetConfig(). The root class is not found if SetConfig() left it
+// untouched, see DryRunConfig().
+func GetClassConfig(name string) (ClassInfo, bool) {
+	if rdt != nil {
+		return rdt.getClassConfig(name)
+	}
+	return ClassInfo{}, false
+}
+
+// FreeRanges returns the contiguous blocks of cache ways of a cache id that
+// are not allocated to any partition of the active configuration, nor
+// reserved for the root class with the rootReserve option. The blocks are
+// returned as bitmasks in ascending order. They can be used e.g. for placing
+// new exclusive partitions with absolute allocations, or for reporting the
+// fragmentation of the cache.
+func FreeRanges(lvl CacheLevel, id uint64) ([]uint64, error) {
+	if rdt != nil {
+		return rdt.freeRanges(lvl, id)
+	}
+	return nil, fmt.Errorf("rdt not initialized")
+}
+
+// FormatAllocationTable returns the active configuration as a human-readable
+// table, listing the requested and granted cache and memory bandwidth
+// allocations of each partition and class per cache id.
+func FormatAllocationTable() string {
+	if rdt != nil {
+		return rdt.formatAllocationTable()
+	}
+	return ""
+}
+
+// GetConfigWarnings returns the warnings, i.e. unexpected but non-fatal
+// conditions, encountered by the latest SetConfig().
+func GetConfigWarnings() []string {
+	if rdt != nil {
+		return rdt.getConfigWarnings()
+	}
+	return []string{}
+}
+
+// MigrateProcess moves a process to an RDT class and confirms that the
+// process was assigned to the class by reading back the task list. Only the
+// given task is moved, see MigrateProcessPerThread() for moving all threads of
+// the process. ErrProcessExited is returned if the process exited during the
+// migration.
+func MigrateProcess(pid string, dst string) error {
+	if rdt != nil {
+		return rdt.migrateProcess(pid, dst, false)
+	}
+	return fmt.Errorf("rdt not initialized")
+}
+
+// MigrateProcessPerThread moves all threads of a process to an RDT class and
+// confirms the migration, see MigrateProcess(). Threads exiting during the
+// migration are ignored.
+func MigrateProcessPerThread(pid string, dst string) error {
+	if rdt != nil {
+		return rdt.migrateProcess(pid, dst, true)
+	}
+	return fmt.Errorf("rdt not initialized")
+}
+
+// ConfigDiff describes the changes that applying a new configuration would
+// make compared to the currently active configuration.
+type ConfigDiff struct {
+	// Added contains the names of classes that would be created.
+	Added []string
+	// Removed contains the names of classes that would be removed.
+	Removed []string
+	// Modified contains the names of classes whose schemata or partition
+	// would change.
+	Modified []string
+	// Schemata contains the changed schemata lines (e.g. "L3:0=ff;1=ff")
+	// of all added, removed and modified classes. Only the resources that
+	// change are included.
+	Schemata map[string]SchemataChange
+}
+
+// SchemataChange contains the current and desired schemata lines of the
+// resources of one class that differ.
+type SchemataChange struct {
+	Current []string
+	Desired []string
+}
+
+// IsEmpty returns true if the diff contains no changes.
+func (d ConfigDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// UpdateClass changes the configuration of one existing class without
+// reconfiguring the other classes. Only the schemata of the class is
+// rewritten, the other resctrl groups and the tasks and monitoring groups of
+// all classes are left intact. The change is refused if it would alter the
+// allocation of any other class (e.g. with proportional MB allocation), or if
+// it changes the MonitoringOnly or Closid settings of the class. Such changes
+// require a full SetConfig().
+func UpdateClass(name string, class ClassConfig) error {
+	if rdt != nil {
+		return rdt.updateClass(name, class)
+	}
+	return fmt.Errorf("rdt not initialized")
+}
+
+// SwapClassAllocations swaps the cache and memory bandwidth allocations of
+// two classes of the same partition, leaving their tasks, monitoring groups
+// and the other classes intact. The schemata are written in an order where
+// the allocations that shrink are updated before the ones that grow, so that
+// the combined allocation of the two classes never transiently exceeds its
+// capacity. The swap is refused if it would change the allocation of any
+// other class.
+func SwapClassAllocations(a, b string) error {
+	if rdt != nil {
+		return rdt.swapClassAllocations(a, b)
+	}
+	return fmt.Errorf("rdt not initialized")
+}
+
+// DiffConfig resolves the given configuration and compares it against the
+// currently active one, without touching the resctrl filesystem.
+func DiffConfig(desired *Config) (ConfigDiff, error) {
+	if rdt != nil {
+		return rdt.diffConfig(desired)
+	}
+	return ConfigDiff{}, fmt.Errorf("rdt not initialized")
+}
+
+// ConfigSnapshot contains the state of all RDT classes, see SnapshotConfig().
+type ConfigSnapshot struct {
+	// Config is the configuration that was active when the snapshot was taken.
+	Config *Config
+	// Classes contains the state of each class, keyed by class name.
+	Classes map[string]ClassSnapshot
+}
+
+// ClassSnapshot contains the state of one RDT class.
+type ClassSnapshot struct {
+	// Schemata is the raw content of the schemata file of the class.
+	Schemata string
+	// Tasks contains the tasks assigned directly to the class.
+	Tasks []string
+	// MonGroups contains the monitoring groups of the class.
+	MonGroups map[string]MonGroupSnapshot
+}
+
+// MonGroupSnapshot contains the state of one monitoring group.
+type MonGroupSnapshot struct {
+	Annotations map[string]string
+	Tasks       []string
+}
+
+// SnapshotConfig captures the active configuration together with the
+// schemata, monitoring groups and task assignments of all classes. The
+// snapshot can be used to revert to the captured state with RestoreConfig().
+func SnapshotConfig() (*ConfigSnapshot, error) {
+	if rdt != nil {
+		return rdt.snapshotConfig()
+	}
+	return nil, fmt.Errorf("rdt not initialized")
+}
+
+// RestoreConfig reverts the resctrl filesystem to the state captured in a
+// snapshot. Classes and monitoring groups created after the snapshot are
+// removed and tasks are re-assigned to the groups they were in. Tasks that
+// no longer exist are ignored.
+func RestoreConfig(s *ConfigSnapshot) error {
+	if rdt != nil {
+		return rdt.restoreConfig(s)
+	}
+	return fmt.Errorf("rdt not initialized")
+}
+
 // MonSupported returns true if RDT monitoring features are available.
 func MonSupported() bool {
 	if rdt != nil {
@@ -296,6 +859,21 @@ func IsQualifiedClassName(name string) bool {
 	return name == RootClassName || (len(name) < 4096 && name != "." && name != ".." && !strings.ContainsAny(name, "/\n"))
 }
 
+// isQualifiedMonGroupName returns true if given string qualifies as a
+// monitoring group name.
+func isQualifiedMonGroupName(name string) bool {
+	return name != "" && name != RootClassName && IsQualifiedClassName(name)
+}
+
+// isNamespacedClassName returns true if given string qualifies as a class
+// name of the form "<partition>/<class>".
+func isNamespacedClassName(name string) bool {
+	split := strings.SplitN(name, "/", 2)
+	return len(split) == 2 && !isRootClass(name) &&
+		IsQualifiedClassName(split[0]) && IsQualifiedClassName(split[1]) &&
+		split[0] != RootClassAlias && split[1] != RootClassAlias
+}
+
 func (c *control) getClass(name string) (CtrlGroup, bool) {
 	cls, ok := c.classes[unaliasClassName(name)]
 	return cls, ok
@@ -312,99 +890,894 @@ func (c *control) getClasses() []CtrlGroup {
 	return ret
 }
 
-func (c *control) monSupported() bool {
-	return info.l3mon.Supported()
-}
+func (c *control) getClassConfig(name string) (ClassInfo, bool) {
+	a, ok := c.conf.classAllocation(name)
+	if !ok {
+		return ClassInfo{}, false
+	}
+	class := a.class
 
-func (c *control) getMonFeatures() map[MonResource][]string {
-	ret := make(map[MonResource][]string)
-	if info.l3mon.Supported() {
-		ret[MonResourceL3] = append([]string{}, info.l3mon.monFeatures...)
+	lines, err := class.schemata(name, a.partition, c.conf.Options)
+	if err != nil {
+		c.Errorf("failed to resolve schemata of class %q: %v", name, err)
+		return ClassInfo{}, false
 	}
 
-	return ret
+	ret := ClassInfo{Partition: class.Partition, MonitoringOnly: class.MonitoringOnly}
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, string(L2)):
+			ret.L2 = append(ret.L2, line)
+		case strings.HasPrefix(line, string(L3)):
+			ret.L3 = append(ret.L3, line)
+		case strings.HasPrefix(line, "MB"):
+			ret.MB = line
+		}
+	}
+	return ret, true
 }
 
-func (c *control) setLogger(l grclog.Logger) {
-	c.Logger = l
+func (c *control) freeRanges(lvl CacheLevel, id uint64) ([]uint64, error) {
+	ranges, err := c.conf.freeCatRanges(lvl, id)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]uint64, len(ranges))
+	for i, r := range ranges {
+		ret[i] = uint64(r)
+	}
+	return ret, nil
 }
 
-func (c *control) setConfig(newConfig *Config, force bool) error {
-	c.Infof("configuration update")
+func (c *control) formatAllocationTable() string {
+	buf := &strings.Builder{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PARTITION\tCLASS\tRESOURCE\tCACHE ID\tTYPE\tREQUESTED\tGRANTED")
 
-	conf, err := (*newConfig).resolve()
-	if err != nil {
-		return fmt.Errorf("invalid configuration: %v", err)
+	partitions := make([]string, 0, len(c.conf.Partitions))
+	for name := range c.conf.Partitions {
+		partitions = append(partitions, name)
 	}
+	sort.Strings(partitions)
 
-	err = c.configureResctrl(conf, force)
-	if err != nil {
-		return fmt.Errorf("resctrl configuration failed: %v", err)
+	catIds := map[CacheLevel][]uint64{}
+	for _, lvl := range []CacheLevel{L2, L3} {
+		catIds[lvl] = append([]uint64{}, info.cat[lvl].cacheIds...)
+		utils.SortUint64s(catIds[lvl])
 	}
+	mbIds := append([]uint64{}, info.mb.cacheIds...)
+	utils.SortUint64s(mbIds)
 
-	c.conf = conf
-	// TODO: we'd better create a deep copy
-	c.rawConf = *newConfig
-	c.Infof("configuration finished")
-
-	return nil
-}
+	row := func(partition, class, res string, id uint64, typ, requested, granted string) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", partition, class, res, id, typ, requested, granted)
+	}
 
-func (c *control) configureResctrl(conf config, force bool) error {
-	grclog.DebugBlock(c, "applying resolved config:", "  ", "%s", utils.DumpJSON(conf))
+	for _, pname := range partitions {
+		partition := c.conf.Partitions[pname]
+		raw := c.rawConf.Partitions[pname]
 
-	// Remove stale resctrl groups
-	classesFromFs, err := c.classesFromResctrlFs()
-	if err != nil {
-		return err
-	}
+		classes := []string{}
+		for name, class := range c.conf.Classes {
+			// Monitoring-only classes have no allocation to show
+			if class.Partition == pname && !class.MonitoringOnly {
+				classes = append(classes, name)
+			}
+		}
+		sort.Strings(classes)
 
-	for name, cls := range classesFromFs {
-		if _, ok := conf.Classes[cls.name]; !isRootClass(cls.name) && !ok {
-			if !force {
-				tasks, err := cls.GetPids()
-				if err != nil {
-					return fmt.Errorf("failed to get resctrl group tasks: %v", err)
-				}
-				if len(tasks) > 0 {
-					return fmt.Errorf("refusing to remove non-empty resctrl group %q", cls.relPath(""))
-				}
+		for _, lvl := range []CacheLevel{L2, L3} {
+			rawCat := raw.L2Allocation
+			if lvl == L3 {
+				rawCat = raw.L3Allocation
 			}
-			log.Debugf("removing existing resctrl group %q", cls.relPath(""))
-			err = groupRemoveFunc(cls.path(""))
-			if err != nil {
-				return fmt.Errorf("failed to remove resctrl group %q: %v", cls.relPath(""), err)
+			requests, _ := rawCat.toSchema(lvl)
+
+			for _, id := range catIds[lvl] {
+				for _, typ := range catSchemaTypes(lvl) {
+					if requests.Alloc != nil {
+						granted := partition.CAT[lvl].Alloc[id].getEffective(typ)
+						row(pname, "-", string(lvl), id, string(typ),
+							cacheAllocationStr(requests.Alloc[id].getEffective(typ)), cacheAllocationStr(granted))
+					}
+					for _, cname := range classes {
+						class := c.conf.Classes[cname]
+						requested := "-"
+						if class.CATSchema[lvl].Alloc != nil {
+							requested = cacheAllocationStr(class.CATSchema[lvl].Alloc[id].getEffective(typ))
+						}
+						granted := "<error>"
+						if mask, err := class.effectiveCatMask(cname, lvl, id, typ, partition, c.conf.Options); err == nil {
+							granted = fmt.Sprintf("%#x", mask)
+						}
+						row(pname, cname, string(lvl), id, string(typ), requested, granted)
+					}
+				}
 			}
-
-			delete(c.classes, name)
 		}
-	}
 
-	for name, cls := range c.classes {
-		if _, ok := conf.Classes[cls.name]; !ok || cls.prefix != c.resctrlGroupPrefix {
-			if !isRootClass(cls.name) {
-				log.Debugf("dropping stale class %q (%q)", name, cls.path(""))
-				delete(c.classes, name)
+		if info.mb.Supported() {
+			requests, _ := raw.MBAllocation.toSchema(c.rawConf.Options.MB)
+			for _, id := range mbIds {
+				if requests != nil {
+					row(pname, "-", "MB", id, "-", mbAllocationStr(requests[id]), mbAllocationStr(partition.MB[id]))
+				}
+				for _, cname := range classes {
+					class := c.conf.Classes[cname]
+					requested := "-"
+					if class.MBSchema != nil {
+						requested = mbAllocationStr(class.MBSchema[id])
+					}
+					row(pname, cname, "MB", id, "-", requested, mbAllocationStr(class.MBSchema.effectiveValue(id, partition.MB)))
+				}
 			}
 		}
 	}
 
-	if _, ok := c.classes[RootClassName]; !ok {
-		log.Warnf("root class missing from runtime data, re-adding...")
-		c.classes[RootClassName] = classesFromFs[RootClassName]
-	}
+	w.Flush()
 
-	// Try to apply given configuration
-	for name, class := range conf.Classes {
+	return buf.String()
+}
+
+// copyPartitionClasses returns a shallow copy of the configuration where the
+// classes of one partition may be modified without affecting the original.
+func (c Config) copyPartitionClasses(pname string) (Config, map[string]ClassConfig) {
+	ret := c
+	ret.Partitions = make(map[string]PartitionConfig, len(c.Partitions))
+	for n, p := range c.Partitions {
+		ret.Partitions[n] = p
+	}
+	partition := ret.Partitions[pname]
+	classes := make(map[string]ClassConfig, len(partition.Classes))
+	for n, cls := range partition.Classes {
+		classes[n] = cls
+	}
+	partition.Classes = classes
+	ret.Partitions[pname] = partition
+	return ret, classes
+}
+
+// rawClassKey returns the key of a class in the classes of a partition of the
+// raw configuration.
+func (c *Config) rawClassKey(pname, name string) (string, bool) {
+	for k := range c.Partitions[pname].Classes {
+		if n, err := c.className(pname, k); err == nil && n == name {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// changedClass returns the name of a class, other than the ones given, whose
+// schemata would be changed by switching to a new configuration. An empty
+// string is returned if there is no such class.
+func (c config) changedClass(newConf config, exclude ...string) (string, error) {
+	curSchemata, err := c.classSchemata()
+	if err != nil {
+		return "", err
+	}
+	newSchemata, err := newConf.classSchemata()
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(newSchemata))
+	for n := range newSchemata {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+NAMES:
+	for _, n := range names {
+		for _, e := range exclude {
+			if n == e {
+				continue NAMES
+			}
+		}
+		if !reflect.DeepEqual(newSchemata[n], curSchemata[n]) {
+			return n, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *control) updateClass(name string, class ClassConfig) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := checkResctrlMount(); err != nil {
+		return err
+	}
+
+	cur, ok := c.conf.Classes[name]
+	cg, ok2 := c.classes[name]
+	if !ok || !ok2 {
+		return fmt.Errorf("class %q not found in the active configuration", name)
+	}
+	pname := cur.Partition
+
+	// Copy the active configuration, replacing the partition of the class
+	newConfig, classes := c.rawConf.copyPartitionClasses(pname)
+
+	key, found := newConfig.rawClassKey(pname, name)
+	if !found {
+		return fmt.Errorf("BUG: class %q not found in the raw configuration", name)
+	}
+	old := classes[key]
+	if class.MonitoringOnly != old.MonitoringOnly {
+		return fmt.Errorf("changing monitoringOnly of class %q requires a full reconfiguration", name)
+	}
+	if !reflect.DeepEqual(class.Closid, old.Closid) {
+		return fmt.Errorf("changing the CLOSID of class %q requires a full reconfiguration", name)
+	}
+	classes[key] = class
+
+	conf, err := newConfig.resolve()
+	if err != nil {
+		return fmt.Errorf("invalid configuration for class %q: %v", name, err)
+	}
+
+	// Verify that the allocations of the other classes do not change
+	if n, err := c.conf.changedClass(conf, name); err != nil {
+		return err
+	} else if n != "" {
+		return fmt.Errorf("update of class %q would change the allocation of class %q, full reconfiguration required", name, n)
+	}
+
+	if err := cg.configure(name, conf.Classes[name], conf.Partitions[pname], conf.Options); err != nil {
+		return fmt.Errorf("failed to update class %q: %v", name, err)
+	}
+
+	c.conf = conf
+	c.rawConf = newConfig
+	c.Infof("class %q updated", name)
+
+	return nil
+}
+
+func (c *control) swapClassAllocations(a, b string) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := checkResctrlMount(); err != nil {
+		return err
+	}
+
+	if a == b {
+		return nil
+	}
+
+	names := []string{a, b}
+	pname := ""
+	for _, name := range names {
+		cls, ok := c.conf.Classes[name]
+		if _, ok2 := c.classes[name]; !ok || !ok2 {
+			return fmt.Errorf("class %q not found in the active configuration", name)
+		}
+		if cls.MonitoringOnly {
+			return fmt.Errorf("cannot swap allocations of monitoring-only class %q", name)
+		}
+		if pname != "" && cls.Partition != pname {
+			return fmt.Errorf("cannot swap allocations of classes %q and %q in different partitions", a, b)
+		}
+		pname = cls.Partition
+	}
+
+	newConfig, classes := c.rawConf.copyPartitionClasses(pname)
+	keyA, okA := newConfig.rawClassKey(pname, a)
+	keyB, okB := newConfig.rawClassKey(pname, b)
+	if !okA || !okB {
+		return fmt.Errorf("BUG: classes %q and %q not found in the raw configuration", a, b)
+	}
+	clsA, clsB := classes[keyA], classes[keyB]
+	clsA.L2Allocation, clsB.L2Allocation = clsB.L2Allocation, clsA.L2Allocation
+	clsA.L3Allocation, clsB.L3Allocation = clsB.L3Allocation, clsA.L3Allocation
+	clsA.MBAllocation, clsB.MBAllocation = clsB.MBAllocation, clsA.MBAllocation
+	classes[keyA], classes[keyB] = clsA, clsB
+
+	conf, err := newConfig.resolve()
+	if err != nil {
+		return fmt.Errorf("failed to swap allocations of classes %q and %q: %v", a, b, err)
+	}
+	if n, err := c.conf.changedClass(conf, a, b); err != nil {
+		return err
+	} else if n != "" {
+		return fmt.Errorf("swapping allocations of classes %q and %q would change the allocation of class %q, full reconfiguration required", a, b, n)
+	}
+
+	// Write the schemata in two phases: first shrink the allocations that
+	// get smaller, then grow the ones that get bigger. This way the
+	// combined allocation of the two classes never exceeds what it was
+	// before, or what it will be after, the swap.
+	newSchemata := make(map[string][]string, len(names))
+	shrunkSchemata := make(map[string][]string, len(names))
+	for _, name := range names {
+		cur, err := c.conf.Classes[name].schemata(name, c.conf.Partitions[pname], c.conf.Options)
+		if err != nil {
+			return err
+		}
+		if newSchemata[name], err = conf.Classes[name].schemata(name, conf.Partitions[pname], conf.Options); err != nil {
+			return err
+		}
+		shrunkSchemata[name] = shrinkSchemata(cur, newSchemata[name])
+	}
+	for _, phase := range []map[string][]string{shrunkSchemata, newSchemata} {
+		for _, name := range names {
+			if err := c.classes[name].writeSchemata(phase[name]); err != nil {
+				return fmt.Errorf("failed to swap allocations of classes %q and %q: %v", a, b, err)
+			}
+		}
+	}
+
+	c.conf = conf
+	c.rawConf = newConfig
+	c.Infof("allocations of classes %q and %q swapped", a, b)
+
+	return nil
+}
+
+// shrinkSchemata returns the desired schemata lines where the domains whose
+// allocation would grow are kept at their current value.
+func shrinkSchemata(current, desired []string) []string {
+	cur := parseSchemata(strings.Join(current, ""))
+	ret := make([]string, 0, len(desired))
+	for _, line := range desired {
+		split := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		res := split[0]
+		domains := strings.Split(split[1], ";")
+		for i, d := range domains {
+			kv := strings.SplitN(d, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			curVal, ok := cur[res][kv[0]]
+			if !ok {
+				continue
+			}
+			newSize, ok1 := schemataValueSize(res, kv[1])
+			curSize, ok2 := schemataValueSize(res, curVal)
+			if !ok1 || !ok2 || newSize > curSize {
+				domains[i] = kv[0] + "=" + curVal
+			}
+		}
+		ret = append(ret, res+":"+strings.Join(domains, ";")+"\n")
+	}
+	return ret
+}
+
+// schemataValueSize returns the size of an allocation in a schemata line, the
+// number of cache ways for cache allocation and the bandwidth for memory
+// bandwidth allocation.
+func schemataValueSize(res, val string) (uint64, bool) {
+	if strings.HasPrefix(res, "MB") {
+		v, err := strconv.ParseUint(val, 10, 64)
+		return v, err == nil
+	}
+	v, err := strconv.ParseUint(val, 16, 64)
+	return uint64(bits.OnesCount64(v)), err == nil
+}
+
+func (c *control) diffConfig(desired *Config) (ConfigDiff, error) {
+	diff := ConfigDiff{Added: []string{}, Removed: []string{}, Modified: []string{}, Schemata: map[string]SchemataChange{}}
+
+	if desired == nil {
+		desired = &Config{}
+	}
+	conf, err := desired.resolve()
+	if err != nil {
+		return diff, fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	current, err := c.conf.classSchemata()
+	if err != nil {
+		return diff, err
+	}
+	next, err := conf.classSchemata()
+	if err != nil {
+		return diff, err
+	}
+
+	for name, lines := range next {
+		cur, ok := current[name]
+		switch {
+		case !ok && name == RootClassName:
+			// Root class not managed by the current configuration, its
+			// current schemata is not known
+			diff.Modified = append(diff.Modified, name)
+			diff.Schemata[name] = SchemataChange{Desired: lines}
+		case !ok:
+			diff.Added = append(diff.Added, name)
+			diff.Schemata[name] = SchemataChange{Desired: lines}
+		default:
+			change := schemataChange(cur, lines)
+			if len(change.Desired) > 0 || c.conf.partitionOf(name) != conf.partitionOf(name) {
+				diff.Modified = append(diff.Modified, name)
+			}
+			if len(change.Desired) > 0 {
+				diff.Schemata[name] = change
+			}
+		}
+	}
+	for name, lines := range current {
+		// The root class is never removed, it is left untouched if not
+		// managed by the new configuration
+		if _, ok := next[name]; !ok && name != RootClassName {
+			diff.Removed = append(diff.Removed, name)
+			diff.Schemata[name] = SchemataChange{Current: lines}
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+
+	return diff, nil
+}
+
+// classSchemata returns the schemata lines of all classes of a resolved
+// configuration, including the root class.
+func (c config) classSchemata() (map[string][]string, error) {
+	allocations := c.classAllocations()
+	ret := make(map[string][]string, len(allocations))
+	for name, a := range allocations {
+		lines, err := a.class.schemata(name, a.partition, c.Options)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = lines
+	}
+
+	for _, lines := range ret {
+		for i := range lines {
+			lines[i] = strings.TrimSuffix(lines[i], "\n")
+		}
+	}
+	return ret, nil
+}
+
+// partitionOf returns the partition of a class, or an empty string if the
+// class is not specified in the configuration.
+func (c config) partitionOf(class string) string {
+	if cls, ok := c.Classes[class]; ok {
+		return cls.Partition
+	}
+	return ""
+}
+
+// schemataChange returns the current and desired schemata lines of the
+// resources that differ.
+func schemataChange(current, desired []string) SchemataChange {
+	change := SchemataChange{}
+	cur := parseSchemata(strings.Join(current, "\n"))
+	for _, line := range desired {
+		for res, domains := range parseSchemata(line) {
+			if !schemataDomainsEqual(domains, cur[res]) {
+				change.Desired = append(change.Desired, line)
+				for _, l := range current {
+					if _, ok := parseSchemata(l)[res]; ok {
+						change.Current = append(change.Current, l)
+					}
+				}
+			}
+		}
+	}
+	return change
+}
+
+func (c *control) snapshotConfig() (*ConfigSnapshot, error) {
+	s := &ConfigSnapshot{
+		Config:  c.rawConf.deepCopy(),
+		Classes: make(map[string]ClassSnapshot, len(c.classes)),
+	}
+
+	for name, cls := range c.classes {
+		cs, err := cls.snapshot()
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot class %q: %v", name, err)
+		}
+		s.Classes[name] = cs
+	}
+
+	return s, nil
+}
+
+func (c *control) restoreConfig(s *ConfigSnapshot) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if s == nil {
+		return fmt.Errorf("no config snapshot given")
+	}
+
+	c.Infof("restoring configuration from snapshot")
+
+	// Tasks of removed groups are re-assigned from the snapshot below
+	if err := c.setConfig(s.Config, true); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(s.Classes))
+	for name := range s.Classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cs := s.Classes[name]
+		cls, ok := c.classes[name]
+		if !ok {
+			// Class was not part of the configuration, e.g. a discovered one
+			var err error
+			if cls, err = newCtrlGroup(c.resctrlGroupPrefix, c.resctrlGroupPrefix, name, c.readOnly); err != nil {
+				return err
+			}
+			c.classes[name] = cls
+		}
+		if err := cls.restore(cs); err != nil {
+			return fmt.Errorf("failed to restore class %q: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *control) migrateProcess(pid string, dst string, perThread bool) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	cls, ok := c.getClass(dst)
+	if !ok {
+		return fmt.Errorf("failed to migrate process %s: class %q not found", pid, dst)
+	}
+
+	tids := []string{pid}
+	if perThread {
+		var err error
+		if tids, err = getProcessThreads(pid); err != nil {
+			return fmt.Errorf("failed to migrate process %s to class %q: %v", pid, dst, err)
+		}
+		if len(tids) == 0 {
+			return fmt.Errorf("failed to migrate process %s to class %q: %w", pid, dst, ErrProcessExited)
+		}
+	}
+
+	if err := cls.AddPids(tids...); err != nil {
+		return fmt.Errorf("failed to migrate process %s: %w", pid, err)
+	}
+
+	// Read back the task list of the class to confirm the migration
+	assigned, err := cls.GetPids()
+	if err != nil {
+		return fmt.Errorf("failed to verify migration of process %s to class %q: %v", pid, dst, err)
+	}
+	assignedSet := make(map[string]struct{}, len(assigned))
+	for _, tid := range assigned {
+		assignedSet[tid] = struct{}{}
+	}
+	missing := []string{}
+	for _, tid := range tids {
+		if _, ok := assignedSet[tid]; ok {
+			continue
+		}
+		if !taskExists(tid) {
+			log.Debugf("task %s of process %s exited during migration", tid, pid)
+			continue
+		}
+		missing = append(missing, tid)
+	}
+	if !taskExists(pid) {
+		return fmt.Errorf("failed to migrate process %s to class %q: %w", pid, dst, ErrProcessExited)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("failed to migrate process %s to class %q: tasks %v not found in class after migration", pid, dst, missing)
+	}
+	return nil
+}
+
+// taskExists checks if a task (process or thread) with the given id exists.
+func taskExists(tid string) bool {
+	_, err := os.Stat(goresctrlpath.Path("proc", tid))
+	return err == nil
+}
+
+func (c *control) monSupported() bool {
+	return info.l3mon.Supported()
+}
+
+func (c *control) getMonFeatures() map[MonResource][]string {
+	ret := make(map[MonResource][]string)
+	if info.l3mon.Supported() {
+		ret[MonResourceL3] = append([]string{}, info.l3mon.monFeatures...)
+	}
+
+	return ret
+}
+
+func (c *control) setLogger(l grclog.Logger) {
+	c.Logger = l
+}
+
+// warn logs a warning and records it in the warnings of the ongoing
+// configuration update.
+func (c *control) warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	c.Warnf("%s", msg)
+	c.warnings = append(c.warnings, msg)
+}
+
+func (c *control) getConfigWarnings() []string {
+	return append([]string{}, c.warnings...)
+}
+
+func (c *control) setConfig(newConfig *Config, force bool) error {
+	c.Infof("configuration update")
+
+	c.warnings = []string{}
+
+	if newConfig == nil {
+		// A nil configuration is the same as an empty one
+		newConfig = &Config{}
+	}
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := checkResctrlMount(); err != nil {
+		return err
+	}
+
+	conf, err := (*newConfig).resolve()
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	if err := c.checkClosids(conf); err != nil {
+		return err
+	}
+
+	if err := c.checkRequestedClosids(conf); err != nil {
+		return err
+	}
+
+	err = c.configureResctrl(conf, force)
+	if err != nil {
+		return fmt.Errorf("resctrl configuration failed: %w", err)
+	}
+
+	c.conf = conf
+	// TODO: we'd better create a deep copy
+	c.rawConf = *newConfig
+	c.Infof("configuration finished")
+
+	return nil
+}
+
+// checkClosids verifies that there are enough CLOSIDs for all the classes of
+// a configuration. Resctrl groups not managed by us consume CLOSIDs, too.
+func (c *control) checkClosids(conf config) error {
+	if info.numClosids == 0 {
+		return nil
+	}
+
+	all, err := resctrlGroupsFromFs("", info.resctrlPath)
+	if err != nil {
+		return err
+	}
+	own, err := resctrlGroupsFromFs(c.resctrlGroupPrefix, info.resctrlPath)
+	if err != nil {
+		return err
+	}
+	other := len(all) - len(own)
+
+	// The root class always consumes one CLOSID
+	classes := len(conf.Classes)
+	if _, ok := conf.Classes[RootClassName]; !ok {
+		classes++
+	}
+
+	return verifyClosidCount(classes, other)
+}
+
+// checkResctrlGroupCount verifies that the number of control groups in the
+// resctrl filesystem does not exceed the number of CLOSIDs. Every control
+// group, including the root group, consumes one CLOSID so more groups than
+// CLOSIDs indicates an inconsistent kernel state.
+func checkResctrlGroupCount() error {
+	if info.numClosids == 0 {
+		return nil
+	}
+
+	all, err := resctrlGroupsFromFs("", info.resctrlPath)
+	if err != nil {
+		return err
+	}
+	if n := uint64(len(all)) + 1; n > info.numClosids {
+		return fmt.Errorf("inconsistent resctrl state: %d control groups found but only %d CLOSIDs available", n, info.numClosids)
+	}
+	return nil
+}
+
+// checkRequestedClosids verifies that the CLOSIDs requested in a
+// configuration are not used by other resctrl groups. The CLOSIDs of the
+// groups are only visible if resctrl is mounted with the "debug" option.
+func (c *control) checkRequestedClosids(conf config) error {
+	requested := map[uint64]string{}
+	for name, class := range conf.Classes {
+		if class.Closid != nil {
+			requested[*class.Closid] = name
+		}
+	}
+	if len(requested) == 0 {
+		return nil
+	}
+
+	groups, err := resctrlGroupsFromFs("", info.resctrlPath)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if strings.HasPrefix(g, c.resctrlGroupPrefix) {
+			name := groupDirNameToClassName(g[len(c.resctrlGroupPrefix):])
+			if _, ok := conf.Classes[name]; !ok {
+				// Stale group, removed before creating new ones
+				continue
+			}
+		}
+
+		closid, ok, err := readClosid(filepath.Join(info.resctrlPath, g))
+		if err != nil {
+			return err
+		} else if !ok {
+			log.Warnf("cannot check requested CLOSIDs, resctrl not mounted with the \"debug\" option")
+			return nil
+		}
+		if name, ok := requested[closid]; ok && g != c.resctrlGroupPrefix+classNameToGroupDirName(name) {
+			return fmt.Errorf("CLOSID %d requested for class %q already used by resctrl group %q", closid, name, g)
+		}
+	}
+	return nil
+}
+
+// readClosid reads the CLOSID of a resctrl group. The CLOSID is only
+// available if resctrl is mounted with the "debug" option.
+func readClosid(path string) (uint64, bool, error) {
+	closid, err := readFileUint64(filepath.Join(path, "ctrl_hw_id"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to read CLOSID: %v", err)
+	}
+	return closid, true, nil
+}
+
+// verifyClosidCount checks that the given number of classes and other
+// resctrl groups fit in the CLOSIDs of the system.
+func verifyClosidCount(classes, other int) error {
+	if info.numClosids == 0 {
+		return nil
+	}
+	if uint64(classes+other) <= info.numClosids {
+		return nil
+	}
+
+	available := uint64(0)
+	if uint64(other) < info.numClosids {
+		available = info.numClosids - uint64(other)
+	}
+	msg := fmt.Sprintf("configuration defines %d classes but only %d CLOSIDs are available", classes, available)
+	if other > 0 {
+		msg += fmt.Sprintf(" (%d of %d CLOSIDs used by other resctrl groups)", other, info.numClosids)
+	}
+	return fmt.Errorf("%w: %s", ErrClosidExhausted, msg)
+}
+
+func (c *control) configureResctrl(conf config, force bool) error {
+	grclog.DebugBlock(c, "applying resolved config:", "  ", "%s", utils.DumpJSON(conf))
+
+	classesFromFs, err := c.classesFromResctrlFs()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := c.classes[RootClassName]; !ok {
+		if conf.Options.StrictRootClass {
+			return ErrRootClassMissing
+		}
+		c.warn("root class missing from runtime data, re-adding...")
+		c.classes[RootClassName] = classesFromFs[RootClassName]
+	}
+
+	// Remove stale resctrl groups
+	stale := map[string]*ctrlGroup{}
+	for name, cls := range classesFromFs {
+		if _, ok := conf.Classes[cls.name]; !isRootClass(cls.name) && !ok {
+			stale[name] = cls
+		}
+	}
+
+	// Check all stale groups before removing any of them so that a refused
+	// configuration does not leave the groups partially removed
+	if !force {
+		for _, cls := range stale {
+			tasks, err := cls.GetPids()
+			if err != nil {
+				return fmt.Errorf("failed to get resctrl group tasks: %v", err)
+			}
+			if len(tasks) > 0 {
+				return fmt.Errorf("refusing to remove non-empty resctrl group %q", cls.relPath(""))
+			}
+		}
+	}
+
+	for name, cls := range stale {
+		log.Debugf("removing existing resctrl group %q", cls.relPath(""))
+		err = groupRemoveFunc(cls.path(""))
+		if err != nil {
+			return fmt.Errorf("failed to remove resctrl group %q: %v", cls.relPath(""), err)
+		}
+
+		delete(c.classes, name)
+	}
+
+	for name, cls := range c.classes {
+		if _, ok := conf.Classes[cls.name]; !ok || cls.prefix != c.resctrlGroupPrefix {
+			if !isRootClass(cls.name) {
+				log.Debugf("dropping stale class %q (%q)", name, cls.path(""))
+				delete(c.classes, name)
+			}
+		}
+	}
+
+	// Remove the groups created so far if the configuration fails, so that
+	// the filesystem is left as it was
+	created := []string{}
+	rollback := func() {
+		for _, name := range created {
+			if err := groupRemoveFunc(c.classes[name].path("")); err != nil {
+				log.Warnf("failed to remove resctrl group %q: %v", c.classes[name].relPath(""), err)
+			}
+			delete(c.classes, name)
+		}
+	}
+
+	// Try to apply given configuration. Creation order is deterministic so
+	// that the kernel assigns the same CLOSIDs on every run.
+	for _, name := range conf.Classes.creationOrder() {
+		class := conf.Classes[name]
 		if _, ok := c.classes[name]; !ok {
-			cg, err := newCtrlGroup(c.resctrlGroupPrefix, c.resctrlGroupPrefix, name)
+			cg, err := newCtrlGroup(c.resctrlGroupPrefix, c.resctrlGroupPrefix, name, c.readOnly)
 			if err != nil {
+				rollback()
+				if errors.Is(err, syscall.ENOSPC) {
+					return fmt.Errorf("%w: failed to create resctrl group for class %q: %v", ErrClosidExhausted, name, err)
+				}
 				return err
 			}
 			c.classes[name] = cg
+			created = append(created, name)
+		}
+		if err := c.classes[name].verifyClosid(class.Closid); err != nil {
+			rollback()
+			return err
 		}
 		partition := conf.Partitions[class.Partition]
 		if err := c.classes[name].configure(name, class, partition, conf.Options); err != nil {
+			rollback()
+			return err
+		}
+	}
+
+	// Reset the root class to full allocation in case of an empty
+	// configuration. Otherwise an unconfigured root class is left untouched.
+	if conf.resetsRootClass() {
+		a, _ := conf.classAllocation(RootClassName)
+		if err := c.classes[RootClassName].configure(RootClassName, a.class, a.partition, conf.Options); err != nil {
 			return err
 		}
 	}
@@ -441,6 +1814,10 @@ func (c *control) discoverFromResctrl(prefix string) error {
 		}
 	}
 
+	if c.readOnly {
+		return nil
+	}
+
 	if err := c.pruneMonGroups(); err != nil {
 		return err
 	}
@@ -464,13 +1841,13 @@ func (c *control) classesFromResctrlFsPrefix(prefix string) (map[string]*ctrlGro
 				// Skip groups in the standard namespace
 				continue
 			}
-			names = append(names, n[len(prefix):])
+			names = append(names, groupDirNameToClassName(n[len(prefix):]))
 		}
 	}
 
 	classes := make(map[string]*ctrlGroup, len(names)+1)
 	for _, name := range names {
-		g, err := newCtrlGroup(prefix, c.resctrlGroupPrefix, name)
+		g, err := newCtrlGroup(prefix, c.resctrlGroupPrefix, name, c.readOnly)
 		if err != nil {
 			return nil, err
 		}
@@ -489,19 +1866,19 @@ func (c *control) pruneMonGroups() error {
 	return nil
 }
 
-func (c *control) readRdtFile(rdtPath string) ([]byte, error) {
+func readRdtFile(rdtPath string) ([]byte, error) {
 	return os.ReadFile(filepath.Join(info.resctrlPath, rdtPath))
 }
 
-func (c *control) writeRdtFile(rdtPath string, data []byte) error {
+func writeRdtFile(rdtPath string, data []byte) error {
 	if err := os.WriteFile(filepath.Join(info.resctrlPath, rdtPath), data, 0644); err != nil {
-		return c.cmdError(err)
+		return cmdError(err)
 	}
 	return nil
 }
 
-func (c *control) cmdError(origErr error) error {
-	errData, readErr := c.readRdtFile(filepath.Join("info", "last_cmd_status"))
+func cmdError(origErr error) error {
+	errData, readErr := readRdtFile(filepath.Join("info", "last_cmd_status"))
 	if readErr != nil {
 		return origErr
 	}
@@ -512,14 +1889,16 @@ func (c *control) cmdError(origErr error) error {
 	return origErr
 }
 
-func newCtrlGroup(prefix, monPrefix, name string) (*ctrlGroup, error) {
+func newCtrlGroup(prefix, monPrefix, name string, readOnly bool) (*ctrlGroup, error) {
 	cg := &ctrlGroup{
-		resctrlGroup: resctrlGroup{prefix: prefix, name: name},
+		resctrlGroup: resctrlGroup{prefix: prefix, name: name, readOnly: readOnly},
 		monPrefix:    monPrefix,
 	}
 
-	if err := os.Mkdir(cg.path(""), 0755); err != nil && !os.IsExist(err) {
-		return nil, err
+	if !readOnly {
+		if err := groupCreateFunc(cg.path(""), 0755); err != nil && !os.IsExist(err) {
+			return nil, err
+		}
 	}
 
 	var err error
@@ -531,23 +1910,55 @@ func newCtrlGroup(prefix, monPrefix, name string) (*ctrlGroup, error) {
 	return cg, nil
 }
 
+// monGroupAnnotations returns the annotations of a monitoring group. Group
+// specific annotations override the class defaults.
+func (c *ctrlGroup) monGroupAnnotations(annotations map[string]string) map[string]string {
+	merged := make(map[string]string, len(c.annotations)+len(annotations))
+	for k, v := range c.annotations {
+		merged[k] = v
+	}
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	return merged
+}
+
 func (c *ctrlGroup) CreateMonGroup(name string, annotations map[string]string) (MonGroup, error) {
 	if mg, ok := c.monGroups[name]; ok {
 		return mg, nil
 	}
 
+	if c.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	merged := c.monGroupAnnotations(annotations)
+
 	log.Debugf("creating monitoring group %s/%s", c.name, name)
-	mg, err := newMonGroup(c.monPrefix, name, c, annotations)
+	mg, err := newMonGroup(c.monPrefix, name, c, merged)
 	if err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			return nil, fmt.Errorf("failed to create new monitoring group %q: %w (%d available)", name, ErrRmidExhausted, info.l3mon.numRmids)
+		}
 		return nil, fmt.Errorf("failed to create new monitoring group %q: %v", name, err)
 	}
 
 	c.monGroups[name] = mg
 
+	if used, err := getRmidsInUse(); err != nil {
+		log.Debugf("failed to count RMIDs in use: %v", err)
+	} else if total := info.l3mon.numRmids; total > 0 && float64(used) > RmidPressureThreshold*float64(total) {
+		log.Warnf("%d of %d RMIDs in use, creation of new monitoring groups will fail soon", used, total)
+	}
+
 	return mg, err
 }
 
 func (c *ctrlGroup) DeleteMonGroup(name string) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
 	mg, ok := c.monGroups[name]
 	if !ok {
 		log.Warnf("trying to delete non-existent mon group %s/%s", c.name, name)
@@ -573,11 +1984,166 @@ func (c *ctrlGroup) DeleteMonGroups() error {
 	return nil
 }
 
+func (c *ctrlGroup) snapshot() (ClassSnapshot, error) {
+	s := ClassSnapshot{MonGroups: make(map[string]MonGroupSnapshot, len(c.monGroups))}
+
+	data, err := readRdtFile(c.relPath("schemata"))
+	if err != nil {
+		return s, err
+	}
+	s.Schemata = string(data)
+
+	if s.Tasks, err = c.GetPids(); err != nil {
+		return s, err
+	}
+
+	for name, mg := range c.monGroups {
+		tasks, err := mg.GetPids()
+		if err != nil {
+			return s, err
+		}
+		s.MonGroups[name] = MonGroupSnapshot{Annotations: mg.GetAnnotations(), Tasks: tasks}
+	}
+
+	return s, nil
+}
+
+func (c *ctrlGroup) restore(s ClassSnapshot) error {
+	lines := []string{}
+	for _, line := range strings.SplitAfter(s.Schemata, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if changed := c.changedSchemata(lines); len(changed) > 0 {
+		data := strings.Join(changed, "")
+		log.Debugf("restoring schemata %q of %q", data, c.relPath(""))
+		if err := writeRdtFile(c.relPath("schemata"), []byte(data)); err != nil {
+			return err
+		}
+	}
+
+	for name := range c.monGroups {
+		if _, ok := s.MonGroups[name]; !ok {
+			if err := c.DeleteMonGroup(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Assign the tasks of the class before those of the monitoring groups,
+	// as assigning a task to the class moves it out of its monitoring group
+	if len(s.Tasks) > 0 {
+		if err := c.AddPids(s.Tasks...); err != nil {
+			return err
+		}
+	}
+
+	for name, ms := range s.MonGroups {
+		mg, err := c.CreateMonGroup(name, ms.Annotations)
+		if err != nil {
+			return err
+		}
+		if len(ms.Tasks) > 0 {
+			if err := mg.AddPids(ms.Tasks...); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *ctrlGroup) AddCgroup(cgroupPath string) (int, error) {
+	if c.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	if !filepath.IsAbs(cgroupPath) {
+		cgroupPath = filepath.Join(cgroupMountPath, cgroupPath)
+	}
+	cgroupPath = goresctrlpath.Path(cgroupPath)
+
+	f, err := os.OpenFile(c.path("tasks"), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	moved := map[string]struct{}{}
+	for i := 0; i < maxCgroupRetries; i++ {
+		tids, err := getCgroupThreads(cgroupPath)
+		if err != nil {
+			return len(moved), fmt.Errorf("failed to assign cgroup %q to class %q: %v", cgroupPath, c.name, err)
+		}
+
+		pending := make([]string, 0, len(tids))
+		for _, tid := range tids {
+			if _, ok := moved[tid]; !ok {
+				pending = append(pending, tid)
+			}
+		}
+		if len(pending) == 0 {
+			return len(moved), nil
+		}
+
+		failed, err := writeTasks(f, pending)
+		if len(failed) > 0 {
+			return len(moved), fmt.Errorf("failed to assign tasks %v of cgroup %q to class %q: %v", failed, cgroupPath, c.name, cmdError(err))
+		}
+		for _, tid := range pending {
+			moved[tid] = struct{}{}
+		}
+	}
+
+	log.Warnf("tasks of cgroup %q still changing after %d attempts to assign them to class %q", cgroupPath, maxCgroupRetries, c.name)
+	return len(moved), nil
+}
+
+const (
+	// cgroupMountPath is the mount point of the unified cgroup hierarchy.
+	cgroupMountPath = "/sys/fs/cgroup"
+	// maxCgroupRetries is the maximum number of times the tasks of a
+	// cgroup are re-read in AddCgroup.
+	maxCgroupRetries = 5
+)
+
+// getCgroupThreads returns the thread ids of all tasks in a cgroup. The
+// threads are read from cgroup.threads, or from /proc for each process in
+// cgroup.procs if the former is not available (cgroup v1).
+func getCgroupThreads(cgroupPath string) ([]string, error) {
+	if data, err := os.ReadFile(filepath.Join(cgroupPath, "cgroup.threads")); err == nil {
+		return strings.Fields(string(data)), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(cgroupPath, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	tids := []string{}
+	for _, pid := range strings.Fields(string(data)) {
+		t, err := getProcessThreads(pid)
+		if err != nil {
+			return nil, err
+		}
+		tids = append(tids, t...)
+	}
+	return tids, nil
+}
+
 func (c *ctrlGroup) GetMonGroup(name string) (MonGroup, bool) {
 	mg, ok := c.monGroups[name]
 	return mg, ok
 }
 
+func (c *ctrlGroup) GetClassTotalMonData() (MonData, error) {
+	// The kernel sums up the counters of the child monitoring groups into
+	// the monitoring data of a CTRL_MON group
+	return c.GetMonDataChecked()
+}
+
 func (c *ctrlGroup) GetMonGroups() []MonGroup {
 	ret := make([]MonGroup, 0, len(c.monGroups))
 
@@ -589,58 +2155,131 @@ func (c *ctrlGroup) GetMonGroups() []MonGroup {
 	return ret
 }
 
+// verifyClosid checks that the group has the requested CLOSID, if any.
+func (c *ctrlGroup) verifyClosid(requested *uint64) error {
+	if requested == nil {
+		return nil
+	}
+
+	closid, ok, err := readClosid(c.path(""))
+	if err != nil {
+		return err
+	} else if !ok {
+		log.Warnf("cannot verify CLOSID of class %q, resctrl not mounted with the \"debug\" option", c.name)
+		return nil
+	}
+	if closid != *requested {
+		return fmt.Errorf("class %q has CLOSID %d instead of the requested %d", c.name, closid, *requested)
+	}
+	return nil
+}
+
 func (c *ctrlGroup) configure(name string, class *classConfig,
 	partition *partitionConfig, options Options) error {
-	schemata := ""
+	schemata, err := class.schemata(name, partition, options)
+	if err != nil {
+		return err
+	}
 
-	// Handle cache allocation
-	for _, lvl := range []cacheLevel{L2, L3} {
-		switch {
-		case info.cat[lvl].unified.Supported():
-			schema, err := class.CATSchema[lvl].toStr(catSchemaTypeUnified, partition.CAT[lvl])
-			if err != nil {
-				return err
-			}
-			schemata += schema
-		case info.cat[lvl].data.Supported() || info.cat[lvl].code.Supported():
-			schema, err := class.CATSchema[lvl].toStr(catSchemaTypeCode, partition.CAT[lvl])
-			if err != nil {
-				return err
-			}
-			schemata += schema
+	c.annotations = class.Annotations
 
-			schema, err = class.CATSchema[lvl].toStr(catSchemaTypeData, partition.CAT[lvl])
-			if err != nil {
-				return err
-			}
-			schemata += schema
-		default:
-			if class.CATSchema[lvl].Alloc != nil && !options.cat(lvl).Optional {
-				return fmt.Errorf("%s cache allocation for %q specified in configuration but not supported by system", lvl, name)
-			}
+	c.staticMonGroups = make(map[string]struct{}, len(class.MonGroups))
+	for mgName, opts := range class.MonGroups {
+		if _, err := c.CreateMonGroup(mgName, opts.Annotations); err != nil {
+			return err
 		}
+		// Annotations of an existing group follow the configuration
+		c.monGroups[mgName].annotations = c.monGroupAnnotations(opts.Annotations)
+		c.staticMonGroups[mgName] = struct{}{}
+	}
+
+	return c.writeSchemata(schemata)
+}
+
+// writeSchemata writes the schemata lines that differ from the currently
+// active schemata of the group.
+func (c *ctrlGroup) writeSchemata(schemata []string) error {
+	if len(schemata) == 0 {
+		log.Debugf("empty schemata")
+		return nil
+	}
+
+	// Only write the resources that changed, re-writing unchanged cache
+	// allocations may cause needless disruption (e.g. cache flushes)
+	changed := c.changedSchemata(schemata)
+	if len(changed) == 0 {
+		log.Debugf("schemata of %q unchanged", c.relPath(""))
+		return nil
+	}
+
+	data := strings.Join(changed, "")
+	log.Debugf("writing schemata %q to %q", data, c.relPath(""))
+	if err := writeRdtFile(c.relPath("schemata"), []byte(data)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// changedSchemata returns the schemata lines that differ from the currently
+// active schemata of the group. All lines are returned if the current
+// schemata cannot be read.
+func (c *ctrlGroup) changedSchemata(schemata []string) []string {
+	data, err := readRdtFile(c.relPath("schemata"))
+	if err != nil {
+		log.Debugf("failed to read current schemata of %q: %v", c.relPath(""), err)
+		return schemata
 	}
+	current := parseSchemata(string(data))
 
-	// Handle memory bandwidth allocation
-	switch {
-	case info.mb.Supported():
-		schemata += class.MBSchema.toStr(partition.MB)
-	default:
-		if class.MBSchema != nil && !options.MB.Optional {
-			return fmt.Errorf("memory bandwidth allocation for %q specified in configuration but not supported by system", name)
+	changed := []string{}
+	for _, line := range schemata {
+		for res, domains := range parseSchemata(line) {
+			if !schemataDomainsEqual(domains, current[res]) {
+				changed = append(changed, line)
+			}
 		}
 	}
+	return changed
+}
 
-	if len(schemata) > 0 {
-		log.Debugf("writing schemata %q to %q", schemata, c.relPath(""))
-		if err := rdt.writeRdtFile(c.relPath("schemata"), []byte(schemata)); err != nil {
-			return err
+// parseSchemata parses schemata data into a map of resource name -> domain
+// id -> value. Values are normalized (lower-case, no leading zeros) so that
+// data read from resctrl can be compared with what we would write.
+func parseSchemata(data string) map[string]map[string]string {
+	ret := map[string]map[string]string{}
+	for _, line := range strings.Split(data, "\n") {
+		split := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(split) != 2 {
+			continue
 		}
-	} else {
-		log.Debugf("empty schemata")
+		domains := map[string]string{}
+		for _, d := range strings.Split(split[1], ";") {
+			kv := strings.SplitN(strings.TrimSpace(d), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			val := strings.TrimLeft(strings.ToLower(strings.TrimSpace(kv[1])), "0")
+			if val == "" {
+				val = "0"
+			}
+			domains[strings.TrimSpace(kv[0])] = val
+		}
+		ret[strings.TrimSpace(split[0])] = domains
 	}
+	return ret
+}
 
-	return nil
+func schemataDomainsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, val := range a {
+		if b[id] != val {
+			return false
+		}
+	}
+	return true
 }
 
 func (c *ctrlGroup) monGroupsFromResctrlFs() (map[string]*monGroup, error) {
@@ -664,6 +2303,9 @@ func (c *ctrlGroup) monGroupsFromResctrlFs() (map[string]*monGroup, error) {
 // Remove empty monitoring groups
 func (c *ctrlGroup) pruneMonGroups() error {
 	for name, mg := range c.monGroups {
+		if _, ok := c.staticMonGroups[name]; ok {
+			continue
+		}
 		pids, err := mg.GetPids()
 		if err != nil {
 			return fmt.Errorf("failed to get pids for monitoring group %q: %v", mg.relPath(""), err)
@@ -682,7 +2324,7 @@ func (r *resctrlGroup) Name() string {
 }
 
 func (r *resctrlGroup) GetPids() ([]string, error) {
-	data, err := rdt.readRdtFile(r.relPath("tasks"))
+	data, err := readRdtFile(r.relPath("tasks"))
 	if err != nil {
 		return []string{}, err
 	}
@@ -693,23 +2335,149 @@ func (r *resctrlGroup) GetPids() ([]string, error) {
 	return []string{}, nil
 }
 
+func (r *resctrlGroup) GetPidsWithComm() (map[string]string, error) {
+	pids, err := r.GetPids()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string, len(pids))
+	for _, pid := range pids {
+		comm, err := os.ReadFile(goresctrlpath.Path("proc", pid, "comm"))
+		if err != nil {
+			log.Debugf("failed to read command name of process %s: %v", pid, err)
+			continue
+		}
+		ret[pid] = strings.TrimSpace(string(comm))
+	}
+	return ret, nil
+}
+
+func (r *resctrlGroup) AddPidsPerThread(pids ...string) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
+
+	tids := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		t, err := getProcessThreads(pid)
+		if err != nil {
+			return fmt.Errorf("failed to assign process %s to class %q: %v", pid, r.name, err)
+		}
+		tids = append(tids, t...)
+	}
+
+	return r.AddPids(tids...)
+}
+
+// getProcessThreads returns the thread ids of a process. An empty list is
+// returned if the process does not exist (anymore).
+func getProcessThreads(pid string) ([]string, error) {
+	entries, err := os.ReadDir(goresctrlpath.Path("proc", pid, "task"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Debugf("no process %s", pid)
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read threads: %v", err)
+	}
+
+	tids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		tids = append(tids, e.Name())
+	}
+	return tids, nil
+}
+
 func (r *resctrlGroup) AddPids(pids ...string) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
+
 	f, err := os.OpenFile(r.path("tasks"), os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	for _, pid := range pids {
-		if _, err := f.WriteString(pid + "\n"); err != nil {
+	if failed, err := writeTasks(f, pids); len(failed) > 0 {
+		return fmt.Errorf("failed to assign processes %v to class %q: %v", failed, r.name, cmdError(err))
+	}
+	return nil
+}
+
+func (r *resctrlGroup) RemovePids(pids ...string) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
+
+	f, err := os.OpenFile(filepath.Join(info.resctrlPath, "tasks"), os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if failed, err := writeTasks(f, pids); len(failed) > 0 {
+		return fmt.Errorf("failed to remove processes %v from class %q: %v", failed, r.name, cmdError(err))
+	}
+	return nil
+}
+
+// maxTasksWriteSize is the maximum number of bytes written to a resctrl
+// tasks file at once. The kernel does not accept writes longer than a page.
+const maxTasksWriteSize = 4096
+
+// writeTasks writes task ids into a resctrl tasks file. The ids are written
+// in comma-separated batches. If a batch fails, e.g. because one of the tasks
+// does not exist anymore or because the kernel does not support batched
+// writes, the ids of the batch are re-written one by one. Non-existent tasks
+// are ignored. Returns the ids that could not be written and the last error.
+func writeTasks(w io.Writer, pids []string) ([]string, error) {
+	failed := []string{}
+	var lastErr error
+
+	writeBatch := func(batch []string, buf []byte) {
+		if _, err := w.Write(buf); err == nil {
+			return
+		} else if len(batch) == 1 {
 			if errors.Is(err, syscall.ESRCH) {
-				log.Debugf("no task %s", pid)
+				log.Debugf("no task %s", batch[0])
 			} else {
-				return fmt.Errorf("failed to assign processes %v to class %q: %v", pids, r.name, rdt.cmdError(err))
+				failed = append(failed, batch[0])
+				lastErr = err
+			}
+			return
+		}
+		for _, pid := range batch {
+			if _, err := io.WriteString(w, pid+"\n"); err != nil {
+				if errors.Is(err, syscall.ESRCH) {
+					log.Debugf("no task %s", pid)
+				} else {
+					failed = append(failed, pid)
+					lastErr = err
+				}
 			}
 		}
 	}
-	return nil
+
+	buf := make([]byte, 0, maxTasksWriteSize)
+	first := 0
+	for i, pid := range pids {
+		if len(buf) > 0 && len(buf)+len(pid)+1 > maxTasksWriteSize {
+			buf[len(buf)-1] = '\n'
+			writeBatch(pids[first:i], buf)
+			buf = buf[:0]
+			first = i
+		}
+		buf = append(buf, pid...)
+		buf = append(buf, ',')
+	}
+	if len(buf) > 0 {
+		buf[len(buf)-1] = '\n'
+		writeBatch(pids[first:], buf)
+	}
+
+	return failed, lastErr
 }
 
 func (r *resctrlGroup) GetMonData() MonData {
@@ -727,6 +2495,62 @@ func (r *resctrlGroup) GetMonData() MonData {
 	return m
 }
 
+func (r *resctrlGroup) GetMonDataChecked() (MonData, error) {
+	if !info.l3mon.Supported() {
+		return MonData{}, ErrMonitoringUnsupported
+	}
+
+	l3, err := r.getMonL3Data()
+	if err != nil {
+		return MonData{}, fmt.Errorf("failed to retrieve L3 monitoring data: %v", err)
+	}
+
+	return MonData{L3: l3}, nil
+}
+
+func (r *resctrlGroup) GetMonDataAggregated() MonData {
+	m := r.GetMonData()
+	if m.L3 == nil {
+		return m
+	}
+
+	packages, err := getMonL3Packages()
+	if err != nil {
+		log.Warnf("failed to aggregate L3 monitoring data: %v", err)
+		return m
+	}
+
+	m.L3Package = MonL3Data{}
+	for id, data := range m.L3 {
+		pkg, ok := packages[id]
+		if !ok {
+			log.Warnf("failed to aggregate L3 monitoring data: unknown package of cache id %d", id)
+			m.L3Package = nil
+			return m
+		}
+		if m.L3Package[pkg] == nil {
+			m.L3Package[pkg] = MonLeafData{}
+		}
+		for name, val := range data {
+			m.L3Package[pkg][name] += val
+		}
+	}
+
+	return m
+}
+
+func (r *resctrlGroup) GetMonDataForCacheID(id uint64) (MonLeafData, error) {
+	if !info.l3mon.Supported() {
+		return nil, fmt.Errorf("L3 monitoring not supported")
+	}
+
+	data, err := r.getMonLeafData(filepath.Join("mon_data", fmt.Sprintf("mon_L3_%02d", id)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read L3 monitoring data of cache id %d: %v", id, err)
+	}
+	return data, nil
+}
+
 func (r *resctrlGroup) getMonL3Data() (MonL3Data, error) {
 	files, err := os.ReadDir(r.path("mon_data"))
 	if err != nil {
@@ -787,7 +2611,7 @@ func (r *resctrlGroup) relPath(elem ...string) string {
 		if r.name == RootClassName {
 			return filepath.Join(elem...)
 		}
-		return filepath.Join(append([]string{r.prefix + r.name}, elem...)...)
+		return filepath.Join(append([]string{r.prefix + classNameToGroupDirName(r.name)}, elem...)...)
 	}
 	// Parent is only intended for MON groups - non-root CTRL groups are considered
 	// as peers to the root CTRL group (as they are in HW) and do not have a parent
@@ -798,13 +2622,50 @@ func (r *resctrlGroup) path(elem ...string) string {
 	return filepath.Join(info.resctrlPath, r.relPath(elem...))
 }
 
+// getRmidsInUse returns the number of RMIDs in use in the system. Every ctrl
+// group (including the root) and every monitoring group consumes one RMID.
+func getRmidsInUse() (uint64, error) {
+	ctrlGroups := []string{info.resctrlPath}
+
+	entries, err := os.ReadDir(info.resctrlPath)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		switch name := e.Name(); {
+		case !e.IsDir(), name == "info", name == "mon_groups", name == "mon_data":
+		default:
+			ctrlGroups = append(ctrlGroups, filepath.Join(info.resctrlPath, name))
+		}
+	}
+
+	used := uint64(len(ctrlGroups))
+	for _, path := range ctrlGroups {
+		entries, err := os.ReadDir(filepath.Join(path, "mon_groups"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return 0, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				used++
+			}
+		}
+	}
+	return used, nil
+}
+
 func newMonGroup(prefix string, name string, parent *ctrlGroup, annotations map[string]string) (*monGroup, error) {
 	mg := &monGroup{
-		resctrlGroup: resctrlGroup{prefix: prefix, name: name, parent: parent},
+		resctrlGroup: resctrlGroup{prefix: prefix, name: name, parent: parent, readOnly: parent.readOnly},
 		annotations:  make(map[string]string, len(annotations))}
 
-	if err := os.Mkdir(mg.path(""), 0755); err != nil && !os.IsExist(err) {
-		return nil, err
+	if !mg.readOnly {
+		if err := os.Mkdir(mg.path(""), 0755); err != nil && !os.IsExist(err) {
+			return nil, err
+		}
 	}
 	for k, v := range annotations {
 		mg.annotations[k] = v
@@ -853,3 +2714,34 @@ func unaliasClassName(name string) string {
 	}
 	return name
 }
+
+// namespacedClassName returns the name of a class in the namespace of a
+// partition.
+func namespacedClassName(partition, class string) string {
+	return partition + "/" + class
+}
+
+var (
+	groupDirNameEscaper   = strings.NewReplacer("%", "%25", "/", "%2F")
+	groupDirNameUnescaper = strings.NewReplacer("%25", "%", "%2F", "/")
+)
+
+// classNameToGroupDirName converts a class name into a name usable as a
+// resctrl group directory. Namespaced class names contain a '/' which is
+// escaped. Other class names are used as such so that the directories of
+// existing classes do not change.
+func classNameToGroupDirName(name string) string {
+	if !strings.Contains(name, "/") {
+		return name
+	}
+	return groupDirNameEscaper.Replace(name)
+}
+
+// groupDirNameToClassName is the inverse of classNameToGroupDirName. Only
+// directory names of namespaced classes are unescaped.
+func groupDirNameToClassName(name string) string {
+	if n := groupDirNameUnescaper.Replace(name); strings.Contains(n, "/") && groupDirNameEscaper.Replace(n) == name {
+		return n
+	}
+	return name
+}
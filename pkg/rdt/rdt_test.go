@@ -17,20 +17,29 @@ limitations under the License.
 package rdt
 
 import (
+	"errors"
+	"fmt"
 	stdlog "log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"sigs.k8s.io/yaml"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 
 	grclog "github.com/intel/goresctrl/pkg/log"
+	goresctrlpath "github.com/intel/goresctrl/pkg/path"
 	"github.com/intel/goresctrl/pkg/testutils"
 	"github.com/intel/goresctrl/pkg/utils"
 	testdata "github.com/intel/goresctrl/test/data"
@@ -272,11 +281,22 @@ partitions:
 
 	// Verify that ctrl groups are correctly configured
 	mockFs.verifyTextFile(rdt.classes["BestEffort"].relPath("schemata"),
-		"L3:0=3f;1=3f;2=3f;3=3f\nMB:0=33;1=33;2=33;3=33\n")
+		"L3:0=3f;1=3f;2=3f;3=3f\nMB:0=30;1=30;2=30;3=30\n")
 	mockFs.verifyTextFile(rdt.classes["Burstable"].relPath("schemata"),
-		"L3:0=ff;1=ff;2=ff;3=ff\nMB:0=66;1=66;2=66;3=66\n")
+		"L3:0=ff;1=ff;2=ff;3=ff\nMB:0=70;1=70;2=70;3=70\n")
+	// Only the changed L3 line should have been written to the pre-existing group
 	mockFs.verifyTextFile(rdt.classes["Guaranteed"].relPath("schemata"),
-		"L3:0=fff00;1=fff00;2=fff00;3=fff00\nMB:0=100;1=100;2=100;3=100\n")
+		"L3:0=fff00;1=fff00;2=fff00;3=fff00\n")
+
+	// Re-applying the same configuration should not write anything
+	guaranteedSchemata := "    L3:0=fff00;1=fff00;2=fff00;3=fff00\n    MB:0=100;1=100;2=100;3=100\n"
+	if err := os.WriteFile(rdt.classes["Guaranteed"].path("schemata"), []byte(guaranteedSchemata), 0644); err != nil {
+		t.Fatalf("failed to write schemata: %v", err)
+	}
+	if err := SetConfigFromFile(testConfigFile, false); err != nil {
+		t.Fatalf("rdt re-configuration failed: %v", err)
+	}
+	mockFs.verifyTextFile(rdt.classes["Guaranteed"].relPath("schemata"), guaranteedSchemata)
 
 	// Verify that existing goresctrl monitor groups were removed
 	for _, cls := range []string{RootClassName, "Guaranteed"} {
@@ -302,13 +322,63 @@ partitions:
 	if err := cls.AddPids(pids...); err != nil {
 		t.Errorf("AddPids() failed: %v", err)
 	}
+	mockFs.verifyTextFile(rdt.classes["Guaranteed"].relPath("tasks"), "10,11,12\n")
+
+	// The kernel lists the tasks one per line
+	if err := os.WriteFile(rdt.classes["Guaranteed"].path("tasks"), []byte("10\n11\n12\n"), 0644); err != nil {
+		t.Fatalf("failed to write tasks: %v", err)
+	}
 	if p, err := cls.GetPids(); err != nil {
 		t.Errorf("GetPids() failed: %v", err)
 	} else if !cmp.Equal(p, pids) {
 		t.Errorf("GetPids() returned %s, expected %s", p, pids)
 	}
 
-	mockFs.verifyTextFile(rdt.classes["Guaranteed"].relPath("tasks"), "10\n11\n12\n")
+	// Verify resolving command names, process 12 without comm is omitted
+	commRoot := t.TempDir()
+	for pid, comm := range map[string]string{"10": "foo", "11": "bar"} {
+		if err := os.MkdirAll(filepath.Join(commRoot, "proc", pid), 0755); err != nil {
+			t.Fatalf("failed to create mock procfs: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(commRoot, "proc", pid, "comm"), []byte(comm+"\n"), 0644); err != nil {
+			t.Fatalf("failed to create mock procfs: %v", err)
+		}
+	}
+	goresctrlpath.SetPrefix(commRoot)
+	if p, err := cls.(CtrlGroupExt).GetPidsWithComm(); err != nil {
+		t.Errorf("GetPidsWithComm() failed: %v", err)
+	} else {
+		testutils.VerifyDeepEqual(t, "pids with comm", map[string]string{"10": "foo", "11": "bar"}, p)
+	}
+	goresctrlpath.SetPrefix("/")
+
+	// Verify assigning all threads of processes, non-existent process 30
+	// is ignored
+	procRoot := t.TempDir()
+	for _, tid := range []string{"20", "21", "22"} {
+		if err := os.MkdirAll(filepath.Join(procRoot, "proc/20/task", tid), 0755); err != nil {
+			t.Fatalf("failed to create mock procfs: %v", err)
+		}
+	}
+	goresctrlpath.SetPrefix(procRoot)
+	if err := os.WriteFile(rdt.classes["Guaranteed"].path("tasks"), nil, 0644); err != nil {
+		t.Fatalf("failed to reset tasks: %v", err)
+	}
+	if err := cls.(CtrlGroupExt).AddPidsPerThread("20", "30"); err != nil {
+		t.Errorf("AddPidsPerThread() failed: %v", err)
+	}
+	goresctrlpath.SetPrefix("/")
+	mockFs.verifyTextFile(rdt.classes["Guaranteed"].relPath("tasks"), "20,21,22\n")
+
+	// Verify removing pids, i.e. moving them back to the root group
+	rootTasks := filepath.Join(mockFs.baseDir, "resctrl", "tasks")
+	if err := os.WriteFile(rootTasks, nil, 0644); err != nil {
+		t.Fatalf("failed to reset root tasks: %v", err)
+	}
+	if err := cls.(CtrlGroupExt).RemovePids("20", "21"); err != nil {
+		t.Errorf("RemovePids() failed: %v", err)
+	}
+	mockFs.verifyTextFile("tasks", "20,21\n")
 
 	// Verify MonSupported and GetMonFeatures
 	if !MonSupported() {
@@ -430,6 +500,60 @@ partitions:
 		t.Errorf("unexcpected monitoring data\nexpected:\n%s\nreceived:\n%s", utils.DumpJSON(expected), utils.DumpJSON(md))
 	}
 
+	if ld, err := mg.(ResctrlGroupExt).GetMonDataForCacheID(2); err != nil {
+		t.Errorf("GetMonDataForCacheID() failed: %v", err)
+	} else if !cmp.Equal(ld, expected.L3[2]) {
+		t.Errorf("unexcpected monitoring data\nexpected:\n%s\nreceived:\n%s", utils.DumpJSON(expected.L3[2]), utils.DumpJSON(ld))
+	}
+	if _, err := mg.(ResctrlGroupExt).GetMonDataForCacheID(7); err == nil {
+		t.Errorf("GetMonDataForCacheID() for non-existent cache id did not fail")
+	}
+
+	if md, err := mg.(ResctrlGroupExt).GetMonDataChecked(); err != nil {
+		t.Errorf("GetMonDataChecked() failed: %v", err)
+	} else if !cmp.Equal(md, expected) {
+		t.Errorf("unexcpected monitoring data\nexpected:\n%s\nreceived:\n%s", utils.DumpJSON(expected), utils.DumpJSON(md))
+	}
+	// Class total is what the kernel reports for the class itself
+	if total, err := cls.(CtrlGroupExt).GetClassTotalMonData(); err != nil {
+		t.Errorf("GetClassTotalMonData() failed: %v", err)
+	} else if !cmp.Equal(total, cls.GetMonData()) {
+		t.Errorf("unexpected class total monitoring data\nexpected:\n%s\nreceived:\n%s", utils.DumpJSON(cls.GetMonData()), utils.DumpJSON(total))
+	}
+	l3mon := info.l3mon
+	info.l3mon = l3MonInfo{}
+	if _, err := mg.(ResctrlGroupExt).GetMonDataChecked(); !errors.Is(err, ErrMonitoringUnsupported) {
+		t.Errorf("expected ErrMonitoringUnsupported from GetMonDataChecked(), got %v", err)
+	}
+	if _, err := cls.(CtrlGroupExt).GetClassTotalMonData(); !errors.Is(err, ErrMonitoringUnsupported) {
+		t.Errorf("expected ErrMonitoringUnsupported from GetClassTotalMonData(), got %v", err)
+	}
+	info.l3mon = l3mon
+
+	// Verify aggregation of monitoring data with SNC enabled: two NUMA
+	// nodes per package, sharing one L3 cache
+	sysfsRoot := mockNodeSysfs(t, [][2]string{{"0", "0"}, {"0", "0"}, {"1", "1"}, {"1", "1"}})
+	defer os.RemoveAll(sysfsRoot)
+	goresctrlpath.SetPrefix(sysfsRoot)
+	defer goresctrlpath.SetPrefix("/")
+
+	expected.L3Package = MonL3Data{
+		0: MonLeafData{
+			"llc_occupancy":   12,
+			"mbm_local_bytes": 14,
+			"mbm_total_bytes": 16,
+		},
+		1: MonLeafData{
+			"llc_occupancy":   52,
+			"mbm_local_bytes": 54,
+			"mbm_total_bytes": 56,
+		},
+	}
+	md = mg.(ResctrlGroupExt).GetMonDataAggregated()
+	if !cmp.Equal(md, expected) {
+		t.Errorf("unexcpected aggregated monitoring data\nexpected:\n%s\nreceived:\n%s", utils.DumpJSON(expected), utils.DumpJSON(md))
+	}
+
 	//
 	// 3. Test discovery
 	//
@@ -555,7 +679,7 @@ partitions:
 				},
 				"class-3": Schemata{
 					l3: "0=7f000;1=7ff;2=7f;3=7f000",
-					mb: "0=40;1=32;2=40;3=20",
+					mb: "0=40;1=30;2=40;3=20",
 				},
 				"class-4": Schemata{
 					l3: "0=f000;1=3f;2=f;3=f000",
@@ -563,7 +687,7 @@ partitions:
 				},
 				"system/default": Schemata{
 					l3: "0=1f000;1=7f;2=1f;3=1f000",
-					mb: "0=30;1=48;2=60;3=30",
+					mb: "0=30;1=50;2=60;3=30",
 				},
 				"class-5": Schemata{
 					l3: "0=80000;1=800;2=180;3=80000",
@@ -692,264 +816,322 @@ partitions:
 		},
 		// Testcase
 		TC{
-			name: "Default L3 CAT",
-			fs:   "resctrl.full",
+			name: "L3 root reserve",
+			fs:   "resctrl.nomb",
 			config: `
 options:
+  l3:
+    rootReserve: 10%
 partitions:
   part-1:
-    mbAllocation: [100%]
+    l3Allocation: 60%
     classes:
+      system/default:
+        l3Allocation: 50%
       class-1:
-        mbAllocation: [50%]
+  part-2:
+    l3Allocation: 40%
+    classes:
+      class-2:
 `,
 			schemata: map[string]Schemata{
 				"class-1": Schemata{
-					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
-					mb: "0=50;1=50;2=50;3=50",
+					l3: "0=1ffc;1=1ffc;2=1ffc;3=1ffc",
+				},
+				"class-2": Schemata{
+					l3: "0=fe000;1=fe000;2=fe000;3=fe000",
 				},
 				"system/default": Schemata{
-					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
-					mb: "0=100;1=100;2=100;3=100",
+					l3: "0=ff;1=ff;2=ff;3=ff",
 				},
 			},
 		},
 		// Testcase
 		TC{
-			name: "Default MBA",
-			fs:   "resctrl.full",
+			name: "L3 root reserve, absolute allocations overlap (fail)",
+			fs:   "resctrl.nomb",
 			config: `
 options:
+  l3:
+    rootReserve: 10%
 partitions:
   part-1:
-    l3Allocation: 100%
-    classes:
-      class-1:
-        l3Allocation: 50%
+    l3Allocation: "0-9"
+  part-2:
+    l3Allocation: "10-19"
 `,
-			schemata: map[string]Schemata{
-				"class-1": Schemata{
-					l3: "0=3ff;1=3ff;2=3ff;3=3ff",
-					mb: "0=100;1=100;2=100;3=100",
-				},
-				"system/default": Schemata{
-					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
-					mb: "0=100;1=100;2=100;3=100",
-				},
-			},
+			configErrRe: `allocation 0x3ff of partition "part-1" for cache id 0 overlaps the root reserve 0x3`,
 		},
 		// Testcase
 		TC{
-			name:        "duplicate class names (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `"class-1" defined multiple times`,
+			name: "L3 check shareable bits, overlap is only a warning",
+			fs:   "resctrl.nomb",
 			config: `
+options:
+  l3:
+    checkShareable: true
 partitions:
   part-1:
+    l3Allocation: "0-9"
     classes:
       class-1:
   part-2:
+    l3Allocation: "10-19"
     classes:
-      class-1:
+      class-2:
 `,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=3ff;1=3ff;2=3ff;3=3ff",
+				},
+				"class-2": Schemata{
+					l3: "0=ffc00;1=ffc00;2=ffc00;3=ffc00",
+				},
+				"system/default": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+				},
+			},
 		},
 		// Testcase
 		TC{
-			name:        "duplicate root class (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `"system/default" defined multiple times`,
+			name: "L3 check shareable bits, overlap (fail)",
+			fs:   "resctrl.nomb",
 			config: `
+options:
+  l3:
+    checkShareable: true
+    failOnShareable: true
 partitions:
   part-1:
-    classes:
-      "":
+    l3Allocation: "0-9"
   part-2:
-    classes:
-      system/default:
+    l3Allocation: "10-19"
 `,
+			configErrRe: `L3 allocation of partition "part-2" on cache id 0 overlaps shareable bits: 0xc0000 \(partition mask 0xffc00, shareable bits 0xc0000\)`,
 		},
 		// Testcase
 		TC{
-			name:        "invalid class name",
-			fs:          "resctrl.nomb",
-			configErrRe: `unqualified class name`,
+			name: "L3 check shareable bits, no overlap",
+			fs:   "resctrl.nomb",
 			config: `
+options:
+  l3:
+    checkShareable: true
+    failOnShareable: true
 partitions:
   part-1:
+    l3Allocation: "0-17"
     classes:
-      "..":
-`,
-		},
-		// Testcase
-		TC{
-			name:        "invalid field (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: ` unknown field "foo"`,
-			config: `
-partitions:
-  part-1:
-    l3Allocation: 100%
-    foo: bar
+      class-1:
 `,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=3ffff;1=3ffff;2=3ffff;3=3ffff",
+				},
+				"system/default": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+				},
+			},
 		},
 		// Testcase
 		TC{
-			name:        "Invalid cache ids (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `failed to parse L3 allocation request for partition "part-1": invalid integer "a"`,
+			name: "L3 shared partitions, overlap allowed",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    l3Allocation:
-      a: 100%
+    l3Allocation: "0-11"
+    sharedL3: true
+    classes:
+      class-1:
+        l3Allocation: 50%
+  part-2:
+    l3Allocation: "8-19"
+    sharedL3: true
+    classes:
+      class-2:
 `,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=3f;1=3f;2=3f;3=3f",
+				},
+				"class-2": Schemata{
+					l3: "0=fff00;1=fff00;2=fff00;3=fff00",
+				},
+				"system/default": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+				},
+			},
 		},
 		// Testcase
 		TC{
-			name:        "L3 invalid allocation schema #3, missing unified (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `failed to parse L3 allocation request for partition "part-1": 'unified' not specified in cache schema`,
+			name: "L3 shared partition overlaps exclusive partition (fail)",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    l3Allocation:
-      all:
-        data: 100%
+    l3Allocation: "0-11"
+    sharedL3: true
+  part-2:
+    l3Allocation: "8-19"
 `,
+			configErrRe: `overlapping L3 partition allocation requests for cache id 0`,
 		},
 		// Testcase
 		TC{
-			name:        "L3 invalid allocation schema #4, missing code (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `failed to parse L3 allocation request for partition "part-1": 'data' specified but missing 'code' from cache schema`,
+			name: "L3 shared partition, relative allocation (fail)",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    l3Allocation:
-      all:
-        unified: 100%
-        data: 100%
+    l3Allocation: 60%
+    sharedL3: true
+  part-2:
+    l3Allocation: 40%
 `,
+			configErrRe: `shared L3 partition "part-1" requires an absolute allocation`,
 		},
 		// Testcase
 		TC{
-			name:        "L3 invalid allocation schema #5, missing data (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `failed to parse L3 allocation request for partition "part-1": 'code' specified but missing 'data' from cache schema`,
+			name: "L3 cache ways and fractions",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    l3Allocation:
-      all:
-        unified: 100%
-        code: 100%
+    l3Allocation: "0-10"
+    classes:
+      class-1:
+        l3Allocation: half
+      class-2:
+        l3Allocation:
+          all: quarter
+          3: ways:6
+      class-3:
+        l3Allocation: ways:4
+  part-2:
+    l3Allocation: "11-19"
+    classes:
+      system/default:
+        l3Allocation: half
 `,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=1f;1=1f;2=1f;3=1f",
+				},
+				"class-2": Schemata{
+					l3: "0=3;1=3;2=3;3=3f",
+				},
+				"class-3": Schemata{
+					l3: "0=f;1=f;2=f;3=f",
+				},
+				"system/default": Schemata{
+					l3: "0=7800;1=7800;2=7800;3=7800",
+				},
+			},
 		},
 		// Testcase
 		TC{
-			name:        "L3 required (fail)",
-			fs:          "resctrl.nol3",
-			configErrRe: `L3 cache allocation for "class-1" specified in configuration but not supported by system`,
+			name: "L2 cache ways and fractions",
+			fs:   "resctrl.l2",
 			config: `
 partitions:
   part-1:
-    l3Allocation: 100%
+    l2Allocation: 100%
     classes:
       class-1:
-        l3Allocation: 20%
+        l2Allocation: half
+      class-2:
+        l2Allocation: quarter
+      system/default:
+        l2Allocation: ways:6
 `,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l2: "0=f;1=f",
+				},
+				"class-2": Schemata{
+					l2: "0=3;1=3",
+				},
+				"system/default": Schemata{
+					l2: "0=3f;1=3f",
+				},
+			},
 		},
 		// Testcase
 		TC{
-			name: "MB optional",
+			name: "L3 cache ways exceeding partition (fail)",
 			fs:   "resctrl.nomb",
 			config: `
-options:
-  mb:
-    optional: true
 partitions:
   part-1:
-    l3Allocation: 100%
-    mbAllocation: [100%]
+    l3Allocation: "0-10"
     classes:
       class-1:
-        l3Allocation: 0-7
-        mbAllocation: [50%]
+        l3Allocation: ways:12
 `,
-			schemata: map[string]Schemata{
-				"class-1": Schemata{
-					l3: "0=ff;1=ff;2=ff;3=ff",
-				},
-				"system/default": Schemata{
-					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
-				},
-			},
+			configErrRe: `12 cache ways requested but basemask 0x7ff only has 11`,
 		},
 		// Testcase
 		TC{
-			name:        "MB required (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `memory bandwidth allocation for "class-1" specified in configuration but not supported by system`,
+			name: "L3 cache ways below minimum (fail)",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    mbAllocation: [100%]
+    l3Allocation: 100%
     classes:
       class-1:
-        mbAllocation: [50%]
+        l3Allocation: ways:1
 `,
+			configErrRe: `number of ways less than 2`,
 		},
 		// Testcase
 		TC{
-			name:        "L3 mix rel and abs allocation in partition (fail)",
-			fs:          "resctrl.full",
-			configErrRe: "error resolving L3 allocation for cache id 0: mixing absolute and relative allocations between partitions not supported",
+			name: "L3 cache fraction in partition (fail)",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    l3Allocation: "0xff"
-  part-2:
-    l3Allocation: 50%
+    l3Allocation: half
 `,
+			configErrRe: `cache ways and fractions in partition allocation not supported`,
 		},
 		// Testcase
 		TC{
-			name:        "L3 mix rel and abs allocation in partition #2 (fail)",
-			fs:          "resctrl.full",
-			configErrRe: "error resolving L3 allocation for cache id 0: mixing relative and absolute allocations between partitions not supported",
+			name: "L3 root reserve, not a percentage (fail)",
+			fs:   "resctrl.nomb",
 			config: `
+options:
+  l3:
+    rootReserve: "0x3"
 partitions:
   part-1:
-    l3Allocation: 50%
-  part-2:
-    l3Allocation: "0xff"
+    l3Allocation: 100%
 `,
+			configErrRe: `only percentage is supported`,
 		},
 		// Testcase
 		TC{
-			name: "L3 mix rel and abs allocation in classes",
+			name: "L3 exclude allocation",
 			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    l3Allocation: 100%
+    l3Allocation: "0-9"
     classes:
       class-1:
-        l3Allocation:
-            all: 100%
-            1: 50%
+        l3Allocation: "^0-1"
       class-2:
         l3Allocation:
-            all: 50%
-            1: "0x7"
-            2: "1-2"
+          all: "^8-9"
+          1: "^0x3f0"
 `,
 			schemata: map[string]Schemata{
 				"class-1": Schemata{
-					l3: "0=fffff;1=3ff;2=fffff;3=fffff",
+					l3: "0=3fc;1=3fc;2=3fc;3=3fc",
 				},
 				"class-2": Schemata{
-					l3: "0=3ff;1=7;2=6;3=3ff",
+					l3: "0=ff;1=f;2=ff;3=ff",
 				},
 				"system/default": Schemata{
 					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
@@ -958,183 +1140,262 @@ partitions:
 		},
 		// Testcase
 		TC{
-			name: "L3 partial allocation",
+			name: "L3 exclude allocation, non-contiguous (fail)",
 			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    l3Allocation:
-      all: "21%"
-      1: "42%"
-      2: "63%"
-      3: "89%"
+    l3Allocation: "0-9"
     classes:
       class-1:
-  part-2:
-    l3Allocation:
-      all: "29%"
-      1: "8%"
-      2: "19%"
-      3: "11%"
+        l3Allocation: "^2-3"
+`,
+			configErrRe: `non-contiguous bitmask 0x3f3`,
+		},
+		// Testcase
+		TC{
+			name: "L3 exclude allocation in partition (fail)",
+			fs:   "resctrl.nomb",
+			config: `
+partitions:
+  part-1:
+    l3Allocation: "^0-1"
+`,
+			configErrRe: `exclude allocations in partition allocation not supported`,
+		},
+		// Testcase
+		TC{
+			name: "Default L3 CAT",
+			fs:   "resctrl.full",
+			config: `
+options:
+partitions:
+  part-1:
+    mbAllocation: [100%]
     classes:
-      class-2:
+      class-1:
+        mbAllocation: [50%]
 `,
 			schemata: map[string]Schemata{
 				"class-1": Schemata{
-					l3: "0=f;1=ff;2=1fff;3=3ffff",
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=50;1=50;2=50;3=50",
 				},
-				"class-2": Schemata{
-					l3: "0=3f0;1=300;2=e000;3=c0000",
+				"system/default": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=100;1=100;2=100;3=100",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name: "Default MBA",
+			fs:   "resctrl.full",
+			config: `
+options:
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+        l3Allocation: 50%
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=3ff;1=3ff;2=3ff;3=3ff",
+					mb: "0=100;1=100;2=100;3=100",
 				},
 				"system/default": Schemata{
 					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=100;1=100;2=100;3=100",
 				},
 			},
 		},
 		// Testcase
 		TC{
-			name:        "L3 partition non-contiguous bitmask (fail)",
+			name:        "duplicate class names (fail)",
 			fs:          "resctrl.nomb",
-			configErrRe: `failed to parse L3 allocation request for partition "part-1": invalid cache bitmask "0x2f": more than one continuous block of ones`,
+			configErrRe: `"class-1" defined multiple times`,
 			config: `
 partitions:
   part-1:
-    l3Allocation:
-      all: "100%"
-      1: "0x2f"
+    classes:
+      class-1:
+  part-2:
+    classes:
+      class-1:
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 overlapping partitions (fail)",
+			name:        "duplicate root class (fail)",
 			fs:          "resctrl.nomb",
-			configErrRe: `overlapping L3 partition allocation requests for cache id 2`,
+			configErrRe: `"system/default" defined multiple times`,
 			config: `
 partitions:
   part-1:
-    l3Allocation: "0xff"
+    classes:
+      "":
   part-2:
-    l3Allocation:
-      all: "0xff00"
-      2: "0xff80"
+    classes:
+      system/default:
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 nan percentage in partition (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `failed to parse L3 allocation request for partition "part-1": strconv.ParseUint: parsing "1f": invalid syntax`,
+			name: "namespaced class names",
+			fs:   "resctrl.nomb",
 			config: `
+options:
+  namespaceClasses: true
 partitions:
   part-1:
-    l3Allocation: "1f%"
+    l3Allocation: 60%
+    classes:
+      burstable:
+  part-2:
+    l3Allocation: 40%
+    classes:
+      burstable:
+      system/default:
 `,
+			schemata: map[string]Schemata{
+				"part-1/burstable": Schemata{
+					l3: "0=fff;1=fff;2=fff;3=fff",
+				},
+				"part-2/burstable": Schemata{
+					l3: "0=ff000;1=ff000;2=ff000;3=ff000",
+				},
+				"system/default": Schemata{
+					l3: "0=ff000;1=ff000;2=ff000;3=ff000",
+				},
+			},
 		},
 		// Testcase
 		TC{
-			name:        "L3 percentage range in partition (fail)",
+			name:        "namespaced class name conflicting with root class (fail)",
 			fs:          "resctrl.nomb",
-			configErrRe: `invalid configuration: percentage ranges in partition allocation not supported`,
+			configErrRe: `namespaced class name "system/default" conflicts with the root class`,
+			config: `
+options:
+  namespaceClasses: true
+partitions:
+  system:
+    classes:
+      default:
+`,
+		},
+		// Testcase
+		TC{
+			name:        "invalid class name",
+			fs:          "resctrl.nomb",
+			configErrRe: `unqualified class name`,
 			config: `
 partitions:
   part-1:
-    l3Allocation: "50-100%"
+    classes:
+      "..":
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 missing for one partition (fail)",
-			fs:          "resctrl.full",
-			configErrRe: `invalid configuration: some partitions \(part-2\) missing L3 "unified" allocation request`,
+			name:        "invalid field (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: ` unknown field "foo"`,
 			config: `
 partitions:
   part-1:
-    l3Allocation: "50%"
-    mbAllocation: ["100%"]
-  part-2:
-    mbAllocation: ["100%"]
+    l3Allocation: 100%
+    foo: bar
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 percentage over 100 in partition (fail)",
+			name:        "Invalid cache ids (fail)",
 			fs:          "resctrl.nomb",
-			configErrRe: `failed to parse L3 allocation request for partition "part-1": invalid percentage value "101%"`,
+			configErrRe: `failed to parse L3 allocation request for partition "part-1": invalid integer "a"`,
 			config: `
 partitions:
   part-1:
-    l3Allocation: "101%"
+    l3Allocation:
+      a: 100%
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 missing cdp (fail)",
+			name:        "L3 invalid allocation schema #3, missing unified (fail)",
 			fs:          "resctrl.nomb",
-			configErrRe: `some partitions \(part-2\) missing L3 "code" allocation request for cache id [0-3]`,
+			configErrRe: `failed to parse L3 allocation request for partition "part-1": 'unified' not specified in cache schema`,
 			config: `
 partitions:
   part-1:
     l3Allocation:
       all:
-        unified: "50%"
-        code: "40%"
-        data: "60%"
-  part-2:
-    l3Allocation: "50%"
+        data: 100%
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 total percentage over 100 (fail)",
+			name:        "L3 invalid allocation schema #4, missing code (fail)",
 			fs:          "resctrl.nomb",
-			configErrRe: `accumulated L3 "data" partition allocation requests for cache id [0-3] exceeds 100%`,
+			configErrRe: `failed to parse L3 allocation request for partition "part-1": 'data' specified but missing 'code' from cache schema`,
 			config: `
 partitions:
   part-1:
     l3Allocation:
       all:
-        unified: "50%"
-        code: "40%"
-        data: "60%"
-  part-2:
+        unified: 100%
+        data: 100%
+`,
+		},
+		// Testcase
+		TC{
+			name:        "L3 invalid allocation schema #5, missing data (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `failed to parse L3 allocation request for partition "part-1": 'code' specified but missing 'data' from cache schema`,
+			config: `
+partitions:
+  part-1:
     l3Allocation:
       all:
-        unified: "50%"
-        code: "40%"
-        data: "60%"
+        unified: 100%
+        code: 100%
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 class allocation does not fit partition (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `bitmask 0x1ff00 \(0x1ff << 8\) does not fit basemask 0xff00`,
+			name:        "L3 required (fail)",
+			fs:          "resctrl.nol3",
+			configErrRe: `L3 cache allocation for "class-1" specified in configuration but not supported by system`,
 			config: `
 partitions:
   part-1:
-    l3Allocation: "0xff00"
+    l3Allocation: 100%
     classes:
       class-1:
-        l3Allocation: "0x1ff"
+        l3Allocation: 20%
 `,
 		},
 		// Testcase
 		TC{
-			name: "L3 min cbm bits is respected",
+			name: "MB optional",
 			fs:   "resctrl.nomb",
 			config: `
+options:
+  mb:
+    optional: true
 partitions:
   part-1:
-    l3Allocation: "100%"
+    l3Allocation: 100%
+    mbAllocation: [100%]
     classes:
       class-1:
-        l3Allocation:
-          all: "1%"
-          1-2: "99-100%"
+        l3Allocation: 0-7
+        mbAllocation: [50%]
 `,
 			schemata: map[string]Schemata{
 				"class-1": Schemata{
-					l3: "0=3;1=c0000;2=c0000;3=3",
+					l3: "0=ff;1=ff;2=ff;3=ff",
 				},
 				"system/default": Schemata{
 					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
@@ -1143,391 +1404,3385 @@ partitions:
 		},
 		// Testcase
 		TC{
-			name:        "L3 too few bits (fail)",
+			name:        "MB required (fail)",
 			fs:          "resctrl.nomb",
-			configErrRe: `bitmask 0x1ff00 \(0x1ff << 8\) does not fit basemask 0xff00`,
+			configErrRe: `memory bandwidth allocation for "class-1" specified in configuration but not supported by system`,
 			config: `
 partitions:
   part-1:
-    l3Allocation: "0xff00"
+    mbAllocation: [100%]
     classes:
       class-1:
-        l3Allocation: "0x1ff"
+        mbAllocation: [50%]
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 invalid percentage range in class (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `invalid configuration: failed to resolve L3 allocation for class "class-1": invalid percentage range`,
+			name:        "L3 mix rel and abs allocation in partition (fail)",
+			fs:          "resctrl.full",
+			configErrRe: "error resolving L3 allocation for cache id 0: mixing absolute and relative allocations between partitions not supported",
 			config: `
 partitions:
   part-1:
-    l3Allocation: "100%"
-    classes:
-      class-1:
-        l3Allocation: "0-101%"
+    l3Allocation: "0xff"
+  part-2:
+    l3Allocation: 50%
 `,
 		},
 		// Testcase
 		TC{
-			name:        "L3 missing from partition (fail)",
-			fs:          "resctrl.nomb",
-			configErrRe: `L3 allocation missing from partition "part-1"`,
+			name:        "L3 mix rel and abs allocation in partition #2 (fail)",
+			fs:          "resctrl.full",
+			configErrRe: "error resolving L3 allocation for cache id 0: mixing relative and absolute allocations between partitions not supported",
 			config: `
 partitions:
   part-1:
-    classes:
-      class-1:
-        l3Allocation: "100%"
+    l3Allocation: 50%
+  part-2:
+    l3Allocation: "0xff"
 `,
 		},
 		// Testcase
 		TC{
-			name: "MB allocation under minimum",
-			fs:   "resctrl.nol3",
+			name: "L3 mix rel and abs allocation in classes",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    mbAllocation: ["1%"]
+    l3Allocation: 100%
     classes:
       class-1:
-        mbAllocation: ["100%"]
+        l3Allocation:
+            all: 100%
+            1: 50%
+      class-2:
+        l3Allocation:
+            all: 50%
+            1: "0x7"
+            2: "1-2"
 `,
 			schemata: map[string]Schemata{
 				"class-1": Schemata{
-					mb: "0=10;1=10;2=10;3=10",
+					l3: "0=fffff;1=3ff;2=fffff;3=fffff",
+				},
+				"class-2": Schemata{
+					l3: "0=3ff;1=7;2=6;3=3ff",
 				},
 				"system/default": Schemata{
-					mb: "0=100;1=100;2=100;3=100",
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
 				},
 			},
 		},
 		// Testcase
 		TC{
-			name: "L2, partial allocation",
-			fs:   "resctrl.l2",
+			name: "L3 partial allocation",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    l2Allocation:
-      all: 30%
-      1: 75%
+    l3Allocation:
+      all: "21%"
+      1: "42%"
+      2: "63%"
+      3: "89%"
     classes:
       class-1:
   part-2:
-    l2Allocation:
-      0: 30%
-      1:
-        unified: 20%
+    l3Allocation:
+      all: "29%"
+      1: "8%"
+      2: "19%"
+      3: "11%"
     classes:
       class-2:
-  part-3:
-    l2Allocation:
-      0: 40%
-      1: 5%
-    classes:
-      system/default:
 `,
 			schemata: map[string]Schemata{
 				"class-1": Schemata{
-					l2: "0=3;1=3f",
+					l3: "0=f;1=ff;2=1fff;3=3ffff",
 				},
 				"class-2": Schemata{
-					l2: "0=c;1=40",
+					l3: "0=3f0;1=300;2=e000;3=c0000",
 				},
 				"system/default": Schemata{
-					l2: "0=f0;1=80",
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
 				},
 			},
 		},
 		// Testcase
 		TC{
-			name: "L2 CDP",
-			fs:   "resctrl.l2cdp",
+			name:        "L3 partition non-contiguous bitmask (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `failed to parse L3 allocation request for partition "part-1": invalid cache bitmask "0x2f": more than one continuous block of ones`,
 			config: `
 partitions:
   part-1:
-    l2Allocation:
-      all: 42%
-      2:
-        unified: 30%
-        code: 20%
-        data: 50%
-      3:
-        unified: 30%
-        code: 40%
-        data: 50%
-    l3Allocation: 30%
+    l3Allocation:
+      all: "100%"
+      1: "0x2f"
+`,
+		},
+		// Testcase
+		TC{
+			name:        "L3 overlapping partitions (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `overlapping L3 partition allocation requests for cache id 2`,
+			config: `
+partitions:
+  part-1:
+    l3Allocation: "0xff"
+  part-2:
+    l3Allocation:
+      all: "0xff00"
+      2: "0xff80"
+`,
+		},
+		// Testcase
+		TC{
+			name:        "L3 nan percentage in partition (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `failed to parse L3 allocation request for partition "part-1": strconv.ParseUint: parsing "1f": invalid syntax`,
+			config: `
+partitions:
+  part-1:
+    l3Allocation: "1f%"
+`,
+		},
+		// Testcase
+		TC{
+			name:        "L3 percentage range in partition (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `invalid configuration: percentage ranges in partition allocation not supported`,
+			config: `
+partitions:
+  part-1:
+    l3Allocation: "50-100%"
+`,
+		},
+		// Testcase
+		TC{
+			name:        "L3 missing for one partition (fail)",
+			fs:          "resctrl.full",
+			configErrRe: `invalid configuration: some partitions \(part-2\) missing L3 "unified" allocation request`,
+			config: `
+partitions:
+  part-1:
+    l3Allocation: "50%"
+    mbAllocation: ["100%"]
+  part-2:
+    mbAllocation: ["100%"]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "L3 percentage over 100 in partition (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `failed to parse L3 allocation request for partition "part-1": invalid percentage value "101%"`,
+			config: `
+partitions:
+  part-1:
+    l3Allocation: "101%"
+`,
+		},
+		// Testcase
+		TC{
+			name:        "L3 missing cdp (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `some partitions \(part-2\) missing L3 "code" allocation request for cache id [0-3]`,
+			config: `
+partitions:
+  part-1:
+    l3Allocation:
+      all:
+        unified: "50%"
+        code: "40%"
+        data: "60%"
+  part-2:
+    l3Allocation: "50%"
+`,
+		},
+		// Testcase
+		TC{
+			name: "L3 CDP class overrides in unified partition",
+			fs:   "resctrl.nomb.cdp",
+			config: `
+partitions:
+  part-1:
+    l3Allocation: "60%"
     classes:
       class-1:
+        l3Allocation:
+          all:
+            unified: "50%"
+            code: "100%"
+            data: "50%"
+      class-2:
   part-2:
-    l2Allocation:
-      all: 43%
-      2:
-        unified: 70%
-        code: 40%
-        data: 30%
-      3:
-        unified: 30%
-        code: 60%
-        data: 50%
-    l3Allocation: 50%
+    l3Allocation: "40%"
     classes:
-      class-2:
-        l2Allocation:
-          all: 80%
-          2:
-            unified: 80%
-            code: 60%
-            data: 90%
-      system/default:
-        l3Allocation: 60%
-
+      class-3:
+        l3Allocation:
+          all:
+            unified: "100%"
+            code: "0x3"
+            data: "^0x3"
+      "":
 `,
 			schemata: map[string]Schemata{
 				"class-1": Schemata{
-					l2code: "0=ff;1=ff;2=f;3=ff",
-					l2data: "0=ff;1=ff;2=3ff;3=3ff",
-					l3:     "0=7",
+					l3code: "0=fff;1=fff;2=fff;3=fff",
+					l3data: "0=3f;1=3f;2=3f;3=3f",
 				},
 				"class-2": Schemata{
-					l2code: "0=ff00;1=ff00;2=1f0;3=3ff00",
-					l2data: "0=ff00;1=ff00;2=fc00;3=3fc00",
-					l3:     "0=1f8",
+					l3code: "0=fff;1=fff;2=fff;3=fff",
+					l3data: "0=fff;1=fff;2=fff;3=fff",
+				},
+				"class-3": Schemata{
+					l3code: "0=3000;1=3000;2=3000;3=3000",
+					l3data: "0=fc000;1=fc000;2=fc000;3=fc000",
 				},
 				"system/default": Schemata{
-					l2code: "0=1ff00;1=1ff00;2=ff0;3=fff00",
-					l2data: "0=1ff00;1=1ff00;2=fc00;3=ffc00",
-					l3:     "0=78",
+					l3code: "0=ff000;1=ff000;2=ff000;3=ff000",
+					l3data: "0=ff000;1=ff000;2=ff000;3=ff000",
 				},
 			},
 		},
 		// Testcase
 		TC{
-			name: "L2 optional",
-			fs:   "resctrl.nomb",
+			name:        "L3 CDP class override does not fit unified partition (fail)",
+			fs:          "resctrl.nomb.cdp",
+			configErrRe: `invalid L3 "code" allocation for class "class-1": bitmask 0xffff \(0xffff << 0\) does not fit basemask 0xfff`,
 			config: `
-options:
-  l2:
-    optional: true
 partitions:
   part-1:
-    l2Allocation: 50%
-    l3Allocation: 50%
+    l3Allocation: "60%"
     classes:
       class-1:
-        l2Allocation: 20%
+        l3Allocation:
+          all:
+            unified: "50%"
+            code: "0-15"
+            data: "0x1f"
 `,
-			schemata: map[string]Schemata{
-				"class-1": Schemata{
-					l3: "0=3ff;1=3ff;2=3ff;3=3ff",
-				},
-				"system/default": Schemata{
-					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
-				},
-			},
 		},
 		// Testcase
 		TC{
-			name:        "MB nan percentage value in partition (fail)",
-			fs:          "resctrl.nol3",
-			configErrRe: `failed to resolve MB allocation for partition "part-1": strconv.ParseUint: parsing "xyz"`,
+			name:        "L3 total percentage over 100 (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `accumulated L3 "data" partition allocation requests for cache id [0-3] exceeds 100%`,
 			config: `
 partitions:
   part-1:
-    mbAllocation: ["xyz%"]
+    l3Allocation:
+      all:
+        unified: "50%"
+        code: "40%"
+        data: "60%"
+  part-2:
+    l3Allocation:
+      all:
+        unified: "50%"
+        code: "40%"
+        data: "60%"
 `,
 		},
 		// Testcase
 		TC{
-			name:        "MB invalid percentage value in class (fail)",
-			fs:          "resctrl.nol3",
-			configErrRe: `failed to resolve MB allocation for class "class-1":.*invalid syntax`,
+			name:        "L3 class allocation does not fit partition (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `bitmask 0x1ff00 \(0x1ff << 8\) does not fit basemask 0xff00`,
 			config: `
 partitions:
   part-1:
-    mbAllocation: ["100%"]
+    l3Allocation: "0xff00"
     classes:
       class-1:
-        mbAllocation: ["1a%"]
+        l3Allocation: "0x1ff"
 `,
 		},
 		// Testcase
 		TC{
-			name:        "MB missing percentage value (fail)",
-			fs:          "resctrl.nol3",
-			configErrRe: `missing '%' value from mbSchema`,
+			name: "L3 min cbm bits is respected",
+			fs:   "resctrl.nomb",
 			config: `
 partitions:
   part-1:
-    mbAllocation: ["100MBps"]
+    l3Allocation: "100%"
+    classes:
+      class-1:
+        l3Allocation:
+          all: "1%"
+          1-2: "99-100%"
 `,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=3;1=c0000;2=c0000;3=3",
+				},
+				"system/default": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+				},
+			},
 		},
 		// Testcase
 		TC{
-			name:        "MB missing from partition (fail)",
-			fs:          "resctrl.nol3",
-			configErrRe: `MB allocation missing from partition "part-1"`,
+			name:        "L3 too few bits (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `bitmask 0x1ff00 \(0x1ff << 8\) does not fit basemask 0xff00`,
 			config: `
 partitions:
   part-1:
+    l3Allocation: "0xff00"
     classes:
       class-1:
-        mbAllocation: ["100%"]
+        l3Allocation: "0x1ff"
 `,
 		},
 		// Testcase
 		TC{
-			name:        "MB MBps",
-			fs:          "resctrl.nol3.mbps",
-			fsMountOpts: "mba_MBps",
+			name:        "L3 invalid percentage range in class (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `invalid configuration: failed to resolve L3 allocation for class "class-1": invalid percentage range`,
+			config: `
+partitions:
+  part-1:
+    l3Allocation: "100%"
+    classes:
+      class-1:
+        l3Allocation: "0-101%"
+`,
+		},
+		// Testcase
+		TC{
+			name:        "L3 missing from partition (fail)",
+			fs:          "resctrl.nomb",
+			configErrRe: `L3 allocation missing from partition "part-1"`,
+			config: `
+partitions:
+  part-1:
+    classes:
+      class-1:
+        l3Allocation: "100%"
+`,
+		},
+		// Testcase
+		TC{
+			name: "MB allocation under minimum",
+			fs:   "resctrl.nol3",
+			config: `
+partitions:
+  part-1:
+    mbAllocation: ["1%"]
+    classes:
+      class-1:
+        mbAllocation: ["100%"]
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					mb: "0=10;1=10;2=10;3=10",
+				},
+				"system/default": Schemata{
+					mb: "0=100;1=100;2=100;3=100",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name: "MB allocation, proportional",
+			fs:   "resctrl.full",
+			config: `
+partitions:
+  part-1:
+    mbAllocation: [80%]
+    mbProportional: true
+    classes:
+      class-1:
+        mbAllocation:
+          all: [50%]
+          3: [20%]
+      class-2:
+        mbAllocation: [30%]
+      class-3:
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=20;1=20;2=20;3=10",
+				},
+				"class-2": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=10;1=10;2=10;3=20",
+				},
+				"class-3": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=40;1=40;2=40;3=50",
+				},
+				"system/default": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=100;1=100;2=100;3=100",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name: "L2, partial allocation",
+			fs:   "resctrl.l2",
+			config: `
+partitions:
+  part-1:
+    l2Allocation:
+      all: 30%
+      1: 75%
+    classes:
+      class-1:
+  part-2:
+    l2Allocation:
+      0: 30%
+      1:
+        unified: 20%
+    classes:
+      class-2:
+  part-3:
+    l2Allocation:
+      0: 40%
+      1: 5%
+    classes:
+      system/default:
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l2: "0=3;1=3f",
+				},
+				"class-2": Schemata{
+					l2: "0=c;1=40",
+				},
+				"system/default": Schemata{
+					l2: "0=f0;1=80",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name: "L2 CDP",
+			fs:   "resctrl.l2cdp",
+			config: `
+partitions:
+  part-1:
+    l2Allocation:
+      all: 42%
+      2:
+        unified: 30%
+        code: 20%
+        data: 50%
+      3:
+        unified: 30%
+        code: 40%
+        data: 50%
+    l3Allocation: 30%
+    classes:
+      class-1:
+  part-2:
+    l2Allocation:
+      all: 43%
+      2:
+        unified: 70%
+        code: 40%
+        data: 30%
+      3:
+        unified: 30%
+        code: 60%
+        data: 50%
+    l3Allocation: 50%
+    classes:
+      class-2:
+        l2Allocation:
+          all: 80%
+          2:
+            unified: 80%
+            code: 60%
+            data: 90%
+      system/default:
+        l3Allocation: 60%
+
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l2code: "0=ff;1=ff;2=f;3=ff",
+					l2data: "0=ff;1=ff;2=3ff;3=3ff",
+					l3:     "0=7",
+				},
+				"class-2": Schemata{
+					l2code: "0=ff00;1=ff00;2=1f0;3=3ff00",
+					l2data: "0=ff00;1=ff00;2=fc00;3=3fc00",
+					l3:     "0=1f8",
+				},
+				"system/default": Schemata{
+					l2code: "0=1ff00;1=1ff00;2=ff0;3=fff00",
+					l2data: "0=1ff00;1=1ff00;2=fc00;3=ffc00",
+					l3:     "0=78",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name: "L2 optional",
+			fs:   "resctrl.nomb",
+			config: `
+options:
+  l2:
+    optional: true
+partitions:
+  part-1:
+    l2Allocation: 50%
+    l3Allocation: 50%
+    classes:
+      class-1:
+        l2Allocation: 20%
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=3ff;1=3ff;2=3ff;3=3ff",
+				},
+				"system/default": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name:        "MB nan percentage value in partition (fail)",
+			fs:          "resctrl.nol3",
+			configErrRe: `failed to resolve MB allocation for partition "part-1": strconv.ParseUint: parsing "xyz"`,
+			config: `
+partitions:
+  part-1:
+    mbAllocation: ["xyz%"]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB invalid percentage value in class (fail)",
+			fs:          "resctrl.nol3",
+			configErrRe: `failed to resolve MB allocation for class "class-1":.*invalid syntax`,
+			config: `
+partitions:
+  part-1:
+    mbAllocation: ["100%"]
+    classes:
+      class-1:
+        mbAllocation: ["1a%"]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB missing percentage value (fail)",
+			fs:          "resctrl.nol3",
+			configErrRe: `missing '%' value from mbSchema`,
+			config: `
+partitions:
+  part-1:
+    mbAllocation: ["100MBps"]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB missing from partition (fail)",
+			fs:          "resctrl.nol3",
+			configErrRe: `MB allocation missing from partition "part-1"`,
+			config: `
+partitions:
+  part-1:
+    classes:
+      class-1:
+        mbAllocation: ["100%"]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB MBps",
+			fs:          "resctrl.nol3.mbps",
+			fsMountOpts: "mba_MBps",
+			config: `
+partitions:
+  part-1:
+    mbAllocation: ["50%", "1000MBps"]
+    classes:
+      class-1:
+        mbAllocation: ["100%", "1500MBps"]
+  part-2:
+    mbAllocation:
+      all: ["1000MBps"]
+      # Unsupported values should just be ignored
+      0,1: [50, "1GBps", "500MBps"]
+    classes:
+      class-2:
+        mbAllocation: ["750MBps"]
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					mb: "0=1000;1=1000;2=1000;3=1000",
+				},
+				"class-2": Schemata{
+					mb: "0=500;1=500;2=750;3=750",
+				},
+				"system/default": Schemata{
+					mb: "0=4294967295;1=4294967295;2=4294967295;3=4294967295",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name: "MB allocation, proportional from options",
+			fs:   "resctrl.full",
+			config: `
+options:
+  mb:
+    proportional: true
+    strict: true
+partitions:
+  part-1:
+    mbAllocation: [80%]
+    classes:
+      class-1:
+        mbAllocation:
+          all: [50%]
+          3: [20%]
+      class-2:
+        mbAllocation: [30%]
+      class-3:
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=20;1=20;2=20;3=10",
+				},
+				"class-2": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=10;1=10;2=10;3=20",
+				},
+				"class-3": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=40;1=40;2=40;3=50",
+				},
+				"system/default": Schemata{
+					l3: "0=fffff;1=fffff;2=fffff;3=fffff",
+					mb: "0=100;1=100;2=100;3=100",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name:        "MB strict, value for inactive mode (fail)",
+			fs:          "resctrl.full",
+			configErrRe: `invalid MB allocation for class "class-1": cache id "all": 'MBps' value in \[50% 1000MBps\] has no effect because percentage-based MBA allocation is enabled`,
+			config: `
+options:
+  mb:
+    strict: true
+partitions:
+  part-1:
+    mbAllocation: [80%]
+    classes:
+      class-1:
+        mbAllocation: [50%, 1000MBps]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB strict, multiple values of the same unit (fail)",
+			fs:          "resctrl.full",
+			configErrRe: `invalid MB allocation for partition "part-1": cache id "1": multiple '%' values`,
+			config: `
+options:
+  mb:
+    strict: true
+partitions:
+  part-1:
+    mbAllocation:
+      all: [80%]
+      1: [60%, 70%]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB strict, unrecognized unit (fail)",
+			fs:          "resctrl.full",
+			configErrRe: `unrecognized MBA allocation unit in "80"`,
+			config: `
+options:
+  mb:
+    strict: true
+partitions:
+  part-1:
+    mbAllocation: [80%, "80"]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB strict, proportional in MBps mode (fail)",
+			fs:          "resctrl.nol3.mbps",
+			fsMountOpts: "mba_MBps",
+			configErrRe: `proportional MB allocation of partition "part-1" not supported with MBps`,
+			config: `
+options:
+  mb:
+    strict: true
+partitions:
+  part-1:
+    mbAllocation: [1000MBps]
+    mbProportional: true
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB nan MBps value (fail)",
+			fs:          "resctrl.nol3.mbps",
+			fsMountOpts: "mba_MBps",
+			configErrRe: `failed to resolve MB allocation for partition "part-1":.* invalid syntax`,
+			config: `
+partitions:
+  part-1:
+    mbAllocation: ["0xffMBps"]
+`,
+		},
+		// Testcase
+		TC{
+			name:        "MB missing MBps value (fail)",
+			fs:          "resctrl.nol3.mbps",
+			fsMountOpts: "mba_MBps",
+			configErrRe: `missing 'MBps' value from mbSchema`,
+			config: `
+partitions:
+  part-1:
+    mbAllocation: ["100%"]
+`,
+		},
+		// Testcase
+		TC{
+			name: "MB convert MBps to percentage",
+			fs:   "resctrl.nol3",
+			config: `
+options:
+  mb:
+    convertUnits: true
+    maxBandwidthMBps: 10000
+    strict: true
+partitions:
+  part-1:
+    mbAllocation: ["5000MBps"]
+    classes:
+      class-1:
+      class-2:
+        mbAllocation: ["60%"]
+  part-2:
+    mbAllocation:
+      all: ["20000MBps"]
+      3: ["2400MBps"]
+    classes:
+      class-3:
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					mb: "0=50;1=50;2=50;3=50",
+				},
+				"class-2": Schemata{
+					mb: "0=30;1=30;2=30;3=30",
+				},
+				"class-3": Schemata{
+					mb: "0=100;1=100;2=100;3=20",
+				},
+				"system/default": Schemata{
+					mb: "0=100;1=100;2=100;3=100",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name:        "MB convert percentage to MBps",
+			fs:          "resctrl.nol3.mbps",
+			fsMountOpts: "mba_MBps",
+			config: `
+options:
+  mb:
+    convertUnits: true
+    maxBandwidthMBps: 10000
+partitions:
+  part-1:
+    mbAllocation: ["50%"]
+    classes:
+      class-1:
+        mbAllocation: ["1000MBps"]
+      class-2:
+        mbAllocation: ["100%", "2000MBps"]
+      class-3:
+`,
+			schemata: map[string]Schemata{
+				"class-1": Schemata{
+					mb: "0=1000;1=1000;2=1000;3=1000",
+				},
+				"class-2": Schemata{
+					mb: "0=2000;1=2000;2=2000;3=2000",
+				},
+				"class-3": Schemata{
+					mb: "0=5000;1=5000;2=5000;3=5000",
+				},
+				"system/default": Schemata{
+					mb: "0=4294967295;1=4294967295;2=4294967295;3=4294967295",
+				},
+			},
+		},
+		// Testcase
+		TC{
+			name:        "MB convert without max bandwidth (fail)",
+			fs:          "resctrl.nol3",
+			configErrRe: `missing '%' value from mbSchema; required because percentage-based MBA allocation is enabled in the system \(cannot convert "100MBps", maxBandwidthMBps not set\)`,
+			config: `
+options:
+  mb:
+    convertUnits: true
+partitions:
+  part-1:
+    mbAllocation: ["100MBps"]
+`,
+		},
+	}
+
+	verifySchemata := func(tc *TC) {
+		for n, s := range tc.schemata {
+			expected := ""
+			if s.l2 != "" {
+				expected += "L2:" + s.l2 + "\n"
+			}
+			if s.l2code != "" {
+				expected += "L2CODE:" + s.l2code + "\n"
+			}
+			if s.l2data != "" {
+				expected += "L2DATA:" + s.l2data + "\n"
+			}
+			if s.l3 != "" {
+				expected += "L3:" + s.l3 + "\n"
+			}
+			if s.l3code != "" {
+				expected += "L3CODE:" + s.l3code + "\n"
+			}
+			if s.l3data != "" {
+				expected += "L3DATA:" + s.l3data + "\n"
+			}
+			if s.mb != "" {
+				expected += "MB:" + s.mb + "\n"
+			}
+			if c, ok := rdt.classes[n]; !ok {
+				t.Fatalf("verifySchemata: class %q does not exists in %v", n, rdt.classes)
+			} else {
+				verifyTextFile(t, c.path("schemata"), expected)
+			}
+		}
+
+		if len(tc.schemata) != len(rdt.classes) {
+			var a, b []string
+			for n := range tc.schemata {
+				a = append(a, n)
+			}
+			for n := range rdt.classes {
+				b = append(b, n)
+			}
+			t.Fatalf("unexpected set of classes: expected %v, got %v", a, b)
+		}
+	}
+
+	// Set group remove function so that mock groups can be removed
+	groupRemoveFunc = os.RemoveAll
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFs, err := newMockResctrlFs(t, tc.fs, tc.fsMountOpts)
+			if err != nil {
+				t.Fatalf("failed to set up mock resctrl fs: %v", err)
+			}
+			defer mockFs.delete()
+
+			if err := Initialize(mockGroupPrefix); err != nil {
+				t.Fatalf("resctrl initialization failed: %v", err)
+			}
+
+			err = SetConfigFromData([]byte(tc.config), false)
+			if tc.configErrRe != "" {
+				if err == nil {
+					t.Fatalf("resctrl configuration succeeded unexpectedly")
+				} else {
+					m, e := regexp.MatchString(tc.configErrRe, err.Error())
+					if e != nil {
+						t.Fatalf("error in regexp matching: %v", e)
+					}
+					if !m {
+						t.Fatalf("unexpected error message:\n  %q\n  does NOT match regexp\n  %q", err.Error(), tc.configErrRe)
+					}
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("resctrl configuration failed: %v", err)
+				}
+				verifySchemata(&tc)
+			}
+
+			// Check that SetConfig does not alter the config struct
+			conf := parseTestConfig(t, tc.config)
+			confDataOld, err := yaml.Marshal(conf)
+			if err != nil {
+				t.Fatalf("marshalling config failed: %v", err)
+			}
+			_ = SetConfig(conf, false)
+			if confDataNew, err := yaml.Marshal(conf); err != nil {
+				t.Fatalf("marshalling config failed: %v", err)
+			} else if !cmp.Equal(confDataNew, confDataOld) {
+				t.Fatalf("SetConfig altered config data:\n%s\nVS.\n%s", confDataOld, confDataNew)
+			}
+		})
+	}
+}
+
+func TestConfigFromFiles(t *testing.T) {
+	base := testutils.CreateTempFile(t, `
+options:
+  l3:
+    optional: true
+partitions:
+  part-1:
+    l3Allocation: 60%
+    mbAllocation: [100%]
+    classes:
+      class-1:
+        l3Allocation:
+          all: 100%
+          1: 50%
+        mbAllocation: [60%]
+      class-2:
+        kubernetes:
+          denyPodAnnotation: true
+  part-2:
+    l3Allocation: 40%
+    classes:
+      class-3:
+`)
+	defer os.Remove(base)
+	override := testutils.CreateTempFile(t, `
+options:
+  l3:
+    optional: false
+  mb:
+    optional: true
+partitions:
+  part-1:
+    l3Allocation:
+      2: 70%
+    classes:
+      class-1:
+        l3Allocation:
+          1: 80%
+      class-2:
+      class-4:
+        mbAllocation:
+          0: [20%]
+  part-2:
+    classes:
+      class-3:
+        l3Allocation: 50%
+`)
+	defer os.Remove(override)
+	invalid := testutils.CreateTempFile(t, "partitions:\n  part-1:\n    foo: bar\n")
+	defer os.Remove(invalid)
+
+	expected := parseTestConfig(t, `
+options:
+  l3:
+    optional: false
+  mb:
+    optional: true
+partitions:
+  part-1:
+    l3Allocation:
+      all: 60%
+      2: 70%
+    mbAllocation: [100%]
+    classes:
+      class-1:
+        l3Allocation:
+          all: 100%
+          1: 80%
+        mbAllocation: [60%]
+      class-2:
+        kubernetes:
+          denyPodAnnotation: true
+      class-4:
+        mbAllocation:
+          0: [20%]
+  part-2:
+    l3Allocation: 40%
+    classes:
+      class-3:
+        l3Allocation: 50%
+`)
+
+	conf, err := readConfigFiles([]string{base, override})
+	if err != nil {
+		t.Fatalf("reading config files failed: %v", err)
+	}
+	if !cmp.Equal(conf, expected) {
+		t.Errorf("unexpected merged config\nexpected:\n%s\nreceived:\n%s", utils.DumpJSON(expected), utils.DumpJSON(conf))
+	}
+
+	if _, err := readConfigFiles(nil); err == nil {
+		t.Errorf("reading empty list of config files succeeded unexpectedly")
+	}
+	if _, err := readConfigFiles([]string{base, "non-existent-config-file"}); err == nil {
+		t.Errorf("reading non-existent config file succeeded unexpectedly")
+	}
+	if _, err := readConfigFiles([]string{base, invalid}); err == nil || !strings.Contains(err.Error(), invalid) {
+		t.Errorf("expected error mentioning the invalid config file, got %v", err)
+	}
+}
+
+func TestConfigValidateStatic(t *testing.T) {
+	// Static validation must not depend on the system
+	defer func(i *resctrlInfo) { info = i }(info)
+	info = nil
+
+	tcs := []struct {
+		name   string
+		config string
+		errRe  string
+	}{
+		{
+			name: "valid config",
+			config: `
+partitions:
+  part-1:
+    l3Allocation:
+      all: 60%
+      1: "0xff000"
+      2: "9-15"
+    mbAllocation:
+      all: [100%, 1000MBps]
+    classes:
+      class-1:
+        l3Allocation: 100%
+      class-2:
+        l3Allocation:
+          all: 10-20%
+        mbAllocation: [40%]
+  part-2:
+    l3Allocation:
+      all: 40%
+      1: "0-11"
+      2: "0-8"
+    classes:
+      system/default:
+`,
+		},
+		{
+			name: "allocation in monitoring-only class",
+			config: `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+        monitoringOnly: true
+        l3Allocation: 50%
+`,
+			errRe: `allocation specified for monitoring-only class "class-1"`,
+		},
+		{
+			name: "invalid monitoring group name",
+			config: `
+partitions:
+  part-1:
+    classes:
+      class-1:
+        monitorGroups:
+          foo/bar:
+`,
+			errRe: `invalid monitoring group name "foo/bar" in class "class-1"`,
+		},
+		{
+			name: "duplicate CLOSIDs",
+			config: `
+partitions:
+  part-1:
+    classes:
+      class-1:
+        closid: 2
+      class-2:
+        closid: 2
+`,
+			errRe: `CLOSID 2 requested for both class "class-[12]" and class "class-[12]"`,
+		},
+		{
+			name: "CLOSID of the root class",
+			config: `
+partitions:
+  part-1:
+    classes:
+      system/default:
+        closid: 1
+`,
+			errRe: `CLOSID cannot be requested for the root class`,
+		},
+		{
+			name: "CLOSID 0",
+			config: `
+partitions:
+  part-1:
+    classes:
+      class-1:
+        closid: 0
+`,
+			errRe: `CLOSID 0 requested for class "class-1" is reserved for the root class`,
+		},
+		{
+			name: "duplicate class names",
+			config: `
+partitions:
+  part-1:
+    classes:
+      class-1:
+  part-2:
+    classes:
+      class-1:
+`,
+			errRe: `"class-1" defined multiple times`,
+		},
+		{
+			name: "invalid class name",
+			config: `
+partitions:
+  part-1:
+    classes:
+      "..":
+`,
+			errRe: `unqualified class name`,
+		},
+		{
+			name: "partition percentages exceed 100%",
+			config: `
+partitions:
+  part-1:
+    l2Allocation: 60%
+  part-2:
+    l2Allocation:
+      all: 40%
+      3: 50%
+`,
+			errRe: `accumulated L2 "unified" partition allocation requests for cache id 3 exceeds 100% \(110%\)`,
+		},
+		{
+			name: "overlapping absolute allocations",
+			config: `
+partitions:
+  part-1:
+    l3Allocation:
+      0: "0-7"
+  part-2:
+    l3Allocation:
+      0: "0xf0"
+`,
+			errRe: `overlapping L3 partition allocation requests for cache id 0`,
+		},
+		{
+			name: "overlapping shared absolute allocations",
+			config: `
+partitions:
+  part-1:
+    l3Allocation:
+      0: "0-7"
+    sharedL3: true
+  part-2:
+    l3Allocation:
+      0: "0xf0"
+    sharedL3: true
+`,
+		},
+		{
+			name: "missing code allocation",
+			config: `
+partitions:
+  part-1:
+    l3Allocation:
+      all:
+        unified: 50%
+        code: 50%
+        data: 50%
+  part-2:
+    l3Allocation: 50%
+`,
+			errRe: `some partitions \(part-2\) missing L3 "code" allocation request for cache id all`,
+		},
+		{
+			name: "incomplete cdp schema",
+			config: `
+partitions:
+  part-1:
+    l3Allocation:
+      all:
+        unified: 50%
+        data: 50%
+`,
+			errRe: `failed to parse L3 allocation request for partition "part-1": 'data' specified but missing 'code'`,
+		},
+		{
+			name: "class allocation without partition allocation",
+			config: `
+partitions:
+  part-1:
+    classes:
+      class-1:
+        l3Allocation: 50%
+`,
+			errRe: `L3 allocation missing from partition "part-1"`,
+		},
+		{
+			name: "invalid mb allocation",
+			config: `
+partitions:
+  part-1:
+    mbAllocation: [50%, xyzMBps]
+`,
+			errRe: `failed to resolve MB allocation for partition "part-1":.*invalid syntax`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := parseTestConfig(t, tc.config).ValidateStatic()
+			if tc.errRe == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else if err == nil {
+				t.Fatalf("validation succeeded unexpectedly")
+			} else if m, _ := regexp.MatchString(tc.errRe, err.Error()); !m {
+				t.Fatalf("unexpected error message:\n  %q\n  does NOT match regexp\n  %q", err.Error(), tc.errRe)
+			}
+		})
+	}
+}
+
+func TestRemount(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "rw")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	if err := SetConfig(&Config{}, false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	// Re-mount with different options
+	data := "resctrl " + info.resctrlPath + " resctrl rw,mba_MBps 0 0\n"
+	if err := os.WriteFile(mountInfoPath, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write mountinfo mock: %v", err)
+	}
+	if err := SetConfig(&Config{}, false); !errors.Is(err, ErrResctrlRemounted) {
+		t.Fatalf("expected ErrResctrlRemounted, got %v", err)
+	}
+
+	// Re-initialization picks up the new mount options
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt re-initialization failed: %v", err)
+	}
+	if !info.mb.mbpsEnabled {
+		t.Errorf("mba_MBps not detected after re-initialization")
+	}
+	if err := SetConfig(&Config{}, false); err != nil {
+		t.Fatalf("rdt configuration failed after re-initialization: %v", err)
+	}
+}
+
+func TestInitializeStrict(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	// Groups matching our own prefix are conflicting, too
+	rdt, info = nil, nil
+	err = InitializeStrict(mockGroupPrefix, []string{"gores"})
+	testutils.VerifyError(t, err, 1, []string{"conflicting resctrl groups found: goresctrl.Guaranteed, goresctrl.Stale"})
+	if rdt != nil || info != nil {
+		t.Errorf("package state changed despite conflicting groups")
+	}
+
+	// Empty prefix
+	err = InitializeStrict("", []string{"non_goresctrl."})
+	testutils.VerifyError(t, err, 1, []string{"conflicting resctrl groups found: non_goresctrl.Group"})
+
+	// Overlapping conflict prefixes report each group once
+	err = InitializeStrict(mockGroupPrefix, []string{"", "G"})
+	testutils.VerifyError(t, err, 1, []string{"conflicting resctrl groups found: Guaranteed, goresctrl.Guaranteed, goresctrl.Stale, non_goresctrl.Group"})
+
+	if err := InitializeStrict(mockGroupPrefix, []string{"foo."}); err != nil {
+		t.Errorf("InitializeStrict() failed: %v", err)
+	}
+	if rdt == nil {
+		t.Errorf("package not initialized by InitializeStrict()")
+	}
+
+	// Class directories of the active instance are not conflicting on
+	// re-initialization
+	if err := InitializeStrict(mockGroupPrefix, []string{mockGroupPrefix}); err != nil {
+		t.Errorf("InitializeStrict() failed: %v", err)
+	}
+
+	// Previously active package state is left intact on failure
+	prev := rdt
+	err = InitializeStrict(mockGroupPrefix, []string{"non_goresctrl."})
+	testutils.VerifyError(t, err, 1, []string{"conflicting resctrl groups found: non_goresctrl.Group"})
+	if rdt != prev {
+		t.Errorf("package state changed despite conflicting groups")
+	}
+}
+
+func TestInitializeTooManyGroups(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.l2", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	addGroup := func(name string) {
+		dir := filepath.Join(mockFs.baseDir, "resctrl", name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("failed to create resctrl group: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "tasks"), []byte{}, 0644); err != nil {
+			t.Fatalf("failed to write tasks: %v", err)
+		}
+	}
+
+	// 4 CLOSIDs available, one consumed by the root group
+	for i := 0; i < 3; i++ {
+		addGroup(fmt.Sprintf("group-%d", i))
+	}
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	addGroup("group-3")
+	err = Initialize(mockGroupPrefix)
+	testutils.VerifyError(t, err, 1, []string{"inconsistent resctrl state: 5 control groups found but only 4 CLOSIDs available"})
+}
+
+func TestInitializeWithRoot(t *testing.T) {
+	customFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer customFs.delete()
+
+	// The system-wide mock, detected by plain Initialize()
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	root := filepath.Join(customFs.baseDir, "resctrl")
+
+	// No mount info at all
+	if err := InitializeWithRoot(mockGroupPrefix, root, ""); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	if info.resctrlPath != root {
+		t.Errorf("unexpected resctrl path %q, expected %q", info.resctrlPath, root)
+	}
+	if _, ok := GetClass("Guaranteed"); !ok {
+		t.Errorf("class not discovered from custom resctrl root")
+	}
+	if err := SetConfig(&Config{}, false); err != nil {
+		t.Errorf("rdt configuration failed: %v", err)
+	}
+
+	// Mount options are taken from mount info, mount point is overridden
+	mountInfo := filepath.Join(customFs.baseDir, "custom-mounts")
+	if err := os.WriteFile(mountInfo, []byte("resctrl /non-existent resctrl rw,mba_MBps 0 0\n"), 0644); err != nil {
+		t.Fatalf("failed to write mountinfo mock: %v", err)
+	}
+	if err := InitializeWithRoot(mockGroupPrefix, root, mountInfo); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	if info.resctrlPath != root {
+		t.Errorf("unexpected resctrl path %q, expected %q", info.resctrlPath, root)
+	}
+	if !info.mb.mbpsEnabled {
+		t.Errorf("mba_MBps not detected from custom mount info")
+	}
+	if err := SetConfig(&Config{}, false); err != nil {
+		t.Errorf("rdt configuration failed: %v", err)
+	}
+
+	// Plain Initialize() must not reuse the custom settings
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	if expected := filepath.Join(mockFs.baseDir, "resctrl"); info.resctrlPath != expected {
+		t.Errorf("unexpected resctrl path %q, expected %q", info.resctrlPath, expected)
+	}
+	if info.mb.mbpsEnabled {
+		t.Errorf("mba_MBps detected from custom mount info after re-initialization")
+	}
+	if err := SetConfig(&Config{}, false); err != nil {
+		t.Errorf("rdt configuration failed: %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := InitializeReadOnly(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	// Discovery works but empty monitoring groups must not be pruned
+	if err := DiscoverClasses(mockGroupPrefix); err != nil {
+		t.Fatalf("DiscoverClasses() failed: %v", err)
+	}
+	if n := len(GetClasses()); n != 3 {
+		t.Errorf("expected 3 classes, got %d", n)
+	}
+	cls, _ := GetClass("Guaranteed")
+	if _, ok := cls.GetMonGroup("predefined_group_empty"); !ok {
+		t.Errorf("empty monitoring group pruned in read-only mode")
+	}
+
+	// Reading works
+	if _, err := cls.GetPids(); err != nil {
+		t.Errorf("GetPids() failed: %v", err)
+	}
+	mg, _ := cls.GetMonGroup("predefined_group_live")
+	if md := mg.GetMonData(); len(md.L3) == 0 {
+		t.Errorf("no monitoring data in read-only mode")
+	}
+
+	// All modifications must fail
+	if err := SetConfig(&Config{}, true); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from SetConfig(), got %v", err)
+	}
+	if err := cls.AddPids("10"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from AddPids(), got %v", err)
+	}
+	if err := mg.AddPids("10"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from MonGroup.AddPids(), got %v", err)
+	}
+	if err := cls.(CtrlGroupExt).AddPidsPerThread("10"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from AddPidsPerThread(), got %v", err)
+	}
+	if err := cls.(CtrlGroupExt).RemovePids("10"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from RemovePids(), got %v", err)
+	}
+	if err := MigrateProcess("10", cls.Name()); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from MigrateProcess(), got %v", err)
+	}
+	if _, err := cls.(CtrlGroupExt).AddCgroup("test.slice"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from AddCgroup(), got %v", err)
+	}
+	if _, err := cls.CreateMonGroup("new_group", nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from CreateMonGroup(), got %v", err)
+	}
+	if err := cls.DeleteMonGroup("predefined_group_empty"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from DeleteMonGroup(), got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(info.resctrlPath, mockGroupPrefix+"Guaranteed", "mon_groups", mockGroupPrefix+"new_group")); !os.IsNotExist(err) {
+		t.Errorf("monitoring group created in read-only mode")
+	}
+
+	// Re-initializing in normal mode lifts the restrictions
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	if err := SetConfig(&Config{}, true); err != nil {
+		t.Errorf("SetConfig() failed after re-initialization: %v", err)
+	}
+}
+
+func TestEmptyConfig(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	// Set group remove function so that mock groups can be removed
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	fullSchemata := "L3:0=fffff;1=fffff;2=fffff;3=fffff\nMB:0=100;1=100;2=100;3=100\n"
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 50%
+    mbAllocation: [50%]
+    classes:
+      system/default:
+      class-1:
+`
+	for _, tc := range []struct {
+		name   string
+		config *Config
+	}{
+		{name: "empty config", config: &Config{}},
+		{name: "nil config", config: nil},
+		{name: "partitions without classes", config: parseTestConfig(t, `
+partitions:
+  part-1:
+    l3Allocation: 100%
+`)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := SetConfigFromData([]byte(conf), true); err != nil {
+				t.Fatalf("rdt configuration failed: %v", err)
+			}
+			mockFs.verifyTextFile("schemata", "L3:0=3ff;1=3ff;2=3ff;3=3ff\nMB:0=50;1=50;2=50;3=50\n")
+
+			// Add stale groups not known by the configuration
+			mockFs.copyFromOrig("goresctrl.Guaranteed", "goresctrl.Guaranteed")
+			mockFs.copyFromOrig("goresctrl.Stale", "goresctrl.Stale")
+
+			// Non-empty classes must block the configuration without
+			// removing any of the classes
+			cls, _ := GetClass("class-1")
+			if err := os.WriteFile(cls.(*ctrlGroup).path("tasks"), []byte("10\n"), 0644); err != nil {
+				t.Fatalf("failed to write tasks: %v", err)
+			}
+			if err := SetConfig(tc.config, false); err == nil {
+				t.Fatalf("rdt configuration succeeded unexpectedly")
+			}
+			for _, dir := range []string{"goresctrl.class-1", "goresctrl.Guaranteed", "goresctrl.Stale"} {
+				if _, err := os.Stat(filepath.Join(info.resctrlPath, dir)); err != nil {
+					t.Errorf("resctrl group %q removed by refused configuration: %v", dir, err)
+				}
+			}
+
+			// Forced configuration removes all classes and resets the root
+			if err := SetConfig(tc.config, true); err != nil {
+				t.Fatalf("forced rdt configuration failed: %v", err)
+			}
+			classes := GetClasses()
+			if len(classes) != 1 || classes[0].Name() != RootClassName {
+				t.Errorf("only the root class expected, got %v", classes)
+			}
+			if g, err := resctrlGroupsFromFs(mockGroupPrefix, info.resctrlPath); err != nil {
+				t.Errorf("failed to read resctrl groups: %v", err)
+			} else if len(g) != 0 {
+				t.Errorf("stale resctrl groups left: %v", g)
+			}
+			mockFs.verifyTextFile("schemata", fullSchemata)
+		})
+	}
+
+	// A non-empty configuration leaves an unconfigured root class untouched
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	conf = `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+`
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	mockFs.verifyTextFile("schemata", "L3:0=3ff;1=3ff;2=3ff;3=3ff\nMB:0=50;1=50;2=50;3=50\n")
+}
+
+func TestShutdown(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 50%
+    mbAllocation: [50%]
+    classes:
+      system/default:
+      class-1:
+`
+	setup := func() {
+		if err := Initialize(mockGroupPrefix); err != nil {
+			t.Fatalf("rdt initialization failed: %v", err)
+		}
+		if err := SetConfigFromData([]byte(conf), true); err != nil {
+			t.Fatalf("rdt configuration failed: %v", err)
+		}
+	}
+	classDir := filepath.Join(mockFs.baseDir, "resctrl", mockGroupPrefix+"class-1")
+
+	// Shutdown without removing groups only drops the state
+	setup()
+	if err := Shutdown(false, false); err != nil {
+		t.Fatalf("Shutdown() failed: %v", err)
+	}
+	if rdt != nil || info != nil {
+		t.Errorf("package state not cleared by Shutdown()")
+	}
+	if len(GetClasses()) != 0 {
+		t.Errorf("classes available after Shutdown()")
+	}
+	if _, err := os.Stat(classDir); err != nil {
+		t.Errorf("resctrl group removed by Shutdown(): %v", err)
+	}
+
+	// Shutdown of an uninitialized package is a no-op
+	if err := Shutdown(true, true); err != nil {
+		t.Errorf("Shutdown() of an uninitialized package failed: %v", err)
+	}
+
+	// Removal of non-empty groups is refused without force
+	setup()
+	if err := os.WriteFile(filepath.Join(classDir, "tasks"), []byte("10\n"), 0644); err != nil {
+		t.Fatalf("failed to write tasks: %v", err)
+	}
+	if err := Shutdown(true, false); err == nil {
+		t.Errorf("Shutdown() with non-empty groups succeeded unexpectedly")
+	}
+	if rdt == nil {
+		t.Errorf("package state cleared by failed Shutdown()")
+	}
+
+	// Forced removal
+	if err := Shutdown(true, true); err != nil {
+		t.Fatalf("forced Shutdown() failed: %v", err)
+	}
+	if rdt != nil || info != nil {
+		t.Errorf("package state not cleared by Shutdown()")
+	}
+	if _, err := os.Stat(classDir); !os.IsNotExist(err) {
+		t.Errorf("resctrl group not removed by Shutdown(): %v", err)
+	}
+	mockFs.verifyTextFile("schemata", "L3:0=fffff;1=fffff;2=fffff;3=fffff\nMB:0=100;1=100;2=100;3=100\n")
+}
+
+func TestGetClassConfig(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 50%
+    mbAllocation: [50%]
+    classes:
+      class-1:
+        l3Allocation: 50%
+      class-2:
+        monitoringOnly: true
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	ci, ok := GetClassConfig("class-1")
+	if !ok {
+		t.Fatalf("class-1 not found")
+	}
+	testutils.VerifyDeepEqual(t, "class-1", ClassInfo{
+		Partition: "part-1",
+		L3:        []string{"L3:0=1f;1=1f;2=1f;3=1f"},
+		MB:        "MB:0=50;1=50;2=50;3=50",
+	}, ci)
+
+	ci, ok = GetClassConfig("class-2")
+	if !ok {
+		t.Fatalf("class-2 not found")
+	}
+	testutils.VerifyDeepEqual(t, "class-2", ClassInfo{Partition: "part-1", MonitoringOnly: true}, ci)
+
+	// Root class not in the configuration is not managed by it
+	if _, ok := GetClassConfig(RootClassName); ok {
+		t.Errorf("unmanaged root class unexpectedly found")
+	}
+
+	// Root class of an empty configuration is reset to full allocation
+	if err := SetConfig(&Config{}, true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	ci, ok = GetClassConfig(RootClassName)
+	if !ok {
+		t.Fatalf("root class not found")
+	}
+	testutils.VerifyDeepEqual(t, "root class", ClassInfo{
+		L3: []string{"L3:0=fffff;1=fffff;2=fffff;3=fffff"},
+		MB: "MB:0=100;1=100;2=100;3=100",
+	}, ci)
+
+	if _, ok := GetClassConfig("non-existent"); ok {
+		t.Errorf("non-existent class unexpectedly found")
+	}
+}
+
+func TestUpdateClass(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 50%
+    mbAllocation: [50%]
+    classes:
+      class-1:
+        l3Allocation: 50%
+      class-2:
+        mbAllocation: [50%]
+  part-2:
+    l3Allocation: 50%
+    mbAllocation: [100%]
+    mbProportional: true
+    classes:
+      class-3:
+        mbAllocation: [60%]
+      class-4:
+        mbAllocation: [40%]
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	cls, _ := GetClass("class-1")
+	if err := os.WriteFile(cls.(*ctrlGroup).path("tasks"), []byte("10\n"), 0644); err != nil {
+		t.Fatalf("failed to write tasks: %v", err)
+	}
+	if err := os.Mkdir(cls.(*ctrlGroup).path("mon_groups"), 0755); err != nil {
+		t.Fatalf("failed to create mon_groups: %v", err)
+	}
+	if _, err := cls.CreateMonGroup("mg-1", nil); err != nil {
+		t.Fatalf("CreateMonGroup() failed: %v", err)
+	}
+
+	parseClass := func(data string) ClassConfig {
+		c := ClassConfig{}
+		if err := yaml.Unmarshal([]byte(data), &c); err != nil {
+			t.Fatalf("failed to parse class config: %v", err)
+		}
+		return c
+	}
+	classSchemata := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(mockFs.baseDir, "resctrl", mockGroupPrefix+name, "schemata"))
+		if err != nil {
+			t.Fatalf("failed to read schemata: %v", err)
+		}
+		return string(data)
+	}
+	class2Schemata := classSchemata("class-2")
+
+	// Update the allocation of one class
+	if err := UpdateClass("class-1", parseClass(`
+l3Allocation: 25%
+mbAllocation: [80%]
+annotations:
+  foo: bar
+`)); err != nil {
+		t.Fatalf("UpdateClass() failed: %v", err)
+	}
+	testutils.VerifyStrings(t, "L3:0=7;1=7;2=7;3=7\nMB:0=40;1=40;2=40;3=40\n", classSchemata("class-1"))
+	testutils.VerifyStrings(t, class2Schemata, classSchemata("class-2"))
+	mockFs.verifyTextFile(cls.(*ctrlGroup).relPath("tasks"), "10\n")
+	if _, ok := cls.GetMonGroup("mg-1"); !ok {
+		t.Errorf("monitoring group removed by UpdateClass()")
+	}
+	testutils.VerifyDeepEqual(t, "annotations", map[string]string{"foo": "bar"}, cls.(*ctrlGroup).annotations)
+	if ci, _ := GetClassConfig("class-1"); ci.MB != "MB:0=40;1=40;2=40;3=40" {
+		t.Errorf("unexpected class config after UpdateClass(): %+v", ci)
+	}
+
+	// Errors
+	for _, tc := range []struct {
+		name  string
+		class string
+		conf  string
+		err   string
+	}{
+		{
+			name:  "non-existent class",
+			class: "class-5",
+			err:   `class "class-5" not found in the active configuration`,
+		},
+		{
+			name:  "monitoring-only change",
+			class: "class-2",
+			conf:  "monitoringOnly: true",
+			err:   `changing monitoringOnly of class "class-2" requires a full reconfiguration`,
+		},
+		{
+			name:  "closid change",
+			class: "class-2",
+			conf:  "closid: 5",
+			err:   `changing the CLOSID of class "class-2" requires a full reconfiguration`,
+		},
+		{
+			name:  "proportional allocation affects other classes",
+			class: "class-3",
+			conf:  "mbAllocation: [20%]",
+			err:   `update of class "class-3" would change the allocation of class "class-4"`,
+		},
+		{
+			name:  "invalid allocation",
+			class: "class-2",
+			conf:  "l3Allocation: 200%",
+			err:   `invalid configuration for class "class-2"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := UpdateClass(tc.class, parseClass(tc.conf))
+			testutils.VerifyError(t, err, 1, []string{tc.err})
+		})
+	}
+	testutils.VerifyStrings(t, class2Schemata, classSchemata("class-2"))
+}
+
+func TestSwapClassAllocations(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 50%
+    mbAllocation: [100%]
+    classes:
+      class-1:
+        l3Allocation: 80%
+        mbAllocation: [80%]
+      class-2:
+        l3Allocation: 20%
+        mbAllocation: [20%]
+      class-3:
+        monitoringOnly: true
+  part-2:
+    l3Allocation: 50%
+    classes:
+      class-4:
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	cls, _ := GetClass("class-1")
+	if err := os.WriteFile(cls.(*ctrlGroup).path("tasks"), []byte("10\n"), 0644); err != nil {
+		t.Fatalf("failed to write tasks: %v", err)
+	}
+	classSchemata := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(mockFs.baseDir, "resctrl", mockGroupPrefix+name, "schemata"))
+		if err != nil {
+			t.Fatalf("failed to read schemata: %v", err)
+		}
+		return string(data)
+	}
+	class1Schemata := classSchemata("class-1")
+	class2Schemata := classSchemata("class-2")
+	class4Schemata := classSchemata("class-4")
+
+	if err := SwapClassAllocations("class-1", "class-2"); err != nil {
+		t.Fatalf("SwapClassAllocations() failed: %v", err)
+	}
+	testutils.VerifyStrings(t, class2Schemata, classSchemata("class-1"))
+	testutils.VerifyStrings(t, class1Schemata, classSchemata("class-2"))
+	testutils.VerifyStrings(t, class4Schemata, classSchemata("class-4"))
+	mockFs.verifyTextFile(cls.(*ctrlGroup).relPath("tasks"), "10\n")
+	if ci, _ := GetClassConfig("class-1"); ci.MB != "MB:0=20;1=20;2=20;3=20" {
+		t.Errorf("unexpected class config after SwapClassAllocations(): %+v", ci)
+	}
+
+	// Errors
+	for _, tc := range []struct {
+		name string
+		a    string
+		b    string
+		err  string
+	}{
+		{
+			name: "non-existent class",
+			a:    "class-1",
+			b:    "class-5",
+			err:  `class "class-5" not found in the active configuration`,
+		},
+		{
+			name: "monitoring-only class",
+			a:    "class-3",
+			b:    "class-1",
+			err:  `cannot swap allocations of monitoring-only class "class-3"`,
+		},
+		{
+			name: "different partitions",
+			a:    "class-1",
+			b:    "class-4",
+			err:  `cannot swap allocations of classes "class-1" and "class-4" in different partitions`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := SwapClassAllocations(tc.a, tc.b)
+			testutils.VerifyError(t, err, 1, []string{tc.err})
+		})
+	}
+
+	// Allocations are shrunk before growing them
+	testutils.VerifyDeepEqual(t, "shrunk schemata",
+		[]string{"L3:0=f;1=ff;2=f\n", "MB:0=10;1=50\n"},
+		shrinkSchemata(
+			[]string{"L3:0=ff;1=ff;2=f\n", "MB:0=50;1=50\n"},
+			[]string{"L3:0=f;1=fff;2=fff\n", "MB:0=10;1=60\n"}))
+}
+
+func TestDiffConfig(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	current := parseTestConfig(t, `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+        l3Allocation: 50%
+      class-2:
+        l3Allocation: 100%
+      class-3:
+`)
+	desired := parseTestConfig(t, `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      system/default:
+        l3Allocation: 100%
+      class-1:
+        l3Allocation: 25%
+      class-2:
+        l3Allocation: 100%
+      class-4:
+`)
+
+	// Everything is new before configuration
+	diff, err := DiffConfig(current)
+	if err != nil {
+		t.Fatalf("DiffConfig() failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, []string{"class-1", "class-2", "class-3"}, diff.Added)
+
+	if err := SetConfig(current, false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	// The root class, not specified in the configuration, is left untouched
+	// by SetConfig() and must not show up in the diff
+	diff, err = DiffConfig(current)
+	if err != nil {
+		t.Fatalf("DiffConfig() failed: %v", err)
+	}
+	if !diff.IsEmpty() {
+		t.Errorf("expected empty diff, got %s", utils.DumpJSON(diff))
+	}
+
+	diff, err = DiffConfig(desired)
+	if err != nil {
+		t.Fatalf("DiffConfig() failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, []string{"class-4"}, diff.Added)
+	testutils.VerifyStringSlices(t, []string{"class-3"}, diff.Removed)
+	// Root class not managed by the current configuration gets configured
+	testutils.VerifyStringSlices(t, []string{"class-1", RootClassName}, diff.Modified)
+	testutils.VerifyDeepEqual(t, "schemata diff", map[string]SchemataChange{
+		"class-1": {
+			Current: []string{"L3:0=3ff;1=3ff;2=3ff;3=3ff"},
+			Desired: []string{"L3:0=1f;1=1f;2=1f;3=1f"},
+		},
+		"class-3": {
+			Current: []string{"L3:0=fffff;1=fffff;2=fffff;3=fffff"},
+		},
+		"class-4": {
+			Desired: []string{"L3:0=fffff;1=fffff;2=fffff;3=fffff"},
+		},
+		RootClassName: {
+			Desired: []string{"L3:0=fffff;1=fffff;2=fffff;3=fffff"},
+		},
+	}, diff.Schemata)
+
+	// Root class is never removed, a configuration without it leaves the
+	// root untouched
+	if err := SetConfig(desired, false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	diff, err = DiffConfig(current)
+	if err != nil {
+		t.Fatalf("DiffConfig() failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, []string{"class-4"}, diff.Removed)
+	if err := SetConfig(current, true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	if diff, err := DiffConfig(current); err != nil {
+		t.Errorf("DiffConfig() failed: %v", err)
+	} else if !diff.IsEmpty() {
+		t.Errorf("expected empty diff, got %s", utils.DumpJSON(diff))
+	}
+
+	if _, err := DiffConfig(parseTestConfig(t, "partitions:\n  part-1:\n    l3Allocation: 200%\n")); err == nil {
+		t.Errorf("DiffConfig() with invalid config succeeded unexpectedly")
+	}
+}
+
+func TestClassAnnotations(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+        annotations:
+          team: a
+          tier: gold
+      class-2:
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	// Mock fs does not create mon_groups automatically
+	for _, name := range []string{"class-1", "class-2"} {
+		cls, _ := GetClass(name)
+		if err := os.Mkdir(cls.(*ctrlGroup).path("mon_groups"), 0755); err != nil {
+			t.Fatalf("failed to create mon_groups: %v", err)
+		}
+	}
+
+	cls, _ := GetClass("class-1")
+	mg, err := cls.CreateMonGroup("mg-1", map[string]string{"tier": "silver", "pod": "p1"})
+	if err != nil {
+		t.Fatalf("CreateMonGroup() failed: %v", err)
+	}
+	testutils.VerifyDeepEqual(t, "annotations", map[string]string{"team": "a", "tier": "silver", "pod": "p1"}, mg.GetAnnotations())
+
+	cls, _ = GetClass("class-2")
+	mg, err = cls.CreateMonGroup("mg-2", map[string]string{"pod": "p2"})
+	if err != nil {
+		t.Fatalf("CreateMonGroup() failed: %v", err)
+	}
+	testutils.VerifyDeepEqual(t, "annotations", map[string]string{"pod": "p2"}, mg.GetAnnotations())
+}
+
+func TestRootClassMissing(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, []string{}, GetConfigWarnings())
+
+	// Root class is re-added with a warning
+	delete(rdt.classes, RootClassName)
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, []string{"root class missing from runtime data, re-adding..."}, GetConfigWarnings())
+	if _, ok := GetClass(RootClassName); !ok {
+		t.Errorf("root class not re-added")
+	}
+
+	// Warnings are reset on every configuration update
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, []string{}, GetConfigWarnings())
+
+	// Strict mode refuses the configuration
+	delete(rdt.classes, RootClassName)
+	strictConf := `
+options:
+  strictRootClass: true
+` + conf
+	if err := SetConfigFromData([]byte(strictConf), false); !errors.Is(err, ErrRootClassMissing) {
+		t.Errorf("expected ErrRootClassMissing from SetConfig(), got %v", err)
+	}
+}
+
+func TestMigrateProcess(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	tasksPath := rdt.classes["class-1"].path("tasks")
+	resetTasks := func() {
+		if err := os.WriteFile(tasksPath, nil, 0644); err != nil {
+			t.Fatalf("failed to reset tasks: %v", err)
+		}
+	}
+
+	// Process 40 with a single thread exists, process 50 does not
+	procRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(procRoot, "proc/40/task/40"), 0755); err != nil {
+		t.Fatalf("failed to create mock proc: %v", err)
+	}
+	goresctrlpath.SetPrefix(procRoot)
+	defer goresctrlpath.SetPrefix("/")
+
+	resetTasks()
+	if err := MigrateProcess("40", "class-1"); err != nil {
+		t.Errorf("MigrateProcess() failed: %v", err)
+	}
+	mockFs.verifyTextFile(rdt.classes["class-1"].relPath("tasks"), "40\n")
+
+	resetTasks()
+	if err := MigrateProcessPerThread("40", "class-1"); err != nil {
+		t.Errorf("MigrateProcessPerThread() failed: %v", err)
+	}
+	mockFs.verifyTextFile(rdt.classes["class-1"].relPath("tasks"), "40\n")
+
+	resetTasks()
+	if err := MigrateProcess("50", "class-1"); !errors.Is(err, ErrProcessExited) {
+		t.Errorf("expected ErrProcessExited from MigrateProcess(), got %v", err)
+	}
+	if err := MigrateProcessPerThread("50", "class-1"); !errors.Is(err, ErrProcessExited) {
+		t.Errorf("expected ErrProcessExited from MigrateProcessPerThread(), got %v", err)
+	}
+
+	if err := MigrateProcess("40", "non-existent"); err == nil {
+		t.Errorf("MigrateProcess() to a non-existent class succeeded unexpectedly")
+	}
+}
+
+func TestAddCgroup(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	sysRoot := t.TempDir()
+	goresctrlpath.SetPrefix(sysRoot)
+	defer goresctrlpath.SetPrefix("/")
+
+	writeFile := func(path, data string) {
+		path = filepath.Join(sysRoot, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("failed to write %q: %v", path, err)
+		}
+	}
+	resetTasks := func() {
+		if err := os.WriteFile(rdt.classes["class-1"].path("tasks"), nil, 0644); err != nil {
+			t.Fatalf("failed to reset tasks: %v", err)
+		}
+	}
+
+	cls, _ := GetClass("class-1")
+
+	// Unified hierarchy, path relative to the cgroup mount point
+	writeFile("sys/fs/cgroup/test.slice/cgroup.threads", "100\n101\n102\n")
+	resetTasks()
+	if n, err := cls.(CtrlGroupExt).AddCgroup("test.slice"); err != nil {
+		t.Errorf("AddCgroup() failed: %v", err)
+	} else if n != 3 {
+		t.Errorf("AddCgroup() returned %d, expected 3", n)
+	}
+	mockFs.verifyTextFile(rdt.classes["class-1"].relPath("tasks"), "100,101,102\n")
+
+	// No cgroup.threads (cgroup v1), absolute path
+	writeFile("sys/fs/cgroup/cpu/test.slice/cgroup.procs", "200\n300\n")
+	writeFile("proc/200/task/200/stat", "")
+	writeFile("proc/200/task/201/stat", "")
+	resetTasks()
+	if n, err := cls.(CtrlGroupExt).AddCgroup("/sys/fs/cgroup/cpu/test.slice"); err != nil {
+		t.Errorf("AddCgroup() failed: %v", err)
+	} else if n != 2 {
+		t.Errorf("AddCgroup() returned %d, expected 2", n)
+	}
+	mockFs.verifyTextFile(rdt.classes["class-1"].relPath("tasks"), "200,201\n")
+
+	// Non-existent cgroup
+	if _, err := cls.(CtrlGroupExt).AddCgroup("non-existent.slice"); err == nil {
+		t.Errorf("AddCgroup() of non-existent cgroup succeeded unexpectedly")
+	}
+}
+
+func TestSnapshotConfig(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	// Set group remove function so that mock groups can be removed
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+        l3Allocation: 50%
+      class-2:
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	// Mock fs does not create tasks and mon_groups automatically
+	writeTasks := func(g ResctrlGroup, tasks string) {
+		var path string
+		switch g := g.(type) {
+		case *ctrlGroup:
+			path = g.path("tasks")
+		case *monGroup:
+			path = g.path("tasks")
+		}
+		if err := os.WriteFile(path, []byte(tasks), 0644); err != nil {
+			t.Fatalf("failed to write tasks: %v", err)
+		}
+	}
+	for _, name := range []string{"class-1", "class-2"} {
+		cls, _ := GetClass(name)
+		if err := os.Mkdir(cls.(*ctrlGroup).path("mon_groups"), 0755); err != nil {
+			t.Fatalf("failed to create mon_groups: %v", err)
+		}
+		writeTasks(cls, "")
+	}
+	cls, _ := GetClass("class-1")
+	writeTasks(cls, "10\n11\n")
+	mg, err := cls.CreateMonGroup("mg-1", map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatalf("CreateMonGroup() failed: %v", err)
+	}
+	writeTasks(mg, "12\n")
+
+	snapshot, err := SnapshotConfig()
+	if err != nil {
+		t.Fatalf("SnapshotConfig() failed: %v", err)
+	}
+	class1Schemata := snapshot.Classes["class-1"].Schemata
+
+	// The snapshot must not share data with the active configuration
+	delete(rdt.rawConf.Partitions["part-1"].Classes, "class-2")
+	if _, ok := snapshot.Config.Partitions["part-1"].Classes["class-2"]; !ok {
+		t.Errorf("configuration of the snapshot modified via the active configuration")
+	}
+
+	// Mess up the configuration
+	conf = `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+        l3Allocation: 25%
+      class-3:
+`
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	cls, _ = GetClass("class-1")
+	mg, err = cls.CreateMonGroup("mg-2", nil)
+	if err != nil {
+		t.Fatalf("CreateMonGroup() failed: %v", err)
+	}
+	writeTasks(mg, "13\n")
+	writeTasks(cls, "")
+	writeTasks(rdt.classes["class-1"].monGroups["mg-1"], "14\n")
+
+	// Restore
+	if err := RestoreConfig(snapshot); err != nil {
+		t.Fatalf("RestoreConfig() failed: %v", err)
+	}
+
+	names := []string{}
+	for _, cls := range GetClasses() {
+		names = append(names, cls.Name())
+	}
+	testutils.VerifyStringSlices(t, []string{"class-1", "class-2", RootClassName}, names)
+
+	mockFs.verifyTextFile(rdt.classes["class-1"].relPath("schemata"), class1Schemata)
+	mockFs.verifyTextFile(rdt.classes["class-1"].relPath("tasks"), "10,11\n")
+
+	cls, _ = GetClass("class-1")
+	if _, ok := cls.GetMonGroup("mg-2"); ok {
+		t.Errorf("monitoring group created after the snapshot not removed")
+	}
+	mg, ok := cls.GetMonGroup("mg-1")
+	if !ok {
+		t.Fatalf("monitoring group mg-1 not restored")
+	}
+	testutils.VerifyDeepEqual(t, "annotations", map[string]string{"a": "b"}, mg.GetAnnotations())
+	mockFs.verifyTextFile(rdt.classes["class-1"].monGroups["mg-1"].relPath("tasks"), "12\n")
+
+	if err := RestoreConfig(nil); err == nil {
+		t.Errorf("RestoreConfig(nil) succeeded unexpectedly")
+	}
+}
+
+func TestClassCreationOrder(t *testing.T) {
+	conf := `
+partitions:
+  part-1:
+    classes:
+      class-c:
+      class-a:
+      class-e:
+  part-2:
+    classes:
+      class-d:
+      class-b:
+      system/default:
+`
+	applyConfig := func() []string {
+		mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+		if err != nil {
+			t.Fatalf("failed to set up mock resctrl fs: %v", err)
+		}
+		defer mockFs.delete()
+
+		if err := Initialize(mockGroupPrefix); err != nil {
+			t.Fatalf("rdt initialization failed: %v", err)
+		}
+
+		created := []string{}
+		groupCreateFunc = func(path string, perm os.FileMode) error {
+			err := os.Mkdir(path, perm)
+			if err == nil {
+				created = append(created, filepath.Base(path))
+			}
+			return err
+		}
+		defer func() { groupCreateFunc = os.Mkdir }()
+
+		if err := SetConfigFromData([]byte(conf), true); err != nil {
+			t.Fatalf("rdt configuration failed: %v", err)
+		}
+		return created
+	}
+
+	expected := []string{"goresctrl.class-a", "goresctrl.class-b", "goresctrl.class-c", "goresctrl.class-d", "goresctrl.class-e"}
+	testutils.VerifyStringSlices(t, expected, applyConfig())
+	testutils.VerifyStringSlices(t, expected, applyConfig())
+}
+
+func TestClassClosid(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := `
+partitions:
+  part-1:
+    classes:
+      class-c:
+      class-b:
+        closid: 2
+      class-a:
+      class-d:
+        closid: 1
+`
+	c := &Config{}
+	if err := yaml.Unmarshal([]byte(conf), c); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	resolved, err := c.resolve()
+	if err != nil {
+		t.Fatalf("resolve() failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, []string{"class-d", "class-b", "class-a", "class-c"}, resolved.Classes.creationOrder())
+
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	// CLOSIDs of the groups visible with the "debug" mount option, CLOSID
+	// of class-d used by another group
+	for g, closid := range map[string]string{
+		"":                    "0",
+		"non_goresctrl.Group": "1",
+		"Guaranteed":          "3",
+		"goresctrl.class-a":   "4",
+		"goresctrl.class-b":   "2",
+		"goresctrl.class-c":   "5",
+		"goresctrl.class-d":   "6",
+	} {
+		if err := os.WriteFile(filepath.Join(mockFs.baseDir, "resctrl", g, "ctrl_hw_id"), []byte(closid+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write ctrl_hw_id: %v", err)
+		}
+	}
+	err = SetConfigFromData([]byte(conf), true)
+	testutils.VerifyError(t, err, 1, []string{`CLOSID 1 requested for class "class-d" already used by resctrl group "non_goresctrl.Group"`})
+
+	// Existing group with a different CLOSID
+	conf = `
+partitions:
+  part-1:
+    classes:
+      class-b:
+        closid: 2
+      class-c:
+        closid: 7
+`
+	err = SetConfigFromData([]byte(conf), true)
+	testutils.VerifyError(t, err, 1, []string{`class "class-c" has CLOSID 5 instead of the requested 7`})
+}
+
+func TestConfigMonitorGroups(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	monGroupNames := func(cls CtrlGroup) []string {
+		names := []string{}
+		for _, mg := range cls.GetMonGroups() {
+			names = append(names, mg.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	conf := `
+partitions:
+  part-1:
+    classes:
+      Guaranteed:
+        annotations:
+          a: class-a
+          b: class-b
+        monitorGroups:
+          mg-1:
+            annotations:
+              b: mg-b
+          mg-2:
+`
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	cls, _ := GetClass("Guaranteed")
+	testutils.VerifyStringSlices(t, []string{"mg-1", "mg-2", "predefined_group_live"}, monGroupNames(cls))
+	mg, _ := cls.GetMonGroup("mg-1")
+	testutils.VerifyDeepEqual(t, "annotations", map[string]string{"a": "class-a", "b": "mg-b"}, mg.GetAnnotations())
+
+	// Mock fs does not create tasks automatically
+	for _, name := range []string{"mg-1", "mg-2"} {
+		mg, _ := cls.GetMonGroup(name)
+		if err := os.WriteFile(mg.(*monGroup).path("tasks"), nil, 0644); err != nil {
+			t.Fatalf("failed to write tasks: %v", err)
+		}
+	}
+
+	// Empty monitoring groups from the config are not pruned
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, []string{"mg-1", "mg-2", "predefined_group_live"}, monGroupNames(cls))
+
+	// Dropped from the config, empty group gets pruned
+	conf = `
+partitions:
+  part-1:
+    classes:
+      Guaranteed:
+        monitorGroups:
+          mg-2:
+`
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+	cls, _ = GetClass("Guaranteed")
+	testutils.VerifyStringSlices(t, []string{"mg-2", "predefined_group_live"}, monGroupNames(cls))
+}
+
+func TestMonitoringOnlyClass(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	// Set group remove function so that mock groups can be removed
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 50%
+    mbAllocation: [50%]
+    mbProportional: true
+    classes:
+      mon-1:
+        monitoringOnly: true
+      class-1:
+        l3Allocation: 50%
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	cls, ok := GetClass("mon-1")
+	if !ok {
+		t.Fatalf("monitoring-only class not created")
+	}
+	if _, err := os.Stat(cls.(*ctrlGroup).path("schemata")); !os.IsNotExist(err) {
+		t.Errorf("schemata of monitoring-only class written")
+	}
+	// Monitoring-only class must not take a share of the MB allocation
+	cls, _ = GetClass("class-1")
+	mockFs.verifyTextFile(cls.(*ctrlGroup).relPath("schemata"), "L3:0=1f;1=1f;2=1f;3=1f\nMB:0=50;1=50;2=50;3=50\n")
+
+	if tbl := FormatAllocationTable(); strings.Contains(tbl, "mon-1") {
+		t.Errorf("monitoring-only class in allocation table:\n%s", tbl)
+	}
+
+	// Mock fs does not create mon_groups automatically
+	cls, _ = GetClass("mon-1")
+	if err := os.Mkdir(cls.(*ctrlGroup).path("mon_groups"), 0755); err != nil {
+		t.Fatalf("failed to create mon_groups: %v", err)
+	}
+	if _, err := cls.CreateMonGroup("mg-1", nil); err != nil {
+		t.Errorf("CreateMonGroup() failed: %v", err)
+	}
+
+	conf = `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      mon-1:
+        monitoringOnly: true
+        mbAllocation: [50%]
+`
+	if err := SetConfigFromData([]byte(conf), false); err == nil {
+		t.Errorf("configuration with allocation in monitoring-only class succeeded unexpectedly")
+	}
+}
+
+func TestAllocationMetrics(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	// Set group remove function so that mock groups can be removed
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 50%
+    mbAllocation: [50%]
+    classes:
+      class-1:
+        l3Allocation: 50%
+        mbAllocation: [50%]
+      mon-1:
+        monitoringOnly: true
+`
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	(&collector{}).collectAllocationMetrics(ch)
+	close(ch)
+
+	ways := map[string]float64{}
+	mb := map[string]float64{}
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatalf("failed to read metric: %v", err)
+		}
+		labels := []string{}
+		for _, l := range pb.GetLabel() {
+			labels = append(labels, l.GetName()+"="+l.GetValue())
+		}
+		key := strings.Join(labels, ",")
+		switch m.Desc() {
+		case l3AllocatedWaysDesc:
+			ways[key] = pb.GetGauge().GetValue()
+		case mbAllocationPercentDesc:
+			mb[key] = pb.GetGauge().GetValue()
+		}
+	}
+
+	testutils.VerifyDeepEqual(t, "L3 ways", 5.0, ways["cache_id=0,rdt_class=class-1,type=unified"])
+	// 25% rounded to the bandwidth granularity of 10%
+	testutils.VerifyDeepEqual(t, "MB percent", 30.0, mb["cache_id=1,rdt_class=class-1"])
+	// Root class not in the configuration is not managed by it
+	testutils.VerifyDeepEqual(t, "number of L3 metrics", 4, len(ways))
+	testutils.VerifyDeepEqual(t, "number of MB metrics", 4, len(mb))
+}
+
+func TestClosidExhausted(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	groupRemoveFunc = os.RemoveAll
+	defer func() { groupRemoveFunc = os.Remove }()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	// 8 CLOSIDs available, 2 consumed by other resctrl groups and one by
+	// the root class
+	conf := `
+partitions:
+  part-1:
+    classes:
+      class-0:
+      class-1:
+      class-2:
+      class-3:
+      class-4:
+`
+	if err := SetConfigFromData([]byte(conf), true); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	err = SetConfigFromData([]byte(conf+"      class-5:\n"), true)
+	if !errors.Is(err, ErrClosidExhausted) {
+		t.Fatalf("expected ErrClosidExhausted from SetConfig(), got %v", err)
+	}
+	testutils.VerifyStrings(t, "CLOSIDs exhausted: configuration defines 7 classes but only 6 CLOSIDs are available (2 of 8 CLOSIDs used by other resctrl groups)", err.Error())
+
+	// Filesystem must be left untouched
+	if _, err := os.Stat(filepath.Join(mockFs.baseDir, "resctrl", mockGroupPrefix+"class-5")); !os.IsNotExist(err) {
+		t.Errorf("resctrl group of class-5 unexpectedly created")
+	}
+	if _, ok := GetClass("class-5"); ok {
+		t.Errorf("class-5 unexpectedly exists")
+	}
+	if _, ok := GetClass("class-4"); !ok {
+		t.Errorf("class-4 unexpectedly removed")
+	}
+}
+
+func TestDryRunConfig(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	info = nil
+	if _, err := DryRunConfig(&Config{}); err == nil {
+		t.Errorf("DryRunConfig() succeeded unexpectedly without initialization")
+	}
+
+	if err := InitializeReadOnly(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := &Config{}
+	if err := yaml.Unmarshal([]byte(`
+partitions:
+  part-1:
+    l3Allocation: 50%
+    classes:
+      class-1:
+        l3Allocation: 50%
+      class-2:
+        l3Allocation: "0x3"
+`), conf); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	schemata, err := DryRunConfig(conf)
+	if err != nil {
+		t.Fatalf("DryRunConfig() failed: %v", err)
+	}
+	// Root class not in the configuration is left untouched
+	expected := map[string]string{
+		"class-1": "L3:0=1f;1=1f;2=1f;3=1f\n",
+		"class-2": "L3:0=3;1=3;2=3;3=3\n",
+	}
+	testutils.VerifyDeepEqual(t, "schemata", expected, schemata)
+
+	// Root class of an empty configuration is reset to full allocation
+	if schemata, err := DryRunConfig(&Config{}); err != nil {
+		t.Errorf("DryRunConfig() failed: %v", err)
+	} else {
+		testutils.VerifyDeepEqual(t, "schemata", map[string]string{
+			RootClassName: "L3:0=fffff;1=fffff;2=fffff;3=fffff\n",
+		}, schemata)
+	}
+
+	// Nothing written
+	if _, err := os.Stat(filepath.Join(mockFs.baseDir, "resctrl", mockGroupPrefix+"class-1")); !os.IsNotExist(err) {
+		t.Errorf("resctrl group of class-1 unexpectedly created")
+	}
+
+	// Invalid configuration
+	conf = &Config{}
+	if err := yaml.Unmarshal([]byte(`
+partitions:
+  part-1:
+    l3Allocation: 50%
+    classes:
+      class-1:
+        l3Allocation: 150%
+`), conf); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if _, err := DryRunConfig(conf); err == nil {
+		t.Errorf("DryRunConfig() of invalid config succeeded unexpectedly")
+	}
+}
+
+func TestDumpInfo(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+
+	if err := InitializeReadOnly(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := &Config{}
+	if err := yaml.Unmarshal([]byte(`
+partitions:
+  part-1:
+    l3Allocation: 50%
+    mbAllocation: [50%]
+    classes:
+      class-1:
+        l3Allocation: 50%
+        mbAllocation: [50%]
+`), conf); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	expected, err := DryRunConfig(conf)
+	if err != nil {
+		t.Fatalf("DryRunConfig() failed: %v", err)
+	}
+
+	data, err := DumpInfo()
+	if err != nil {
+		t.Fatalf("DumpInfo() failed: %v", err)
+	}
+
+	// Loaded info must work without the resctrl filesystem
+	mockFs.delete()
+	info = nil
+
+	if err := LoadInfo(data); err != nil {
+		t.Fatalf("LoadInfo() failed: %v", err)
+	}
+	if schemata, err := DryRunConfig(conf); err != nil {
+		t.Errorf("DryRunConfig() with loaded info failed: %v", err)
+	} else {
+		testutils.VerifyDeepEqual(t, "schemata", expected, schemata)
+	}
+	if data2, err := DumpInfo(); err != nil {
+		t.Errorf("DumpInfo() of loaded info failed: %v", err)
+	} else {
+		testutils.VerifyStrings(t, string(data), string(data2))
+	}
+	if err := SetConfig(conf, false); err == nil {
+		t.Errorf("SetConfig() with loaded info succeeded unexpectedly")
+	}
+
+	// Invalid data
+	if err := LoadInfo([]byte("{")); err == nil {
+		t.Errorf("LoadInfo() of invalid JSON succeeded unexpectedly")
+	}
+	if err := LoadInfo([]byte(`{"cat": {"L3": {"unified": {"cbmMask": "xyz"}}}}`)); err == nil {
+		t.Errorf("LoadInfo() of invalid cbmMask succeeded unexpectedly")
+	}
+	if err := LoadInfo([]byte(`{"cat": {"L4": {}}}`)); err == nil {
+		t.Errorf("LoadInfo() of invalid cache level succeeded unexpectedly")
+	}
+}
+
+func TestClassMetrics(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation: 100%
+    classes:
+      class-1:
+        monitorGroups:
+          mg-1:
+      class-2:
+`
+	if err := os.MkdirAll(filepath.Join(mockFs.baseDir, "resctrl", "goresctrl.class-1", "mon_groups"), 0755); err != nil {
+		t.Fatalf("failed to create mon_groups: %v", err)
+	}
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	if err := os.WriteFile(rdt.classes["class-1"].path("tasks"), []byte("10\n11\n12\n"), 0644); err != nil {
+		t.Fatalf("failed to write tasks: %v", err)
+	}
+	if err := os.WriteFile(rdt.classes["class-2"].path("tasks"), nil, 0644); err != nil {
+		t.Fatalf("failed to write tasks: %v", err)
+	}
+	if err := os.WriteFile(rdt.classes[RootClassName].path("tasks"), []byte("1\n"), 0644); err != nil {
+		t.Fatalf("failed to write tasks: %v", err)
+	}
+
+	collect := func() (map[string]float64, map[string]float64, int) {
+		ch := make(chan prometheus.Metric, 10)
+		(&collector{}).collectClassMetrics(ch)
+		close(ch)
+
+		tasks := map[string]float64{}
+		monGroups := map[string]float64{}
+		errs := 0
+		for m := range ch {
+			pb := &dto.Metric{}
+			if err := m.Write(pb); err != nil {
+				errs++
+				continue
+			}
+			class := pb.GetLabel()[0].GetValue()
+			switch m.Desc() {
+			case classTasksDesc:
+				tasks[class] = pb.GetGauge().GetValue()
+			case classMonGroupsDesc:
+				monGroups[class] = pb.GetGauge().GetValue()
+			}
+		}
+		return tasks, monGroups, errs
+	}
+
+	tasks, monGroups, errs := collect()
+	testutils.VerifyDeepEqual(t, "class tasks", map[string]float64{"class-1": 3, "class-2": 0, RootClassName: 1}, tasks)
+	testutils.VerifyDeepEqual(t, "class monitoring groups", map[string]float64{"class-1": 1, "class-2": 0, RootClassName: 0}, monGroups)
+	testutils.VerifyDeepEqual(t, "collection errors", 0, errs)
+
+	// Unreadable tasks file is a collection error
+	if err := os.Remove(rdt.classes["class-2"].path("tasks")); err != nil {
+		t.Fatalf("failed to remove tasks: %v", err)
+	}
+	tasks, _, errs = collect()
+	testutils.VerifyDeepEqual(t, "class tasks", map[string]float64{"class-1": 3, RootClassName: 1}, tasks)
+	testutils.VerifyDeepEqual(t, "collection errors", 1, errs)
+}
+
+func TestRmidMetrics(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.full", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	// 5 ctrl groups and 7 monitoring groups in the mock fs
+	used, err := getRmidsInUse()
+	if err != nil {
+		t.Fatalf("getRmidsInUse() failed: %v", err)
+	}
+	testutils.VerifyDeepEqual(t, "RMIDs in use", uint64(12), used)
+
+	cls, _ := GetClass(RootClassName)
+	if _, err := cls.CreateMonGroup("new-group", nil); err != nil {
+		t.Fatalf("CreateMonGroup() failed: %v", err)
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	(&collector{}).collectRmidMetrics(ch)
+	close(ch)
+
+	values := map[*prometheus.Desc]float64{}
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatalf("failed to read metric: %v", err)
+		}
+		values[m.Desc()] = pb.GetGauge().GetValue()
+	}
+	testutils.VerifyDeepEqual(t, "RMIDs used", 13.0, values[rmidsUsedDesc])
+	testutils.VerifyDeepEqual(t, "RMIDs total", 192.0, values[rmidsTotalDesc])
+}
+
+func TestFormatAllocationTable(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+	conf := `
+partitions:
+  part-1:
+    l3Allocation:
+      all: 60%
+      1: "0xff"
+    classes:
+      class-1:
+        l3Allocation: 50%
+  part-2:
+    l3Allocation:
+      all: 40%
+      1: "0xf00"
+    classes:
+      system/default:
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	table := FormatAllocationTable()
+	t.Logf("allocation table:\n%s", table)
+
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	// Header plus one row per partition and class, per cache id
+	if len(lines) != 1+4*4 {
+		t.Fatalf("unexpected number of lines in allocation table: %d", len(lines))
+	}
+	for _, expected := range [][]string{
+		{"PARTITION", "CLASS", "RESOURCE", "CACHE", "ID", "TYPE", "REQUESTED", "GRANTED"},
+		{"part-1", "-", "L3", "0", "unified", "60%", "0xfff"},
+		{"part-1", "-", "L3", "1", "unified", "0xff", "0xff"},
+		{"part-1", "class-1", "L3", "0", "unified", "50%", "0x3f"},
+		{"part-1", "class-1", "L3", "1", "unified", "50%", "0xf"},
+		{"part-2", "-", "L3", "2", "unified", "40%", "0xff000"},
+		{"part-2", "system/default", "L3", "1", "unified", "-", "0xf00"},
+	} {
+		found := false
+		for _, line := range lines {
+			if cmp.Equal(strings.Fields(line), expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("row %v not found in allocation table", expected)
+		}
+	}
+}
+
+func TestMonBandwidth(t *testing.T) {
+	prev := MonData{
+		L3: MonL3Data{
+			0: MonLeafData{"llc_occupancy": 1000, "mbm_local_bytes": 1000, "mbm_total_bytes": 2000},
+			1: MonLeafData{"mbm_local_bytes": math.MaxUint64 - 99, "mbm_total_bytes": 0},
+		},
+	}
+	now := MonData{
+		L3: MonL3Data{
+			0: MonLeafData{"llc_occupancy": 500, "mbm_local_bytes": 3000, "mbm_total_bytes": 6000},
+			1: MonLeafData{"mbm_local_bytes": 100, "mbm_total_bytes": 1000},
+			2: MonLeafData{"mbm_local_bytes": 100, "mbm_total_bytes": 1000},
+		},
+	}
+
+	expected := map[uint64]map[string]float64{
+		0: {"mbm_local_bytes": 1000, "mbm_total_bytes": 2000},
+		1: {"mbm_local_bytes": 100, "mbm_total_bytes": 500},
+	}
+	testutils.VerifyDeepEqual(t, "bandwidth", expected, MonBandwidth(prev, now, 2*time.Second))
+
+	testutils.VerifyDeepEqual(t, "bandwidth with zero interval", map[uint64]map[string]float64{}, MonBandwidth(prev, now, 0))
+}
+
+func TestMBRounding(t *testing.T) {
+	origInfo := info
+	defer func() { info = origInfo }()
+
+	tcs := []struct {
+		gran     uint64
+		min      uint64
+		base     uint64
+		pct      uint64
+		expected uint64
+	}{
+		{gran: 10, min: 10, base: 100, pct: 45, expected: 50},
+		{gran: 10, min: 10, base: 100, pct: 44, expected: 40},
+		{gran: 10, min: 10, base: 100, pct: 100, expected: 100},
+		{gran: 10, min: 10, base: 100, pct: 3, expected: 10},
+		{gran: 10, min: 10, base: 80, pct: 50, expected: 40},
+		{gran: 10, min: 10, base: 50, pct: 33, expected: 20},
+		{gran: 20, min: 20, base: 100, pct: 45, expected: 40},
+		{gran: 20, min: 20, base: 100, pct: 50, expected: 60},
+		{gran: 20, min: 20, base: 100, pct: 95, expected: 100},
+		{gran: 20, min: 20, base: 100, pct: 5, expected: 20},
+		{gran: 20, min: 20, base: 70, pct: 50, expected: 40},
+		{gran: 1, min: 10, base: 100, pct: 45, expected: 45},
+	}
+	for _, tc := range tcs {
+		info = &resctrlInfo{mb: mbInfo{cacheIds: []uint64{0}, bandwidthGran: tc.gran, minBandwidth: tc.min}}
+		s := mbSchema{0: tc.pct}
+		if v := s.effectiveValue(0, map[uint64]uint64{0: tc.base}); v != tc.expected {
+			t.Errorf("%d%% of %d%% with granularity %d: expected %d, got %d", tc.pct, tc.base, tc.gran, tc.expected, v)
+		}
+	}
+
+	// Schemata contains the rounded values
+	info = &resctrlInfo{mb: mbInfo{cacheIds: []uint64{0, 1}, bandwidthGran: 20, minBandwidth: 20}}
+	testutils.VerifyStrings(t, "MB:0=40;1=100\n", mbSchema{0: 45, 1: 90}.toStr(map[uint64]uint64{}))
+}
+
+func TestUnusedCatBits(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	data := `
+options:
+  l3:
+    reportUnused: true
+partitions:
+  part-1:
+    l3Allocation:
+      all: "0-7"
+      2: "0-3"
+    classes:
+      class-1:
+        l3Allocation: 50%
+  part-2:
+    l3Allocation:
+      all: "8-19"
+      2: "4-19"
+    classes:
+      class-2:
+      class-3:
+        l3Allocation: 50%
+`
+	cfg, err := parseConfigData([]byte(data))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	conf, err := cfg.resolve()
+	if err != nil {
+		t.Fatalf("failed to resolve config: %v", err)
+	}
+
+	unused, err := conf.unusedCatBits(L3)
+	if err != nil {
+		t.Fatalf("unusedCatBits() failed: %v", err)
+	}
+	expected := []string{
+		`L3 bits 0xf0 of partition "part-1" on cache id 0 not used by any class (partition mask 0xff, used by classes 0xf)`,
+		`L3 bits 0xf0 of partition "part-1" on cache id 1 not used by any class (partition mask 0xff, used by classes 0xf)`,
+		`L3 bits 0xc of partition "part-1" on cache id 2 not used by any class (partition mask 0xf, used by classes 0x3)`,
+		`L3 bits 0xf0 of partition "part-1" on cache id 3 not used by any class (partition mask 0xff, used by classes 0xf)`,
+	}
+	testutils.VerifyStringSlices(t, expected, unused)
+}
+
+func TestFreeRanges(t *testing.T) {
+	mockFs, err := newMockResctrlFs(t, "resctrl.nomb", "")
+	if err != nil {
+		t.Fatalf("failed to set up mock resctrl fs: %v", err)
+	}
+	defer mockFs.delete()
+
+	if err := Initialize(mockGroupPrefix); err != nil {
+		t.Fatalf("rdt initialization failed: %v", err)
+	}
+
+	// No partitions, all of the cache is free
+	ranges, err := FreeRanges(L3, 0)
+	testutils.VerifyNoError(t, err)
+	testutils.VerifyDeepEqual(t, "free ranges", []uint64{0xfffff}, ranges)
+
+	conf := `
+partitions:
+  part-1:
+    l3Allocation:
+      all: "0-3"
+      2: "12-15"
+    classes:
+      class-1:
+        l3Allocation: 50%
+  part-2:
+    l3Allocation: "8-11"
+    classes:
+      system/default:
+`
+	if err := SetConfigFromData([]byte(conf), false); err != nil {
+		t.Fatalf("rdt configuration failed: %v", err)
+	}
+
+	ranges, err = FreeRanges(L3, 0)
+	testutils.VerifyNoError(t, err)
+	testutils.VerifyDeepEqual(t, "free ranges", []uint64{0xf0, 0xff000}, ranges)
+
+	ranges, err = FreeRanges(L3, 2)
+	testutils.VerifyNoError(t, err)
+	testutils.VerifyDeepEqual(t, "free ranges", []uint64{0xff, 0xf0000}, ranges)
+
+	_, err = FreeRanges(L3, 7)
+	testutils.VerifyError(t, err, 1, []string{"invalid L3 cache id 7"})
+
+	_, err = FreeRanges(L2, 0)
+	testutils.VerifyError(t, err, 1, []string{"L2 cache allocation not supported"})
+}
+
+func TestMigrateConfig(t *testing.T) {
+	legacy := `
+options:
+  l3:
+    optional: true
+partitions:
+  part-1:
+    l3Schema: "0-9"
+    mbSchema: ["50%"]
+    classes:
+      class-1:
+        l3Schema: 50%
+        mbSchema: ["80%"]
+      class-2:
+        l3Allocation: 100%
+`
+	canonical := `
+version: 1
+options:
+  l3:
+    optional: true
+partitions:
+  part-1:
+    l3Allocation: "0-9"
+    mbAllocation: ["50%"]
+    classes:
+      class-1:
+        l3Allocation: 50%
+        mbAllocation: ["80%"]
+      class-2:
+        l3Allocation: 100%
+`
+	if _, err := parseConfigData([]byte(legacy)); err == nil {
+		t.Fatalf("legacy config unexpectedly accepted by strict parsing")
+	}
+
+	migrated, err := MigrateConfig([]byte(legacy))
+	if err != nil {
+		t.Fatalf("config migration failed: %v", err)
+	}
+	got, err := parseConfigData(migrated)
+	if err != nil {
+		t.Fatalf("failed to parse migrated config: %v", err)
+	}
+	expected, err := parseConfigData([]byte(canonical))
+	if err != nil {
+		t.Fatalf("failed to parse canonical config: %v", err)
+	}
+	testutils.VerifyDeepEqual(t, "migrated config", expected, got)
+
+	// Migrating an up-to-date config is a no-op
+	again, err := MigrateConfig(migrated)
+	if err != nil {
+		t.Fatalf("config migration failed: %v", err)
+	}
+	testutils.VerifyStrings(t, string(migrated), string(again))
+
+	// Errors
+	tcs := []struct {
+		name   string
+		config string
+		errStr string
+	}{
+		{
+			name: "both legacy and current field",
 			config: `
 partitions:
   part-1:
-    mbAllocation: ["50%", "1000MBps"]
-    classes:
-      class-1:
-        mbAllocation: ["100%", "1500MBps"]
-  part-2:
-    mbAllocation:
-      all: ["1000MBps"]
-      # Unsupported values should just be ignored
-      0,1: [50, "1GBps", "500MBps"]
-    classes:
-      class-2:
-        mbAllocation: ["750MBps"]
+    l3Schema: 50%
+    l3Allocation: 50%
 `,
-			schemata: map[string]Schemata{
-				"class-1": Schemata{
-					mb: "0=1000;1=1000;2=1000;3=1000",
-				},
-				"class-2": Schemata{
-					mb: "0=500;1=500;2=750;3=750",
-				},
-				"system/default": Schemata{
-					mb: "0=4294967295;1=4294967295;2=4294967295;3=4294967295",
-				},
-			},
+			errStr: `both "l3Schema" and "l3Allocation" specified in "partitions.part-1"`,
 		},
-		// Testcase
-		TC{
-			name:        "MB nan MBps value (fail)",
-			fs:          "resctrl.nol3.mbps",
-			fsMountOpts: "mba_MBps",
-			configErrRe: `failed to resolve MB allocation for partition "part-1":.* invalid syntax`,
-			config: `
-partitions:
-  part-1:
-    mbAllocation: ["0xffMBps"]
-`,
+		{
+			name:   "unsupported version",
+			config: "version: 2\n",
+			errStr: `unsupported configuration version 2`,
 		},
-		// Testcase
-		TC{
-			name:        "MB missing MBps value (fail)",
-			fs:          "resctrl.nol3.mbps",
-			fsMountOpts: "mba_MBps",
-			configErrRe: `missing 'MBps' value from mbSchema`,
+		{
+			name: "invalid result",
 			config: `
 partitions:
   part-1:
-    mbAllocation: ["100%"]
+    foo: bar
 `,
+			errStr: `migrated configuration is invalid`,
 		},
 	}
-
-	verifySchemata := func(tc *TC) {
-		for n, s := range tc.schemata {
-			expected := ""
-			if s.l2 != "" {
-				expected += "L2:" + s.l2 + "\n"
-			}
-			if s.l2code != "" {
-				expected += "L2CODE:" + s.l2code + "\n"
-			}
-			if s.l2data != "" {
-				expected += "L2DATA:" + s.l2data + "\n"
-			}
-			if s.l3 != "" {
-				expected += "L3:" + s.l3 + "\n"
-			}
-			if s.l3code != "" {
-				expected += "L3CODE:" + s.l3code + "\n"
-			}
-			if s.l3data != "" {
-				expected += "L3DATA:" + s.l3data + "\n"
-			}
-			if s.mb != "" {
-				expected += "MB:" + s.mb + "\n"
-			}
-			if c, ok := rdt.classes[n]; !ok {
-				t.Fatalf("verifySchemata: class %q does not exists in %v", n, rdt.classes)
-			} else {
-				verifyTextFile(t, c.path("schemata"), expected)
-			}
-		}
-
-		if len(tc.schemata) != len(rdt.classes) {
-			var a, b []string
-			for n := range tc.schemata {
-				a = append(a, n)
-			}
-			for n := range rdt.classes {
-				b = append(b, n)
-			}
-			t.Fatalf("unexpected set of classes: expected %v, got %v", a, b)
-		}
-	}
-
-	// Set group remove function so that mock groups can be removed
-	groupRemoveFunc = os.RemoveAll
-
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
-			mockFs, err := newMockResctrlFs(t, tc.fs, tc.fsMountOpts)
-			if err != nil {
-				t.Fatalf("failed to set up mock resctrl fs: %v", err)
-			}
-			defer mockFs.delete()
-
-			if err := Initialize(mockGroupPrefix); err != nil {
-				t.Fatalf("resctrl initialization failed: %v", err)
-			}
-
-			err = SetConfigFromData([]byte(tc.config), false)
-			if tc.configErrRe != "" {
-				if err == nil {
-					t.Fatalf("resctrl configuration succeeded unexpectedly")
-				} else {
-					m, e := regexp.MatchString(tc.configErrRe, err.Error())
-					if e != nil {
-						t.Fatalf("error in regexp matching: %v", e)
-					}
-					if !m {
-						t.Fatalf("unexpected error message:\n  %q\n  does NOT match regexp\n  %q", err.Error(), tc.configErrRe)
-					}
-				}
-			} else {
-				if err != nil {
-					t.Fatalf("resctrl configuration failed: %v", err)
-				}
-				verifySchemata(&tc)
-			}
-
-			// Check that SetConfig does not alter the config struct
-			conf := parseTestConfig(t, tc.config)
-			confDataOld, err := yaml.Marshal(conf)
-			if err != nil {
-				t.Fatalf("marshalling config failed: %v", err)
-			}
-			_ = SetConfig(conf, false)
-			if confDataNew, err := yaml.Marshal(conf); err != nil {
-				t.Fatalf("marshalling config failed: %v", err)
-			} else if !cmp.Equal(confDataNew, confDataOld) {
-				t.Fatalf("SetConfig altered config data:\n%s\nVS.\n%s", confDataOld, confDataNew)
-			}
+			_, err := MigrateConfig([]byte(tc.config))
+			testutils.VerifyError(t, err, 1, []string{tc.errStr})
 		})
 	}
+
+	// Strict parsing rejects newer versions
+	if _, err := parseConfigData([]byte("version: 2\n")); err == nil {
+		t.Errorf("config with unsupported version unexpectedly accepted")
+	}
 }
 
 func TestBitMap(t *testing.T) {
@@ -1559,6 +4814,11 @@ func TestBitMap(t *testing.T) {
 		}
 	}
 
+	// Test ranges()
+	testutils.VerifyDeepEqual(t, "ranges", []bitmask{}, bitmask(0).ranges())
+	testutils.VerifyDeepEqual(t, "ranges", []bitmask{0x2, 0x18, 0x100, 0x1c00}, bitmask(0x1d1a).ranges())
+	testutils.VerifyDeepEqual(t, "ranges", []bitmask{0xffffffffffffffff}, bitmask(0xffffffffffffffff).ranges())
+
 	// Negative tests for ListStrToBitmask
 	negTestSet := []string{
 		",",
@@ -1676,6 +4936,27 @@ func TestCacheAllocation(t *testing.T) {
 		t.Errorf("unexpected success when overlaying catAbsoluteAllocation with too small basemask")
 	}
 
+	// Test exclude allocation
+	if res, err := catExcludeAllocation(0x3).Overlay(0xff00, 4); err != nil {
+		t.Errorf("unexpected error when overlaying catExcludeAllocation: %v", err)
+	} else if res != 0xfc00 {
+		t.Errorf("expected 0xfc00 but got %#x when overlaying catExcludeAllocation", res)
+	}
+	if res, err := catExcludeAllocation(0xf0).Overlay(0xff00, 4); err != nil {
+		t.Errorf("unexpected error when overlaying catExcludeAllocation: %v", err)
+	} else if res != 0xf00 {
+		t.Errorf("expected 0xf00 but got %#x when overlaying catExcludeAllocation", res)
+	}
+	if _, err := catExcludeAllocation(0x18).Overlay(0xff00, 1); err == nil {
+		t.Errorf("unexpected success when overlaying catExcludeAllocation resulting in non-contiguous bitmask")
+	}
+	if _, err := catExcludeAllocation(0xff).Overlay(0xff00, 1); err == nil {
+		t.Errorf("unexpected success when overlaying catExcludeAllocation excluding all bits")
+	}
+	if _, err := catExcludeAllocation(0x3f).Overlay(0xff00, 4); err == nil {
+		t.Errorf("unexpected success when overlaying catExcludeAllocation leaving too few bits")
+	}
+
 	// Test percentage allocation
 	if res, err := (catPctRangeAllocation{lowPct: 0, highPct: 100}).Overlay(0xff00, 4); err != nil {
 		t.Errorf("unexpected error when overlaying catPctAllocation: %v", err)
@@ -1708,6 +4989,36 @@ func TestCacheAllocation(t *testing.T) {
 	if _, err := (catPctRangeAllocation{lowPct: 0, highPct: 100}).Overlay(0, 4); err == nil {
 		t.Errorf("unexpected success when overlaying catPctAllocation of invalid percentage range")
 	}
+
+	// Test cache ways allocation
+	if res, err := catWaysAllocation(3).Overlay(0xff00, 2); err != nil {
+		t.Errorf("unexpected error when overlaying catWaysAllocation: %v", err)
+	} else if res != 0x700 {
+		t.Errorf("expected 0x700 but got %#x when overlaying catWaysAllocation", res)
+	}
+	if _, err := catWaysAllocation(9).Overlay(0xff00, 2); err == nil {
+		t.Errorf("unexpected success when overlaying too wide catWaysAllocation")
+	}
+	if _, err := catWaysAllocation(1).Overlay(0xff00, 2); err == nil {
+		t.Errorf("unexpected success when overlaying too narrow catWaysAllocation")
+	}
+
+	// Test cache fraction allocation
+	if res, err := catFractionAllocation(2).Overlay(0x7ff, 2); err != nil {
+		t.Errorf("unexpected error when overlaying catFractionAllocation: %v", err)
+	} else if res != 0x1f {
+		t.Errorf("expected 0x1f but got %#x when overlaying catFractionAllocation", res)
+	}
+	if res, err := catFractionAllocation(4).Overlay(0xf0, 2); err != nil {
+		t.Errorf("unexpected error when overlaying catFractionAllocation: %v", err)
+	} else if res != 0x30 {
+		t.Errorf("expected 0x30 but got %#x when overlaying catFractionAllocation", res)
+	}
+	if res, err := catFractionAllocation(4).Overlay(0x1c, 1); err != nil {
+		t.Errorf("unexpected error when overlaying catFractionAllocation: %v", err)
+	} else if res != 0x4 {
+		t.Errorf("expected 0x4 but got %#x when overlaying catFractionAllocation", res)
+	}
 }
 
 func TestCacheProportion(t *testing.T) {
@@ -1774,6 +5085,46 @@ func TestCacheProportion(t *testing.T) {
 	if _, err := CacheProportion("3-x").parse(2); err == nil {
 		t.Errorf("unexpected success when parsing bitmask cache allocation")
 	}
+
+	// Test cache ways and fractions
+	if a, err := CacheProportion("ways:4").parse(2); err != nil {
+		t.Errorf("unexpected error when parsing cache allocation: %v", err)
+	} else if a != catWaysAllocation(4) {
+		t.Errorf("expected ways:4 but got %v", a)
+	}
+	if a, err := CacheProportion("half").parse(2); err != nil {
+		t.Errorf("unexpected error when parsing cache allocation: %v", err)
+	} else if a != catFractionAllocation(2) {
+		t.Errorf("expected half but got %v", a)
+	}
+	if a, err := CacheProportion("quarter").parse(2); err != nil {
+		t.Errorf("unexpected error when parsing cache allocation: %v", err)
+	} else if a != catFractionAllocation(4) {
+		t.Errorf("expected quarter but got %v", a)
+	}
+	for _, s := range []string{"ways:0", "ways:1", "ways:x", "ways:", "third"} {
+		if _, err := CacheProportion(s).parse(2); err == nil {
+			t.Errorf("unexpected success when parsing cache allocation %q", s)
+		}
+	}
+
+	// Test excluded bits
+	if a, err := CacheProportion("^0-1").parse(2); err != nil {
+		t.Errorf("unexpected error when parsing cache allocation: %v", err)
+	} else if a != catExcludeAllocation(0x3) {
+		t.Errorf("expected ^0x3 but got %v", a)
+	}
+	if a, err := CacheProportion("^0xf0").parse(2); err != nil {
+		t.Errorf("unexpected error when parsing cache allocation: %v", err)
+	} else if a != catExcludeAllocation(0xf0) {
+		t.Errorf("expected ^0xf0 but got %v", a)
+	}
+	if _, err := CacheProportion("^").parse(2); err == nil {
+		t.Errorf("unexpected success when parsing exclude cache allocation")
+	}
+	if _, err := CacheProportion("^0xg").parse(2); err == nil {
+		t.Errorf("unexpected success when parsing exclude cache allocation")
+	}
 }
 
 func TestIsQualifiedClassName(t *testing.T) {
@@ -1793,3 +5144,284 @@ func TestIsQualifiedClassName(t *testing.T) {
 		}
 	}
 }
+
+func TestGroupDirName(t *testing.T) {
+	tcs := map[string]string{
+		"foo":        "foo",
+		"50%":        "50%",
+		"part/class": "part%2Fclass",
+		"part/50%":   "part%2F50%25",
+		"p%2F/c":     "p%252F%2Fc",
+	}
+
+	for name, expected := range tcs {
+		dir := classNameToGroupDirName(name)
+		if dir != expected {
+			t.Errorf("classNameToGroupDirName(%q) returned %q (expected %q)", name, dir, expected)
+		}
+		if n := groupDirNameToClassName(dir); n != name {
+			t.Errorf("groupDirNameToClassName(%q) returned %q (expected %q)", dir, n, name)
+		}
+	}
+
+	// Unescaped directory names of non-namespaced classes are used as such
+	for _, dir := range []string{"50%25", "foo%", "a%2"} {
+		if n := groupDirNameToClassName(dir); n != dir {
+			t.Errorf("groupDirNameToClassName(%q) returned %q (expected %q)", dir, n, dir)
+		}
+	}
+}
+
+func TestCacheIDForCPU(t *testing.T) {
+	sysfsRoot, err := os.MkdirTemp("", "goresctrl.test.sysfs.")
+	if err != nil {
+		t.Fatalf("failed to create mock sysfs: %v", err)
+	}
+	defer os.RemoveAll(sysfsRoot)
+
+	goresctrlpath.SetPrefix(sysfsRoot)
+	defer goresctrlpath.SetPrefix("/")
+
+	// Mock cache topology of cpu 40: L1i, L1d, L2 and L3
+	caches := []struct{ level, typ, id string }{
+		{"1", "Instruction", "20"},
+		{"1", "Data", "20"},
+		{"2", "Unified", "20"},
+		{"3", "Unified", "1"},
+	}
+	for i, c := range caches {
+		dir := filepath.Join(sysfsRoot, "sys/devices/system/cpu/cpu40/cache", "index"+strconv.Itoa(i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create mock sysfs: %v", err)
+		}
+		for name, data := range map[string]string{"level": c.level, "type": c.typ, "id": c.id} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(data+"\n"), 0644); err != nil {
+				t.Fatalf("failed to create mock sysfs: %v", err)
+			}
+		}
+	}
+
+	if id, err := CacheIDForCPU(L3, 40); err != nil {
+		t.Errorf("CacheIDForCPU() failed: %v", err)
+	} else if id != 1 {
+		t.Errorf("CacheIDForCPU(L3, 40) returned %d, expected 1", id)
+	}
+	if id, err := CacheIDForCPU(L2, 40); err != nil {
+		t.Errorf("CacheIDForCPU() failed: %v", err)
+	} else if id != 20 {
+		t.Errorf("CacheIDForCPU(L2, 40) returned %d, expected 20", id)
+	}
+
+	// Negative tests
+	if _, err := CacheIDForCPU(L3, 41); err == nil {
+		t.Errorf("CacheIDForCPU() for non-existent cpu succeeded unexpectedly")
+	}
+	if _, err := CacheIDForCPU("L4", 40); err == nil {
+		t.Errorf("CacheIDForCPU() for non-existent cache level succeeded unexpectedly")
+	}
+}
+
+// mockNodeSysfs creates a mock sysfs with NUMA nodes. Each node has one cpu
+// and is described by its package id and L3 cache id.
+func mockNodeSysfs(t *testing.T, nodes [][2]string) string {
+	sysfsRoot, err := os.MkdirTemp("", "goresctrl.test.sysfs.")
+	if err != nil {
+		t.Fatalf("failed to create mock sysfs: %v", err)
+	}
+
+	files := map[string]string{}
+	for i, n := range nodes {
+		cpu := "cpu" + strconv.Itoa(i)
+		files[filepath.Join("node", "node"+strconv.Itoa(i), cpu, "online")] = "1"
+		files[filepath.Join("node", "node"+strconv.Itoa(i), "cpulist")] = strconv.Itoa(i)
+		files[filepath.Join("cpu", cpu, "topology", "physical_package_id")] = n[0]
+		files[filepath.Join("cpu", cpu, "cache", "index0", "level")] = "3"
+		files[filepath.Join("cpu", cpu, "cache", "index0", "type")] = "Unified"
+		files[filepath.Join("cpu", cpu, "cache", "index0", "id")] = n[1]
+	}
+	for name, data := range files {
+		path := filepath.Join(sysfsRoot, "sys/devices/system", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create mock sysfs: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data+"\n"), 0644); err != nil {
+			t.Fatalf("failed to create mock sysfs: %v", err)
+		}
+	}
+	return sysfsRoot
+}
+
+func TestGetMonL3Packages(t *testing.T) {
+	tcs := []struct {
+		name     string
+		nodes    [][2]string
+		expected map[uint64]uint64
+	}{
+		{
+			name:     "SNC disabled",
+			nodes:    [][2]string{{"0", "0"}, {"1", "1"}},
+			expected: map[uint64]uint64{0: 0, 1: 1},
+		},
+		{
+			name:     "SNC disabled, sparse cache ids",
+			nodes:    [][2]string{{"0", "0"}, {"1", "8"}},
+			expected: map[uint64]uint64{0: 0, 8: 1},
+		},
+		{
+			name:     "SNC enabled",
+			nodes:    [][2]string{{"0", "0"}, {"0", "0"}, {"1", "8"}, {"1", "8"}},
+			expected: map[uint64]uint64{0: 0, 1: 0, 2: 1, 3: 1},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			sysfsRoot := mockNodeSysfs(t, tc.nodes)
+			defer os.RemoveAll(sysfsRoot)
+
+			goresctrlpath.SetPrefix(sysfsRoot)
+			defer goresctrlpath.SetPrefix("/")
+
+			packages, err := getMonL3Packages()
+			if err != nil {
+				t.Fatalf("getMonL3Packages() failed: %v", err)
+			}
+			if !cmp.Equal(packages, tc.expected) {
+				t.Errorf("getMonL3Packages() returned %v, expected %v", packages, tc.expected)
+			}
+		})
+	}
+
+	// No NUMA topology available
+	goresctrlpath.SetPrefix(t.TempDir())
+	defer goresctrlpath.SetPrefix("/")
+	if _, err := getMonL3Packages(); err == nil {
+		t.Errorf("getMonL3Packages() without NUMA topology succeeded unexpectedly")
+	}
+}
+
+// mockTasksFile mimics writes to a resctrl tasks file
+type mockTasksFile struct {
+	batched bool
+	gone    map[string]bool
+	writes  int
+	tasks   []string
+}
+
+func (m *mockTasksFile) Write(p []byte) (int, error) {
+	m.writes++
+	pids := strings.Split(strings.TrimSpace(string(p)), ",")
+	if !m.batched && len(pids) > 1 {
+		return 0, syscall.EINVAL
+	}
+	for _, pid := range pids {
+		if m.gone[pid] {
+			return 0, syscall.ESRCH
+		}
+		if _, err := strconv.ParseUint(pid, 10, 32); err != nil {
+			return 0, syscall.EINVAL
+		}
+		m.tasks = append(m.tasks, pid)
+	}
+	return len(p), nil
+}
+
+func TestWriteTasks(t *testing.T) {
+	tcs := []struct {
+		name           string
+		batched        bool
+		pids           []string
+		expectedTasks  []string
+		expectedFailed []string
+		expectedWrites int
+	}{
+		{
+			name:           "batched",
+			batched:        true,
+			pids:           []string{"1", "2", "3"},
+			expectedTasks:  []string{"1", "2", "3"},
+			expectedFailed: []string{},
+			expectedWrites: 1,
+		},
+		{
+			name:           "batched with non-existent task",
+			batched:        true,
+			pids:           []string{"1", "2", "99", "3"},
+			expectedTasks:  []string{"1", "2", "1", "2", "3"},
+			expectedFailed: []string{},
+			expectedWrites: 5,
+		},
+		{
+			name:           "batched with invalid task",
+			batched:        true,
+			pids:           []string{"1", "x", "99", "3"},
+			expectedTasks:  []string{"1", "1", "3"},
+			expectedFailed: []string{"x"},
+			expectedWrites: 5,
+		},
+		{
+			name:           "no kernel support for batches",
+			pids:           []string{"1", "2", "99", "3"},
+			expectedTasks:  []string{"1", "2", "3"},
+			expectedFailed: []string{},
+			expectedWrites: 5,
+		},
+		{
+			name:           "single task",
+			pids:           []string{"99"},
+			expectedTasks:  nil,
+			expectedFailed: []string{},
+			expectedWrites: 1,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &mockTasksFile{batched: tc.batched, gone: map[string]bool{"99": true}}
+			failed, err := writeTasks(f, tc.pids)
+			if len(tc.expectedFailed) > 0 && err == nil {
+				t.Errorf("writeTasks() did not return an error")
+			}
+			testutils.VerifyStringSlices(t, tc.expectedFailed, failed)
+			testutils.VerifyStringSlices(t, tc.expectedTasks, f.tasks)
+			if f.writes != tc.expectedWrites {
+				t.Errorf("writeTasks() did %d writes, expected %d", f.writes, tc.expectedWrites)
+			}
+		})
+	}
+
+	// Long lists of tasks are split into batches of limited size
+	pids := make([]string, 5000)
+	for i := range pids {
+		pids[i] = strconv.Itoa(100000 + i)
+	}
+	f := &mockTasksFile{batched: true}
+	if failed, err := writeTasks(f, pids); len(failed) > 0 {
+		t.Fatalf("writeTasks() failed: %v", err)
+	}
+	testutils.VerifyStringSlices(t, pids, f.tasks)
+	// 7 bytes per task (including the separator), 585 tasks per batch
+	if f.writes != 9 {
+		t.Errorf("writeTasks() did %d writes, expected 9", f.writes)
+	}
+}
+
+func BenchmarkWriteTasks(b *testing.B) {
+	pids := make([]string, 10000)
+	size := 0
+	for i := range pids {
+		pids[i] = strconv.Itoa(100000 + i)
+		size += len(pids[i]) + 1
+	}
+	maxWrites := size/(maxTasksWriteSize-len(pids[0])) + 1
+
+	for i := 0; i < b.N; i++ {
+		f := &mockTasksFile{batched: true}
+		if failed, err := writeTasks(f, pids); len(failed) > 0 {
+			b.Fatalf("writeTasks() failed: %v", err)
+		}
+		if f.writes > maxWrites {
+			b.Fatalf("writeTasks() did %d writes, expected at most %d", f.writes, maxWrites)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PackageErrors contains the errors of an operation applied on multiple CPU
+// packages, indexed by package id. Only the failed packages are included.
+type PackageErrors map[int]error
+
+// Error method of the error interface.
+func (e PackageErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, pkg := range e.packages() {
+		msgs = append(msgs, fmt.Sprintf("package %d: %v", pkg, e[pkg]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of all failed packages, making errors.Is() and
+// errors.As() work on them.
+func (e PackageErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, pkg := range e.packages() {
+		errs = append(errs, e[pkg])
+	}
+	return errs
+}
+
+func (e PackageErrors) packages() []int {
+	pkgs := make([]int, 0, len(e))
+	for pkg := range e {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Ints(pkgs)
+	return pkgs
+}
+
+// forEachPackage runs fn on all CPU packages of the system. A failure on one
+// package does not prevent running fn on the others. Returns PackageErrors
+// if fn failed on any of the packages.
+func forEachPackage(fn func(info *SstPackageInfo) error) error {
+	infomap, err := GetPackageInfo()
+	if err != nil {
+		return err
+	}
+
+	pkgs := make([]int, 0, len(infomap))
+	for pkg := range infomap {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Ints(pkgs)
+
+	errs := PackageErrors{}
+	for _, pkg := range pkgs {
+		if err := fn(infomap[pkg]); err != nil {
+			errs[pkg] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// EnableCPAll enables SST-CP on all CPU packages, see EnableCP(). Returns
+// PackageErrors listing the packages that failed.
+func EnableCPAll() error {
+	return forEachPackage(EnableCP)
+}
+
+// DisableCPAll disables SST-CP on all CPU packages, see DisableCP(). Returns
+// PackageErrors listing the packages that failed.
+func DisableCPAll() error {
+	return forEachPackage(DisableCP)
+}
+
+// ClosSetupAll stores the same Clos information on all CPU packages, see
+// ClosSetup(). Returns PackageErrors listing the packages that failed.
+func ClosSetupAll(clos int, closInfo *SstClosInfo) error {
+	return forEachPackage(func(info *SstPackageInfo) error {
+		return ClosSetup(info, clos, closInfo)
+	})
+}
+
+// ApplyProfileAll applies the named SST profile on all CPU packages, see
+// ApplyProfile(). Returns PackageErrors listing the packages that failed.
+func ApplyProfileAll(name string) error {
+	profile, err := getProfile(name)
+	if err != nil {
+		return err
+	}
+
+	return forEachPackage(func(info *SstPackageInfo) error {
+		sstlog.Infof("applying SST profile %q on package %d", name, info.pkg.id)
+		return profile.apply(info)
+	})
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/intel/goresctrl/pkg/testutils"
+)
+
+func TestPackageErrors(t *testing.T) {
+	errFoo := errors.New("foo failed")
+	errBar := errors.New("bar failed")
+
+	tcs := []struct {
+		name        string
+		errs        PackageErrors
+		expectedStr string
+		expectedIs  []error
+		expectedNot []error
+	}{
+		{
+			name:        "no errors",
+			errs:        PackageErrors{},
+			expectedStr: "",
+			expectedNot: []error{errFoo, ErrSSTLocked},
+		},
+		{
+			name:        "one package",
+			errs:        PackageErrors{1: errFoo},
+			expectedStr: "package 1: foo failed",
+			expectedIs:  []error{errFoo},
+			expectedNot: []error{errBar},
+		},
+		{
+			name: "sorted by package id",
+			errs: PackageErrors{
+				3: errBar,
+				0: errFoo,
+				1: errBar,
+			},
+			expectedStr: "package 0: foo failed; package 1: bar failed; package 3: bar failed",
+			expectedIs:  []error{errFoo, errBar},
+			expectedNot: []error{ErrSSTLocked},
+		},
+		{
+			name:        "wrapped error",
+			errs:        PackageErrors{2: errors.Join(errFoo, ErrSSTLocked)},
+			expectedStr: "package 2: foo failed\nSST configuration locked",
+			expectedIs:  []error{errFoo, ErrSSTLocked},
+			expectedNot: []error{errBar},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error = tc.errs
+			testutils.VerifyStrings(t, tc.expectedStr, err.Error())
+			for _, target := range tc.expectedIs {
+				if !errors.Is(err, target) {
+					t.Errorf("errors.Is(%q, %q) returned false", err, target)
+				}
+			}
+			for _, target := range tc.expectedNot {
+				if errors.Is(err, target) {
+					t.Errorf("errors.Is(%q, %q) returned true", err, target)
+				}
+			}
+
+			var pe PackageErrors
+			if !errors.As(err, &pe) {
+				t.Errorf("errors.As() failed to extract PackageErrors from %q", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"fmt"
+
+	"github.com/intel/goresctrl/pkg/utils"
+)
+
+// Profile is a named, commonly used combination of SST-BF and SST-CP
+// settings that can be applied with ApplyProfile().
+type Profile struct {
+	Name        string
+	Description string
+
+	apply func(info *SstPackageInfo) error
+}
+
+const (
+	// ProfileDefault disables SST-BF and SST-CP and resets the Clos setup.
+	ProfileDefault = "default"
+	// ProfileBF enables SST-BF.
+	ProfileBF = "bf"
+	// ProfileCPOrdered prioritizes the SST-BF high priority cores with
+	// SST-CP in ordered priority mode.
+	ProfileCPOrdered = "cp-ordered"
+)
+
+// Clos used by the built-in profiles.
+const (
+	profileFastClos = 0
+	profileSlowClos = 3
+)
+
+var profiles = []Profile{
+	{
+		Name:        ProfileDefault,
+		Description: "disable SST-BF and SST-CP, move all CPUs to Clos 0 with default settings",
+		apply:       applyDefaultProfile,
+	},
+	{
+		Name:        ProfileBF,
+		Description: "enable SST-BF",
+		apply:       applyBFProfile,
+	},
+	{
+		Name:        ProfileCPOrdered,
+		Description: "enable SST-CP in ordered mode, SST-BF high priority cores in the fast Clos and all other CPUs in the slow Clos",
+		apply:       applyCPOrderedProfile,
+	},
+}
+
+// GetProfiles returns the profiles that can be applied with ApplyProfile().
+func GetProfiles() []Profile {
+	return append([]Profile{}, profiles...)
+}
+
+// ApplyProfile applies the named SST profile on a CPU package.
+func ApplyProfile(pkg int, name string) error {
+	profile, err := getProfile(name)
+	if err != nil {
+		return err
+	}
+
+	infomap, err := GetPackageInfo(pkg)
+	if err != nil {
+		return err
+	}
+
+	sstlog.Infof("applying SST profile %q on package %d", name, pkg)
+
+	if err := profile.apply(infomap[pkg]); err != nil {
+		return fmt.Errorf("failed to apply SST profile %q on package %d: %w", name, pkg, err)
+	}
+
+	return nil
+}
+
+func getProfile(name string) (*Profile, error) {
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown SST profile %q", name)
+}
+
+func applyDefaultProfile(info *SstPackageInfo) error {
+	if info.BFEnabled {
+		if err := disableBF(info); err != nil {
+			return err
+		}
+	}
+
+	if !info.CPSupported {
+		return nil
+	}
+
+	if info.CPEnabled {
+		if err := DisableCP(info); err != nil {
+			return err
+		}
+	}
+
+	return resetCPConfig(info)
+}
+
+func applyBFProfile(info *SstPackageInfo) error {
+	return enableBF(info)
+}
+
+func applyCPOrderedProfile(info *SstPackageInfo) error {
+	if !info.CPSupported {
+		return fmt.Errorf("SST CP not supported")
+	}
+	if len(info.BFCores) == 0 {
+		return fmt.Errorf("no SST BF high priority cores to assign to the fast Clos")
+	}
+
+	if err := ClosSetup(info, profileFastClos, &SstClosInfo{EPP: 0, MaxFreq: 255}); err != nil {
+		return err
+	}
+	if err := ClosSetup(info, profileSlowClos, &SstClosInfo{EPP: 15, MaxFreq: 255}); err != nil {
+		return err
+	}
+
+	slow := utils.NewIDSet(info.pkg.cpus...)
+	slow.Del(info.BFCores.Members()...)
+
+	cpu2clos := ClosCPUSet{
+		profileFastClos: info.BFCores.Clone(),
+		profileSlowClos: slow,
+	}
+	if err := ConfigureCPPriority(info, Ordered, &cpu2clos); err != nil {
+		return err
+	}
+
+	return EnableCP(info)
+}
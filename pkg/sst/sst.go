@@ -17,9 +17,15 @@ limitations under the License.
 package sst
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	stdlog "log"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	grclog "github.com/intel/goresctrl/pkg/log"
 	goresctrlpath "github.com/intel/goresctrl/pkg/path"
@@ -45,6 +51,10 @@ type SstPackageInfo struct {
 	CPPriority  CPPriorityType
 	BFSupported bool
 	BFEnabled   bool
+	// BFCores are the high priority cores that are boosted when SST-BF is
+	// enabled. They are available whenever SST-BF is supported, also
+	// when it is disabled, so that the boost set can be previewed before
+	// enabling the feature.
 	BFCores     utils.IDSet
 	TFSupported bool
 	TFEnabled   bool
@@ -53,6 +63,10 @@ type SstPackageInfo struct {
 	ClosCPUInfo ClosCPUSet
 }
 
+// ErrSSTLocked is returned when trying to change SST configuration that has
+// been locked, e.g. by the platform firmware (BIOS).
+var ErrSSTLocked = errors.New("SST configuration locked")
+
 // NumClos is the number of CLOSes suported by SST-CP
 const NumClos = 4
 
@@ -69,13 +83,188 @@ type SstClosInfo struct {
 type CPPriorityType int
 
 const (
+	// Proportional priority distributes frequency between the CLOSes in
+	// proportion to the ProportionalPriority of each CLOS
 	Proportional CPPriorityType = 0
-	Ordered      CPPriorityType = 1
+	// Ordered priority serves the CLOSes in the order of their ids, CLOS 0
+	// having the highest priority. ProportionalPriority is ignored.
+	Ordered CPPriorityType = 1
 )
 
+// String returns the name of the CP priority type.
+func (t CPPriorityType) String() string {
+	switch t {
+	case Proportional:
+		return "Proportional"
+	case Ordered:
+		return "Ordered"
+	}
+	return fmt.Sprintf("CPPriorityType(%d)", int(t))
+}
+
 // ClosCPUSet contains mapping from Clos id to a set of CPU ids
 type ClosCPUSet map[int]utils.IDSet
 
+// IsLocked returns true if the SST-PP configuration of the package is locked,
+// i.e. the active performance profile level cannot be changed. This is
+// common on production systems and does not prevent changing the state of
+// the other SST features, e.g. SST-BF or SST-TF.
+func (info *SstPackageInfo) IsLocked() bool {
+	return info.PPLocked
+}
+
+// PriorityMode returns the CLOS priority mode of SST-CP, i.e. whether the
+// ProportionalPriority of the CLOSes is in effect.
+func (info *SstPackageInfo) PriorityMode() CPPriorityType {
+	return info.CPPriority
+}
+
+// ActiveClosCount returns the number of CLOSes that have CPUs assigned.
+func (info *SstPackageInfo) ActiveClosCount() int {
+	n := 0
+	for clos := 0; clos < NumClos; clos++ {
+		if info.ClosCPUInfo[clos].Size() > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// SstFeature describes the status of one SST feature
+type SstFeature struct {
+	Supported bool
+	Enabled   bool
+}
+
+// String returns the status of the feature as "unsupported", "disabled" or
+// "enabled".
+func (f SstFeature) String() string {
+	switch {
+	case !f.Supported:
+		return "unsupported"
+	case f.Enabled:
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// SstFeatureSet summarizes the SST features available on a package.
+type SstFeatureSet struct {
+	PP SstFeature
+	CP SstFeature
+	BF SstFeature
+	TF SstFeature
+}
+
+// String returns a concise single-line summary of the feature set.
+func (s SstFeatureSet) String() string {
+	return fmt.Sprintf("PP:%s CP:%s BF:%s TF:%s", s.PP, s.CP, s.BF, s.TF)
+}
+
+// Features returns a summary of the SST features supported and enabled on
+// the package. SST-PP does not have an enable switch of its own so it is
+// reported as enabled whenever it is supported.
+func (info *SstPackageInfo) Features() SstFeatureSet {
+	return SstFeatureSet{
+		PP: SstFeature{Supported: info.PPSupported, Enabled: info.PPSupported},
+		CP: SstFeature{Supported: info.CPSupported, Enabled: info.CPEnabled},
+		BF: SstFeature{Supported: info.BFSupported, Enabled: info.BFEnabled},
+		TF: SstFeature{Supported: info.TFSupported, Enabled: info.TFEnabled},
+	}
+}
+
+// sstPackageInfoJSON is the JSON representation of SstPackageInfo
+type sstPackageInfoJSON struct {
+	Package        *int           `json:"package,omitempty"`
+	PPSupported    bool           `json:"ppSupported"`
+	PPLocked       bool           `json:"ppLocked"`
+	PPVersion      int            `json:"ppVersion"`
+	PPCurrentLevel int            `json:"ppCurrentLevel"`
+	PPMaxLevel     int            `json:"ppMaxLevel"`
+	CPSupported    bool           `json:"cpSupported"`
+	CPEnabled      bool           `json:"cpEnabled"`
+	CPPriority     string         `json:"cpPriority"`
+	BFSupported    bool           `json:"bfSupported"`
+	BFEnabled      bool           `json:"bfEnabled"`
+	BFCores        []utils.ID     `json:"bfCores"`
+	TFSupported    bool           `json:"tfSupported"`
+	TFEnabled      bool           `json:"tfEnabled"`
+	Clos           []closInfoJSON `json:"clos"`
+}
+
+// closInfoJSON is the JSON representation of one CLOS of SstPackageInfo
+type closInfoJSON struct {
+	ID                   int        `json:"id"`
+	EPP                  int        `json:"epp"`
+	ProportionalPriority int        `json:"proportionalPriority"`
+	MinFreq              int        `json:"minFreq"`
+	MaxFreq              int        `json:"maxFreq"`
+	DesiredFreq          int        `json:"desiredFreq"`
+	CPUs                 []utils.ID `json:"cpus"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The output has a
+// stable schema, independent of the layout of the SstPackageInfo struct:
+//
+//	{
+//	  "package": <package id>,
+//	  "ppSupported": <bool>, "ppLocked": <bool>, "ppVersion": <int>,
+//	  "ppCurrentLevel": <int>, "ppMaxLevel": <int>,
+//	  "cpSupported": <bool>, "cpEnabled": <bool>,
+//	  "cpPriority": "proportional" | "ordered",
+//	  "bfSupported": <bool>, "bfEnabled": <bool>,
+//	  "bfCores": [<cpu id>, ...],
+//	  "tfSupported": <bool>, "tfEnabled": <bool>,
+//	  "clos": [
+//	    {"id": <int>, "epp": <int>, "proportionalPriority": <int>,
+//	     "minFreq": <int>, "maxFreq": <int>, "desiredFreq": <int>,
+//	     "cpus": [<cpu id>, ...]},
+//	    ...
+//	  ]
+//	}
+//
+// CPU lists are sorted in ascending order and "clos" always contains NumClos
+// entries, ordered by CLOS id. The package id is omitted if unknown. An
+// error is returned if CPPriority is not one of the known priority types.
+func (info SstPackageInfo) MarshalJSON() ([]byte, error) {
+	if info.CPPriority != Proportional && info.CPPriority != Ordered {
+		return nil, fmt.Errorf("unknown SST CP priority type %d", int(info.CPPriority))
+	}
+
+	out := sstPackageInfoJSON{
+		PPSupported:    info.PPSupported,
+		PPLocked:       info.PPLocked,
+		PPVersion:      info.PPVersion,
+		PPCurrentLevel: info.PPCurrentLevel,
+		PPMaxLevel:     info.PPMaxLevel,
+		CPSupported:    info.CPSupported,
+		CPEnabled:      info.CPEnabled,
+		CPPriority:     strings.ToLower(info.CPPriority.String()),
+		BFSupported:    info.BFSupported,
+		BFEnabled:      info.BFEnabled,
+		BFCores:        info.BFCores.SortedMembers(),
+		TFSupported:    info.TFSupported,
+		TFEnabled:      info.TFEnabled,
+		Clos:           make([]closInfoJSON, NumClos),
+	}
+	if info.pkg != nil {
+		id := info.pkg.id
+		out.Package = &id
+	}
+	for i, c := range info.ClosInfo {
+		out.Clos[i] = closInfoJSON{
+			ID:                   i,
+			EPP:                  c.EPP,
+			ProportionalPriority: c.ProportionalPriority,
+			MinFreq:              c.MinFreq,
+			MaxFreq:              c.MaxFreq,
+			DesiredFreq:          c.DesiredFreq,
+			CPUs:                 info.ClosCPUInfo[i].SortedMembers(),
+		}
+	}
+	return json.Marshal(out)
+}
+
 var sstlog grclog.Logger = grclog.NewLoggerWrapper(stdlog.New(os.Stderr, "[ sst ] ", 0))
 
 func isstDevPath() string { return goresctrlpath.Path("dev/isst_interface") }
@@ -116,6 +305,13 @@ func GetPackageInfo(pkgs ...int) (map[int]*SstPackageInfo, error) {
 		return nil, fmt.Errorf("failed to determine cpu topology: %w", err)
 	}
 
+	// Drop stale punit cpu mappings of offlined cpus
+	online := utils.NewIDSet()
+	for _, pkg := range packages {
+		online.Add(pkg.cpus...)
+	}
+	pruneCPUMapping(online)
+
 	if len(pkgs) == 0 {
 		for i := range packages {
 			pkglist = append(pkglist, i)
@@ -133,18 +329,73 @@ func GetPackageInfo(pkgs ...int) (map[int]*SstPackageInfo, error) {
 	numPkgs = len(pkglist)
 	infomap := make(map[int]*SstPackageInfo, numPkgs)
 
+	workers := int(atomic.LoadInt32(&packageInfoConcurrency))
+	if workers <= 0 || workers > numPkgs {
+		workers = numPkgs
+	}
+
+	var (
+		lock     sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	queue := make(chan int, numPkgs)
 	for _, i := range pkglist {
-		info, err := getSinglePackageInfo(packages[i])
-		if err != nil {
-			return nil, err
-		}
+		queue <- i
+	}
+	close(queue)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				lock.Lock()
+				failed := firstErr != nil
+				lock.Unlock()
+				if failed {
+					// Cancel the remaining packages
+					return
+				}
+
+				info, err := getSinglePackageInfo(packages[i])
+
+				lock.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					infomap[i] = &info
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
 
-		infomap[i] = &info
+	if firstErr != nil {
+		return nil, firstErr
 	}
 
 	return infomap, nil
 }
 
+// packageInfoConcurrency is the maximum number of packages whose info is
+// retrieved concurrently, zero meaning no limit.
+var packageInfoConcurrency int32
+
+// SetPackageInfoConcurrency caps the number of CPU packages whose info is
+// retrieved concurrently by GetPackageInfo(). Zero (the default) means no
+// limit, i.e. all packages are read in parallel, and one reads the packages
+// sequentially.
+func SetPackageInfoConcurrency(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&packageInfoConcurrency, int32(n))
+}
+
 // getSinglePackageInfo returns information of the SST configuration of one cpu
 // package.
 func getSinglePackageInfo(pkg *cpuPackageInfo) (SstPackageInfo, error) {
@@ -187,7 +438,8 @@ func getSinglePackageInfo(pkg *cpuPackageInfo) (SstPackageInfo, error) {
 	info.TFSupported = isBitSet(rsp, 0)
 	info.TFEnabled = isBitSet(rsp, 16)
 
-	// Read base-frequency info
+	// Read base-frequency info. The core mask of the current PP level does
+	// not depend on the BF enable state.
 	if info.BFSupported {
 		info.BFCores = utils.IDSet{}
 
@@ -235,34 +487,14 @@ func getSinglePackageInfo(pkg *cpuPackageInfo) (SstPackageInfo, error) {
 		}
 
 		info.CPPriority = CPPriorityType(getBits(rsp, 2, 2))
-		info.ClosCPUInfo = make(map[int]utils.IDSet, NumClos)
 
 		for i := 0; i < NumClos; i++ {
-			if rsp, err = sendClosCmd(cpu, CLOS_PM_CLOS, uint32(i), 0); err != nil {
-				return info, fmt.Errorf("failed to read SST CLOS #%d info: %v", i, err)
-			}
-
-			info.ClosInfo[i] = SstClosInfo{
-				EPP:                  int(getBits(rsp, 0, 3)),
-				ProportionalPriority: int(getBits(rsp, 4, 7)),
-				MinFreq:              int(getBits(rsp, 8, 15)),
-				MaxFreq:              int(getBits(rsp, 16, 23)),
-				DesiredFreq:          int(getBits(rsp, 24, 31)),
+			if info.ClosInfo[i], err = loadClos(cpu, i); err != nil {
+				return info, err
 			}
 		}
 
-		for _, id := range pkg.cpus {
-			closId, err := GetCPUClosID(id)
-			if err != nil {
-				continue
-			}
-
-			if info.ClosCPUInfo[closId] == nil {
-				info.ClosCPUInfo[closId] = utils.NewIDSet(id)
-			} else {
-				info.ClosCPUInfo[closId].Add(id)
-			}
-		}
+		info.ClosCPUInfo = getClosCPUMap(pkg)
 	}
 
 	return info, nil
@@ -292,6 +524,89 @@ func GetCPUClosID(cpu utils.ID) (int, error) {
 	return int(getBits(rsp, 16, 17)), nil
 }
 
+// GetClosCPUMap returns the current assignment of the CPUs of a package to
+// CLOSes, read from punit. CPUs whose CLOS cannot be read are left out.
+func GetClosCPUMap(pkg int) (ClosCPUSet, error) {
+	packages, err := getOnlineCpuPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine cpu topology: %w", err)
+	}
+	p, ok := packages[pkg]
+	if !ok {
+		return nil, fmt.Errorf("cpu package %d not present", pkg)
+	}
+	return getClosCPUMap(p), nil
+}
+
+func getClosCPUMap(pkg *cpuPackageInfo) ClosCPUSet {
+	ret := make(ClosCPUSet, NumClos)
+	for _, id := range pkg.cpus {
+		closId, err := GetCPUClosID(id)
+		if err != nil {
+			sstlog.Debugf("failed to read CLOS of cpu %d: %v", id, err)
+			continue
+		}
+
+		if ret[closId] == nil {
+			ret[closId] = utils.NewIDSet(id)
+		} else {
+			ret[closId].Add(id)
+		}
+	}
+	return ret
+}
+
+// CPUMapping describes how a Linux logical CPU maps to the PUNIT CPU and
+// core numbering used by SST.
+type CPUMapping struct {
+	CPU       utils.ID
+	PunitCPU  utils.ID
+	PunitCore uint32
+	// Clos is the SST-CP CLOS the CPU is associated with, -1 if it could
+	// not be read (e.g. SST-CP is not supported).
+	Clos int
+}
+
+// GetCPUTopologyMapping returns the mapping between Linux logical CPUs,
+// PUNIT CPUs and PUNIT cores for all online CPUs of a package, sorted by the
+// logical CPU id.
+func GetCPUTopologyMapping(pkg int) ([]CPUMapping, error) {
+	pkgs, err := getOnlineCpuPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cpu package information: %w", err)
+	}
+	p, ok := pkgs[pkg]
+	if !ok {
+		return nil, fmt.Errorf("cpu package %d not present", pkg)
+	}
+
+	cpus := append([]int{}, p.cpus...)
+	sort.Ints(cpus)
+
+	ret := make([]CPUMapping, 0, len(cpus))
+	for _, cpu := range cpus {
+		id := utils.ID(cpu)
+		punitCpu, err := punitCPU(id)
+		if err != nil {
+			return nil, err
+		}
+		m := CPUMapping{
+			CPU:       id,
+			PunitCPU:  punitCpu,
+			PunitCore: uint32(punitCpu) >> 1,
+			Clos:      -1,
+		}
+		if clos, err := GetCPUClosID(id); err == nil {
+			m.Clos = clos
+		} else {
+			sstlog.Debugf("failed to read CLOS of cpu %d: %v", cpu, err)
+		}
+		ret = append(ret, m)
+	}
+
+	return ret, nil
+}
+
 func getBits(val, i, j uint32) uint32 {
 	lsb := i
 	msb := j
@@ -314,19 +629,78 @@ func clearBit(val, n uint32) uint32 {
 	return val &^ (1 << n)
 }
 
-func setBFStatus(info *SstPackageInfo, status bool) error {
+// checkUnlocked returns ErrSSTLocked if the configuration of a package is
+// locked.
+func checkUnlocked(info *SstPackageInfo, feature string) error {
+	if info.IsLocked() {
+		return fmt.Errorf("failed to change SST %s configuration of package %d: %w", feature, info.pkg.id, ErrSSTLocked)
+	}
+	return nil
+}
+
+// SetPPLevel switches the active SST-PP (Perf Profile) level of a package.
+// The level must not exceed PPMaxLevel of the package. ErrSSTLocked is
+// returned if the configuration has been locked, e.g. by the BIOS. The
+// package info is re-read after the switch to confirm the new level.
+func SetPPLevel(pkg int, level int) error {
+	infomap, err := GetPackageInfo(pkg)
+	if err != nil {
+		return err
+	}
+	info := infomap[pkg]
+
+	if !info.PPSupported {
+		return fmt.Errorf("SST PP not supported on package %d", pkg)
+	}
+	if err := checkUnlocked(info, "PP"); err != nil {
+		return err
+	}
+	if level < 0 || level > info.PPMaxLevel {
+		return fmt.Errorf("invalid SST PP level %d for package %d, must be between 0 and %d", level, pkg, info.PPMaxLevel)
+	}
+	if level == info.PPCurrentLevel {
+		return nil
+	}
+
+	sstlog.Infof("switching SST PP level of package %d from %d to %d", pkg, info.PPCurrentLevel, level)
+
+	if _, err := sendMboxCmd(info.pkg.cpus[0], CONFIG_TDP, CONFIG_TDP_SET_LEVEL, 0, uint32(level)); err != nil {
+		return fmt.Errorf("failed to set SST PP level of package %d: %w", pkg, err)
+	}
+
+	if infomap, err = GetPackageInfo(pkg); err != nil {
+		return fmt.Errorf("failed to verify SST PP level of package %d: %w", pkg, err)
+	}
+	if cur := infomap[pkg].PPCurrentLevel; cur != level {
+		return fmt.Errorf("failed to set SST PP level of package %d: level is %d after setting it to %d", pkg, cur, level)
+	}
+
+	return nil
+}
+
+// setTDPControlBit sets or clears the control bit of an SST feature in the
+// TDP control register of the current PP level.
+func setTDPControlBit(info *SstPackageInfo, feature string, bit uint32, status bool) error {
 	rsp, err := sendMboxCmd(info.pkg.cpus[0], CONFIG_TDP, CONFIG_TDP_GET_TDP_CONTROL, 0, uint32(info.PPCurrentLevel))
 	if err != nil {
 		return fmt.Errorf("failed to read SST status: %w", err)
 	}
 
-	req := clearBit(rsp, 17)
+	req := clearBit(rsp, bit)
 	if status {
-		req = setBit(rsp, 17)
+		req = setBit(rsp, bit)
 	}
 
 	if _, err = sendMboxCmd(info.pkg.cpus[0], CONFIG_TDP, CONFIG_TDP_SET_TDP_CONTROL, 0, req); err != nil {
-		return fmt.Errorf("failed to enable SST %s: %w", "BF", err)
+		return fmt.Errorf("failed to enable SST %s: %w", feature, err)
+	}
+
+	return nil
+}
+
+func setBFStatus(info *SstPackageInfo, status bool) error {
+	if err := setTDPControlBit(info, "BF", 17, status); err != nil {
+		return err
 	}
 
 	info.BFEnabled = status
@@ -334,6 +708,16 @@ func setBFStatus(info *SstPackageInfo, status bool) error {
 	return nil
 }
 
+func setTFStatus(info *SstPackageInfo, status bool) error {
+	if err := setTDPControlBit(info, "TF", 16, status); err != nil {
+		return err
+	}
+
+	info.TFEnabled = status
+
+	return nil
+}
+
 func setScalingMin2CPUInfoMax(info *SstPackageInfo) error {
 	for _, cpu := range info.pkg.cpus {
 		err := setCPUScalingMin2CPUInfoMaxFreq(cpu)
@@ -345,9 +729,109 @@ func setScalingMin2CPUInfoMax(info *SstPackageInfo) error {
 	return nil
 }
 
-func enableBF(info *SstPackageInfo) error {
+// CanEnableBF checks whether SST-BF can be enabled on a package, considering
+// the current state of the other SST features. If not, a human-readable
+// reason is returned.
+func CanEnableBF(info *SstPackageInfo) (bool, string) {
+	if info == nil {
+		return false, "package info is nil"
+	}
 	if !info.BFSupported {
-		return fmt.Errorf("SST BF not supported")
+		return false, "SST BF not supported"
+	}
+	if info.TFEnabled {
+		return false, "SST TF enabled, disable it first"
+	}
+	if ok, err := isHWPEnabled(); err != nil {
+		return false, "failed to determine if HWP is enabled"
+	} else if !ok {
+		return false, "HWP is not enabled"
+	}
+	if info.BFEnabled {
+		return true, "SST BF already enabled"
+	}
+	return true, ""
+}
+
+// CanEnableTF checks whether SST-TF can be enabled on a package, considering
+// the current state of the other SST features. If not, a human-readable
+// reason is returned.
+func CanEnableTF(info *SstPackageInfo) (bool, string) {
+	if info == nil {
+		return false, "package info is nil"
+	}
+	if !info.TFSupported {
+		return false, "SST TF not supported"
+	}
+	if info.BFEnabled {
+		return false, "SST BF enabled, disable it first"
+	}
+	if !info.CPEnabled {
+		return false, "SST CP not enabled, enable it first"
+	}
+	if ok, err := isHWPEnabled(); err != nil {
+		return false, "failed to determine if HWP is enabled"
+	} else if !ok {
+		return false, "HWP is not enabled"
+	}
+	if info.TFEnabled {
+		return true, "SST TF already enabled"
+	}
+	return true, ""
+}
+
+func enableTF(info *SstPackageInfo) error {
+	if ok, reason := CanEnableTF(info); !ok {
+		return fmt.Errorf("%s", reason)
+	}
+
+	return setTFStatus(info, true)
+}
+
+// EnableTF enables SST-TF. SST-CP must be enabled and SST-BF disabled on the
+// packages.
+func EnableTF(pkgs ...int) error {
+	info, err := GetPackageInfo(pkgs...)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range info {
+		if err := enableTF(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func disableTF(info *SstPackageInfo) error {
+	if !info.TFSupported {
+		return fmt.Errorf("SST TF not supported")
+	}
+
+	return setTFStatus(info, false)
+}
+
+// DisableTF disables SST-TF
+func DisableTF(pkgs ...int) error {
+	info, err := GetPackageInfo(pkgs...)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range info {
+		if err := disableTF(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func enableBF(info *SstPackageInfo) error {
+	if ok, reason := CanEnableBF(info); !ok {
+		return fmt.Errorf("%s", reason)
 	}
 
 	if err := setBFStatus(info, true); err != nil {
@@ -363,12 +847,6 @@ func enableBF(info *SstPackageInfo) error {
 
 // EnableBF enables SST-BF and sets it up properly
 func EnableBF(pkgs ...int) error {
-	if ok, err := isHWPEnabled(); err != nil {
-		return fmt.Errorf("Failed to determine if HWP is enabled")
-	} else if !ok {
-		return fmt.Errorf("HWP is not enabled")
-	}
-
 	info, err := GetPackageInfo(pkgs...)
 	if err != nil {
 		return err
@@ -426,6 +904,41 @@ func DisableBF(pkgs ...int) error {
 	return nil
 }
 
+// VerifyBF checks that SST-BF has taken effect on a package, i.e. that the
+// scaling_min_freq of all BF (high priority) cores matches their
+// cpuinfo_max_freq. Returns true if all BF cores are set up correctly,
+// otherwise false and the list of cores that did not get updated. The info
+// should be up-to-date, e.g. re-read with GetPackageInfo() after EnableBF().
+func VerifyBF(info *SstPackageInfo) (bool, []utils.ID, error) {
+	if info == nil {
+		return false, nil, fmt.Errorf("package info is nil")
+	}
+	if !info.BFSupported {
+		return false, nil, fmt.Errorf("SST BF not supported")
+	}
+	if !info.BFEnabled {
+		return false, nil, fmt.Errorf("SST BF not enabled on package %d", info.pkg.id)
+	}
+
+	failed := []utils.ID{}
+	for _, cpu := range info.BFCores.SortedMembers() {
+		minFreq, err := utils.GetCPUFreqValue(cpu, "scaling_min_freq")
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to read scaling_min_freq of cpu %d: %w", cpu, err)
+		}
+		maxFreq, err := utils.GetCPUFreqValue(cpu, "cpuinfo_max_freq")
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to read cpuinfo_max_freq of cpu %d: %w", cpu, err)
+		}
+		if minFreq != maxFreq {
+			sstlog.Debugf("BF core %d not updated: scaling_min_freq %d, cpuinfo_max_freq %d", cpu, minFreq, maxFreq)
+			failed = append(failed, cpu)
+		}
+	}
+
+	return len(failed) == 0, failed, nil
+}
+
 func sendClosCmd(cpu utils.ID, subCmd uint16, parameter uint32, reqData uint32) (uint32, error) {
 	var id, offset uint32
 
@@ -445,6 +958,37 @@ func sendClosCmd(cpu utils.ID, subCmd uint16, parameter uint32, reqData uint32)
 	return sendMMIOCmd(cpu, (id<<2)+offset, reqData, isBitSet(parameter, MBOX_CMD_WRITE_BIT))
 }
 
+func loadClos(cpu utils.ID, clos int) (SstClosInfo, error) {
+	rsp, err := sendClosCmd(cpu, CLOS_PM_CLOS, uint32(clos), 0)
+	if err != nil {
+		return SstClosInfo{}, fmt.Errorf("failed to read SST CLOS #%d info: %v", clos, err)
+	}
+
+	return SstClosInfo{
+		EPP:                  int(getBits(rsp, 0, 3)),
+		ProportionalPriority: int(getBits(rsp, 4, 7)),
+		MinFreq:              int(getBits(rsp, 8, 15)),
+		MaxFreq:              int(getBits(rsp, 16, 23)),
+		DesiredFreq:          int(getBits(rsp, 24, 31)),
+	}, nil
+}
+
+// saveClosVerified saves the Clos settings and reads them back. Returns the
+// settings actually stored, which may differ from the requested ones if the
+// punit adjusted them, e.g. clamped a frequency.
+func saveClosVerified(closInfo *SstClosInfo, cpu utils.ID, clos int) (SstClosInfo, error) {
+	if err := saveClos(closInfo, cpu, clos); err != nil {
+		return SstClosInfo{}, err
+	}
+
+	actual, err := loadClos(cpu, clos)
+	if err != nil {
+		return SstClosInfo{}, fmt.Errorf("failed to verify Clos: %v", err)
+	}
+
+	return actual, nil
+}
+
 func saveClos(closInfo *SstClosInfo, cpu utils.ID, clos int) error {
 	req := closInfo.EPP & 0x0f
 	req |= (closInfo.ProportionalPriority & 0x0f) << 4
@@ -556,14 +1100,33 @@ func assignCPU2Clos(info *SstPackageInfo, clos int) error {
 	return nil
 }
 
-// ConfigureCP will allow caller to configure CPUs to various Clos.
+// ConfigureCP will allow caller to configure CPUs to various Clos. The
+// priority must be 0 (Proportional) or 1 (Ordered).
+//
+// Deprecated: use ConfigureCPPriority, which takes the priority as a
+// CPPriorityType.
 func ConfigureCP(info *SstPackageInfo, priority int, cpu2clos *ClosCPUSet) error {
+	return ConfigureCPPriority(info, CPPriorityType(priority), cpu2clos)
+}
+
+// ConfigureCPPriority will allow caller to configure CPUs to various Clos.
+// The priority argument selects the priority ordering used, proportional
+// priority weights of the Clos are only meaningful in Proportional mode.
+func ConfigureCPPriority(info *SstPackageInfo, priority CPPriorityType, cpu2clos *ClosCPUSet) error {
 	if info == nil {
 		return fmt.Errorf("package info is nil")
 	}
 
-	if priority < 0 || priority > 1 {
-		return fmt.Errorf("Invalid CP priority value %d (valid 0 or 1)", priority)
+	if priority != Proportional && priority != Ordered {
+		return fmt.Errorf("Invalid CP priority value %d (valid %d (%s) or %d (%s))", priority, Proportional, Proportional, Ordered, Ordered)
+	}
+
+	if priority == Ordered {
+		for clos, closInfo := range info.ClosInfo {
+			if closInfo.ProportionalPriority != 0 {
+				sstlog.Warnf("proportional priority %d of Clos %d is ignored in %s priority mode", closInfo.ProportionalPriority, clos, priority)
+			}
+		}
 	}
 
 	if info.ClosCPUInfo == nil {
@@ -589,12 +1152,75 @@ func ConfigureCP(info *SstPackageInfo, priority int, cpu2clos *ClosCPUSet) error
 		}
 	}
 
-	info.CPPriority = CPPriorityType(priority)
+	info.CPPriority = priority
 
 	return nil
 }
 
-// ClosSetup stores the user supplied Clos information into punit
+// closFreqRatioRange returns the range of frequency ratios supported by a
+// package: the minimum from the cpufreq cpuinfo_min_freq of the package and
+// the maximum from the highest turbo ratio limit of the current PP level.
+func closFreqRatioRange(info *SstPackageInfo) (int, int, error) {
+	cpu := info.pkg.cpus[0]
+
+	minFreq, err := utils.GetCPUFreqValue(cpu, "cpuinfo_min_freq")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read minimum frequency: %w", err)
+	}
+
+	rsp, err := sendMboxCmd(cpu, CONFIG_TDP, CONFIG_TDP_GET_TURBO_LIMIT_RATIOS, 0, uint32(info.PPCurrentLevel))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read turbo ratio limits: %w", err)
+	}
+
+	// cpufreq reports kHz, the ratios are in units of 100 MHz
+	return minFreq / 100000, int(getBits(rsp, 0, 7)), nil
+}
+
+// checkClosFreqs validates the frequencies of a Clos against the frequency
+// ratio range of the package. The values 0 and 255 denote no limit and are
+// always accepted. The check is skipped if the range cannot be determined.
+func checkClosFreqs(info *SstPackageInfo, closInfo *SstClosInfo) error {
+	minRatio, maxRatio, err := closFreqRatioRange(info)
+	if err != nil {
+		sstlog.Warnf("skipping Clos frequency validation on package %d: %v", info.pkg.id, err)
+		return nil
+	}
+	return validateClosFreqs(info.pkg.id, minRatio, maxRatio, closInfo)
+}
+
+// validateClosFreqs validates the frequencies of a Clos against a frequency
+// ratio range. The check is skipped if the range is invalid.
+func validateClosFreqs(pkg, minRatio, maxRatio int, closInfo *SstClosInfo) error {
+	if maxRatio == 0 || minRatio > maxRatio {
+		sstlog.Warnf("skipping Clos frequency validation on package %d: invalid frequency ratio range %d-%d", pkg, minRatio, maxRatio)
+		return nil
+	}
+
+	for _, f := range []struct {
+		name string
+		val  int
+	}{
+		{"min freq", closInfo.MinFreq},
+		{"max freq", closInfo.MaxFreq},
+		{"desired freq", closInfo.DesiredFreq},
+	} {
+		if f.val == 0 || f.val == 255 {
+			continue
+		}
+		if f.val < minRatio || f.val > maxRatio {
+			return fmt.Errorf("%s %d out of range for package %d, allowed range is %d-%d (or 0 and 255 for no limit)",
+				f.name, f.val, pkg, minRatio, maxRatio)
+		}
+	}
+	return nil
+}
+
+// ClosSetup stores the user supplied Clos information into punit. The
+// frequencies are validated against the frequency ratio range of the
+// package, the values 0 and 255 being accepted as no limit. The settings are
+// read back after writing and a warning is logged if punit adjusted them.
+// The package info is updated with the settings actually stored.
 func ClosSetup(info *SstPackageInfo, clos int, closInfo *SstClosInfo) error {
 	if info == nil {
 		return fmt.Errorf("package info is nil")
@@ -620,6 +1246,10 @@ func ClosSetup(info *SstPackageInfo, clos int, closInfo *SstClosInfo) error {
 		return fmt.Errorf("Invalid value %d for desired freq", closInfo.DesiredFreq)
 	}
 
+	if err := checkClosFreqs(info, closInfo); err != nil {
+		return err
+	}
+
 	if closInfo.EPP < 0 || closInfo.EPP > 15 {
 		return fmt.Errorf("Invalid value %d for EPP", closInfo.EPP)
 	}
@@ -628,9 +1258,79 @@ func ClosSetup(info *SstPackageInfo, clos int, closInfo *SstClosInfo) error {
 		return fmt.Errorf("Invalid value %d for proportionalPriority", closInfo.ProportionalPriority)
 	}
 
-	info.ClosInfo[clos] = *closInfo
+	if closInfo.ProportionalPriority != 0 && info.CPPriority != Proportional {
+		sstlog.Warnf("proportional priority %d of Clos %d is ignored in %s priority mode", closInfo.ProportionalPriority, clos, info.CPPriority)
+	}
 
-	return saveClos(&info.ClosInfo[clos], info.pkg.cpus[0], clos)
+	actual, err := saveClosVerified(closInfo, info.pkg.cpus[0], clos)
+	if err != nil {
+		return err
+	}
+
+	if actual != *closInfo {
+		sstlog.Warnf("Clos %d settings adjusted by hardware on package %d: requested %+v, stored %+v",
+			clos, info.pkg.id, *closInfo, actual)
+	}
+	info.ClosInfo[clos] = actual
+
+	return nil
+}
+
+// EPPPolicy is a named energy-performance preference of a Clos, mirroring the
+// energy_performance_preference strings of the Linux cpufreq interface.
+type EPPPolicy string
+
+const (
+	// EPPPerformance prefers performance over energy efficiency (EPP 0).
+	EPPPerformance EPPPolicy = "performance"
+	// EPPBalancePerformance balances, leaning towards performance (EPP 8).
+	EPPBalancePerformance EPPPolicy = "balance_performance"
+	// EPPBalancePower balances, leaning towards energy efficiency (EPP 12).
+	EPPBalancePower EPPPolicy = "balance_power"
+	// EPPPower prefers energy efficiency over performance (EPP 15).
+	EPPPower EPPPolicy = "power"
+)
+
+// eppPolicies maps the EPP policies to the raw EPP values of SST-CP
+var eppPolicies = map[EPPPolicy]int{
+	EPPPerformance:        0,
+	EPPBalancePerformance: 8,
+	EPPBalancePower:       12,
+	EPPPower:              15,
+}
+
+// EPP returns the raw EPP value of the policy.
+func (p EPPPolicy) EPP() (int, error) {
+	epp, ok := eppPolicies[p]
+	if !ok {
+		return 0, fmt.Errorf("unknown EPP policy %q", p)
+	}
+	return epp, nil
+}
+
+// ClosFreqs contains the frequency settings of a Clos, see SstClosInfo.
+type ClosFreqs struct {
+	MinFreq     int
+	MaxFreq     int
+	DesiredFreq int
+}
+
+// ClosSetupPolicy stores Clos information into punit, like ClosSetup(), with
+// the EPP derived from a named policy instead of a raw value. The
+// proportional priority of the Clos is set to zero, use ClosSetup() for
+// precise control of all the settings.
+func ClosSetupPolicy(info *SstPackageInfo, clos int, policy EPPPolicy, freqs ClosFreqs) error {
+	epp, err := policy.EPP()
+	if err != nil {
+		return err
+	}
+
+	return ClosSetup(info, clos, &SstClosInfo{
+		EPP:         epp,
+		MinFreq:     freqs.MinFreq,
+		MaxFreq:     freqs.MaxFreq,
+		DesiredFreq: freqs.DesiredFreq,
+	})
 }
 
 // ResetCPConfig will bring the system to a known state. This means that all
@@ -643,23 +1343,76 @@ func ResetCPConfig() error {
 	}
 
 	for _, info := range infomap {
-		for _, cpu := range info.pkg.cpus {
-			if info.pkg.cpus[0] == cpu {
-				if err := setDefaultClosParam(info, cpu); err != nil {
-					return err
-				}
-			}
+		if err := resetCPConfig(info); err != nil {
+			return err
+		}
+	}
 
-			if err := associate2Clos(cpu, 0); err != nil {
-				return fmt.Errorf("failed to associate cpu %d to clos %d: %w", cpu, 0, err)
+	return nil
+}
+
+// resetCPConfig resets the Clos configuration of one package and moves all
+// of its CPUs to Clos 0.
+func resetCPConfig(info *SstPackageInfo) error {
+	for _, cpu := range info.pkg.cpus {
+		if info.pkg.cpus[0] == cpu {
+			if err := setDefaultClosParam(info, cpu); err != nil {
+				return err
 			}
 		}
+
+		if err := associate2Clos(cpu, 0); err != nil {
+			return fmt.Errorf("failed to associate cpu %d to clos %d: %w", cpu, 0, err)
+		}
+	}
+
+	return nil
+}
+
+// SetCPCoreMask limits the set of CPUs of a package that are under active
+// CLOS management. SST-CP itself cannot be enabled for a subset of cores, the
+// hardware only supports enabling it for the whole package. All CPUs of the
+// package that are not in cpus are associated with CLOS 0 and are thus
+// effectively left unmanaged, provided that CLOS 0 is kept at its default
+// (unrestricted) configuration, see ResetCPConfig().
+func SetCPCoreMask(info *SstPackageInfo, cpus utils.IDSet) error {
+	if info == nil {
+		return fmt.Errorf("package info is nil")
+	}
+
+	if !info.pkg.hasCpus(cpus) {
+		return fmt.Errorf("cpus %v do not all belong to package %d", cpus, info.pkg.id)
+	}
+
+	if info.ClosCPUInfo == nil {
+		info.ClosCPUInfo = make(map[int]utils.IDSet, NumClos)
+	}
+
+	for _, cpu := range info.pkg.cpus {
+		if cpus.Has(cpu) {
+			continue
+		}
+
+		if err := associate2Clos(cpu, 0); err != nil {
+			return err
+		}
+
+		for i := 1; i < NumClos; i++ {
+			info.ClosCPUInfo[i].Del(cpu)
+		}
+		if info.ClosCPUInfo[0] == nil {
+			info.ClosCPUInfo[0] = utils.NewIDSet(cpu)
+		} else {
+			info.ClosCPUInfo[0].Add(cpu)
+		}
 	}
 
 	return nil
 }
 
-// EnableCP enables SST-CP feature
+// EnableCP enables SST-CP feature. Note that SST-CP is a package-wide
+// feature: it is enabled for all cores of the package, the cores only differ
+// in the CLOS they are associated with.
 func EnableCP(info *SstPackageInfo) error {
 	if info == nil {
 		return fmt.Errorf("package info is nil")
@@ -683,7 +1436,8 @@ func EnableCP(info *SstPackageInfo) error {
 	return nil
 }
 
-// DisableCP disables SST-CP feature
+// DisableCP disables SST-CP feature. Like EnableCP(), this affects all cores
+// of the package.
 func DisableCP(info *SstPackageInfo) error {
 	if !info.CPSupported {
 		return fmt.Errorf("SST CP not supported")
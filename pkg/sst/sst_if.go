@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"sync"
 	"syscall"
 	"unsafe"
 
@@ -31,33 +32,117 @@ import (
 // cpuMap holds the logical to punit cpu mapping table
 var cpuMap = make(map[utils.ID]utils.ID)
 
+// cpuMapLock protects cpuMap
+var cpuMapLock sync.Mutex
+
 // punitCPU returns the PUNIT CPU id corresponding a given Linux logical CPU
 func punitCPU(cpu utils.ID) (utils.ID, error) {
-	if id, ok := cpuMap[cpu]; ok {
+	cpuMapLock.Lock()
+	id, ok := cpuMap[cpu]
+	cpuMapLock.Unlock()
+	if ok {
 		return id, nil
 	}
 
 	id, err := getCPUMapping(cpu)
 	if err == nil {
+		cpuMapLock.Lock()
 		cpuMap[cpu] = id
+		cpuMapLock.Unlock()
 	}
 	return id, err
 }
 
-// isstIoctl is a helper for executing ioctls on the linux isst_if device driver
-func isstIoctl(ioctl uintptr, req uintptr) error {
+// InvalidateCPUMapping drops the cached logical to punit cpu mapping table,
+// forcing it to be re-read from the isst device. This should be called e.g.
+// after CPU hotplug events.
+func InvalidateCPUMapping() {
+	cpuMapLock.Lock()
+	defer cpuMapLock.Unlock()
+
+	cpuMap = make(map[utils.ID]utils.ID)
+}
+
+// pruneCPUMapping drops the cached mapping of cpus that are not online
+func pruneCPUMapping(online utils.IDSet) {
+	cpuMapLock.Lock()
+	defer cpuMapLock.Unlock()
+
+	for cpu := range cpuMap {
+		if !online.Has(cpu) {
+			delete(cpuMap, cpu)
+		}
+	}
+}
+
+// isstDev is the cached handle of the isst device, opened on first use
+var isstDev *os.File
+
+// isstDevLock protects isstDev. Ioctls are issued holding a read lock so
+// that they can run concurrently.
+var isstDevLock sync.RWMutex
+
+// Function for opening the isst device. This is configurable because of
+// unit tests.
+var isstDevOpenFunc func(string) (*os.File, error) = os.Open
+
+// CloseDevice closes the cached handle of the isst device. The device is
+// re-opened automatically by subsequent operations.
+func CloseDevice() error {
+	isstDevLock.Lock()
+	defer isstDevLock.Unlock()
+
+	if isstDev == nil {
+		return nil
+	}
+	err := isstDev.Close()
+	isstDev = nil
+	return err
+}
+
+// openIsstDev opens the isst device, unless it is already open.
+func openIsstDev() error {
+	isstDevLock.Lock()
+	defer isstDevLock.Unlock()
+
+	if isstDev != nil {
+		return nil
+	}
 	devPath := isstDevPath()
-	f, err := os.Open(devPath)
+	f, err := isstDevOpenFunc(devPath)
 	if err != nil {
-		return fmt.Errorf("failed to open isst device %q: %v", devPath, err)
+		return fmt.Errorf("failed to open isst device %q: %w", devPath, err)
 	}
-	defer f.Close()
+	isstDev = f
+	return nil
+}
 
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(f.Fd()), ioctl, req); errno != 0 {
-		return errno
+// withIsstDev runs fn on the cached handle of the isst device, opening the
+// device if needed. The handle is only re-opened after CloseDevice(). fn is
+// run holding a read lock so that the handle is not closed under it.
+func withIsstDev(fn func(f *os.File) error) error {
+	for {
+		isstDevLock.RLock()
+		if f := isstDev; f != nil {
+			defer isstDevLock.RUnlock()
+			return fn(f)
+		}
+		isstDevLock.RUnlock()
+
+		if err := openIsstDev(); err != nil {
+			return err
+		}
 	}
+}
 
-	return nil
+// isstIoctl is a helper for executing ioctls on the linux isst_if device driver
+func isstIoctl(ioctl uintptr, req uintptr) error {
+	return withIsstDev(func(f *os.File) error {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctl, req); errno != 0 {
+			return errno
+		}
+		return nil
+	})
 }
 
 // getCPUMapping gets mapping of Linux logical CPU numbers to (package-specific)
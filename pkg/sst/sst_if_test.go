@@ -0,0 +1,147 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/intel/goresctrl/pkg/testutils"
+)
+
+// countIsstDevOpens replaces the function for opening the isst device with
+// one that counts the opens and returns the counter. The original function
+// is restored when the test finishes.
+func countIsstDevOpens(tb testing.TB, open func(string) (*os.File, error)) *uint64 {
+	var opens uint64
+	isstDevOpenFunc = func(name string) (*os.File, error) {
+		atomic.AddUint64(&opens, 1)
+		return open(name)
+	}
+	tb.Cleanup(func() {
+		CloseDevice()
+		isstDevOpenFunc = os.Open
+	})
+	return &opens
+}
+
+func TestIsstDevCaching(t *testing.T) {
+	// A regular file stands in for the isst device
+	path := filepath.Join(t.TempDir(), "isst_interface")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("failed to create mock device: %v", err)
+	}
+	opens := countIsstDevOpens(t, func(string) (*os.File, error) { return os.Open(path) })
+
+	var handles []*os.File
+	useDev := func() {
+		err := withIsstDev(func(f *os.File) error {
+			handles = append(handles, f)
+			return nil
+		})
+		testutils.VerifyNoError(t, err)
+	}
+
+	// Device is opened once and the handle is reused
+	useDev()
+	useDev()
+	testutils.VerifyDeepEqual(t, "opens", uint64(1), atomic.LoadUint64(opens))
+	if handles[0] != handles[1] {
+		t.Errorf("cached handle of the isst device not reused")
+	}
+
+	// Errors from the driver do not cause re-opening the device
+	if err := isstIoctl(ISST_IF_GET_PHY_ID, 0); !errors.Is(err, syscall.ENOTTY) {
+		t.Errorf("expected ENOTTY from ioctl on a regular file, got %v", err)
+	}
+	testutils.VerifyDeepEqual(t, "opens", uint64(1), atomic.LoadUint64(opens))
+
+	// CloseDevice closes the handle and the device is re-opened on next use
+	testutils.VerifyNoError(t, CloseDevice())
+	if _, err := handles[0].Stat(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("handle of the isst device not closed by CloseDevice(): %v", err)
+	}
+	testutils.VerifyNoError(t, CloseDevice())
+	useDev()
+	testutils.VerifyDeepEqual(t, "opens", uint64(2), atomic.LoadUint64(opens))
+	if handles[2] == handles[0] {
+		t.Errorf("isst device not re-opened after CloseDevice()")
+	}
+
+	// Failure to open is returned and nothing is cached
+	CloseDevice()
+	isstDevOpenFunc = func(string) (*os.File, error) { return nil, os.ErrNotExist }
+	if err := withIsstDev(func(*os.File) error { return nil }); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected failure to open the isst device, got %v", err)
+	}
+	if isstDev != nil {
+		t.Errorf("failed open of the isst device left a cached handle")
+	}
+}
+
+// BenchmarkIsstIoctl measures a single ioctl with the isst device handle
+// being reused ("cached") and with the device being re-opened for every
+// ioctl ("reopen"), the latter corresponding to no handle caching at all.
+// The benchmark needs to be run as root on an SST-enabled system.
+func BenchmarkIsstIoctl(b *testing.B) {
+	if !SstSupported() {
+		b.Skipf("SST not supported")
+	}
+
+	for _, tc := range []struct {
+		name  string
+		close bool
+	}{
+		{name: "cached"},
+		{name: "reopen", close: true},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			opens := countIsstDevOpens(b, os.Open)
+			for i := 0; i < b.N; i++ {
+				if _, err := getCPUMapping(0); err != nil {
+					b.Fatalf("getCPUMapping() failed: %v", err)
+				}
+				if tc.close {
+					CloseDevice()
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadUint64(opens))/float64(b.N), "opens/op")
+		})
+	}
+}
+
+// BenchmarkGetPackageInfo measures GetPackageInfo() with the isst device
+// handle being reused across commands. The number of device opens per
+// operation is reported as the "opens/op" metric. The benchmark needs to be
+// run as root on an SST-enabled system.
+func BenchmarkGetPackageInfo(b *testing.B) {
+	if !SstSupported() {
+		b.Skipf("SST not supported")
+	}
+
+	opens := countIsstDevOpens(b, os.Open)
+	for i := 0; i < b.N; i++ {
+		if _, err := GetPackageInfo(); err != nil {
+			b.Fatalf("GetPackageInfo() failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadUint64(opens))/float64(b.N), "opens/op")
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/intel/goresctrl/pkg/testutils"
+	"github.com/intel/goresctrl/pkg/utils"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tcs := []struct {
+		name        string
+		info        SstPackageInfo
+		expected    string
+		expectedErr string
+	}{
+		{
+			name: "empty",
+			info: SstPackageInfo{},
+			expected: `{"ppSupported":false,"ppLocked":false,"ppVersion":0,"ppCurrentLevel":0,"ppMaxLevel":0,` +
+				`"cpSupported":false,"cpEnabled":false,"cpPriority":"proportional",` +
+				`"bfSupported":false,"bfEnabled":false,"bfCores":[],"tfSupported":false,"tfEnabled":false,"clos":[` +
+				`{"id":0,"epp":0,"proportionalPriority":0,"minFreq":0,"maxFreq":0,"desiredFreq":0,"cpus":[]},` +
+				`{"id":1,"epp":0,"proportionalPriority":0,"minFreq":0,"maxFreq":0,"desiredFreq":0,"cpus":[]},` +
+				`{"id":2,"epp":0,"proportionalPriority":0,"minFreq":0,"maxFreq":0,"desiredFreq":0,"cpus":[]},` +
+				`{"id":3,"epp":0,"proportionalPriority":0,"minFreq":0,"maxFreq":0,"desiredFreq":0,"cpus":[]}]}`,
+		},
+		{
+			name: "full",
+			info: SstPackageInfo{
+				pkg:            &cpuPackageInfo{id: 1, cpus: []int{3, 1, 2}},
+				PPSupported:    true,
+				PPLocked:       true,
+				PPVersion:      2,
+				PPCurrentLevel: 3,
+				PPMaxLevel:     4,
+				CPSupported:    true,
+				CPEnabled:      true,
+				CPPriority:     Ordered,
+				BFSupported:    true,
+				BFCores:        utils.NewIDSet(3, 1),
+				TFSupported:    true,
+				TFEnabled:      true,
+				ClosInfo: [NumClos]SstClosInfo{
+					{EPP: 1, ProportionalPriority: 2, MinFreq: 10, MaxFreq: 20, DesiredFreq: 15},
+					{},
+					{},
+					{EPP: 15},
+				},
+				ClosCPUInfo: ClosCPUSet{
+					0: utils.NewIDSet(2),
+					3: utils.NewIDSet(3, 1),
+				},
+			},
+			expected: `{"package":1,"ppSupported":true,"ppLocked":true,"ppVersion":2,"ppCurrentLevel":3,"ppMaxLevel":4,` +
+				`"cpSupported":true,"cpEnabled":true,"cpPriority":"ordered",` +
+				`"bfSupported":true,"bfEnabled":false,"bfCores":[1,3],"tfSupported":true,"tfEnabled":true,"clos":[` +
+				`{"id":0,"epp":1,"proportionalPriority":2,"minFreq":10,"maxFreq":20,"desiredFreq":15,"cpus":[2]},` +
+				`{"id":1,"epp":0,"proportionalPriority":0,"minFreq":0,"maxFreq":0,"desiredFreq":0,"cpus":[]},` +
+				`{"id":2,"epp":0,"proportionalPriority":0,"minFreq":0,"maxFreq":0,"desiredFreq":0,"cpus":[]},` +
+				`{"id":3,"epp":15,"proportionalPriority":0,"minFreq":0,"maxFreq":0,"desiredFreq":0,"cpus":[1,3]}]}`,
+		},
+		{
+			name:        "unknown priority type",
+			info:        SstPackageInfo{CPPriority: 2},
+			expectedErr: "unknown SST CP priority type 2",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(&tc.info)
+			if tc.expectedErr != "" {
+				testutils.VerifyError(t, err, 1, []string{tc.expectedErr})
+				return
+			}
+			testutils.VerifyNoError(t, err)
+			testutils.VerifyStrings(t, tc.expected, string(data))
+
+			// Marshaling a value must give the same result
+			data, err = json.Marshal(tc.info)
+			testutils.VerifyNoError(t, err)
+			testutils.VerifyStrings(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	tcs := []struct {
+		name        string
+		info        SstPackageInfo
+		expected    SstFeatureSet
+		expectedStr string
+	}{
+		{
+			name:        "nothing supported",
+			info:        SstPackageInfo{},
+			expected:    SstFeatureSet{},
+			expectedStr: "PP:unsupported CP:unsupported BF:unsupported TF:unsupported",
+		},
+		{
+			name: "all enabled",
+			info: SstPackageInfo{
+				PPSupported: true,
+				CPSupported: true,
+				CPEnabled:   true,
+				BFSupported: true,
+				BFEnabled:   true,
+				TFSupported: true,
+				TFEnabled:   true,
+			},
+			expected: SstFeatureSet{
+				PP: SstFeature{Supported: true, Enabled: true},
+				CP: SstFeature{Supported: true, Enabled: true},
+				BF: SstFeature{Supported: true, Enabled: true},
+				TF: SstFeature{Supported: true, Enabled: true},
+			},
+			expectedStr: "PP:enabled CP:enabled BF:enabled TF:enabled",
+		},
+		{
+			name: "supported but disabled",
+			info: SstPackageInfo{
+				PPSupported: true,
+				CPSupported: true,
+				BFSupported: true,
+				TFSupported: true,
+			},
+			expected: SstFeatureSet{
+				PP: SstFeature{Supported: true, Enabled: true},
+				CP: SstFeature{Supported: true},
+				BF: SstFeature{Supported: true},
+				TF: SstFeature{Supported: true},
+			},
+			expectedStr: "PP:enabled CP:disabled BF:disabled TF:disabled",
+		},
+		{
+			name: "enabled but not supported",
+			info: SstPackageInfo{
+				CPEnabled: true,
+				BFEnabled: true,
+			},
+			expected: SstFeatureSet{
+				CP: SstFeature{Enabled: true},
+				BF: SstFeature{Enabled: true},
+			},
+			expectedStr: "PP:unsupported CP:unsupported BF:unsupported TF:unsupported",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			features := tc.info.Features()
+			testutils.VerifyDeepEqual(t, "features", tc.expected, features)
+			testutils.VerifyStrings(t, tc.expectedStr, features.String())
+		})
+	}
+}
+
+func TestEPPPolicy(t *testing.T) {
+	tcs := []struct {
+		policy      EPPPolicy
+		expected    int
+		expectedErr string
+	}{
+		{policy: EPPPerformance, expected: 0},
+		{policy: EPPBalancePerformance, expected: 8},
+		{policy: EPPBalancePower, expected: 12},
+		{policy: EPPPower, expected: 15},
+		{policy: "", expectedErr: `unknown EPP policy ""`},
+		{policy: "Performance", expectedErr: `unknown EPP policy "Performance"`},
+		{policy: "default", expectedErr: `unknown EPP policy "default"`},
+	}
+
+	for _, tc := range tcs {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			epp, err := tc.policy.EPP()
+			if tc.expectedErr != "" {
+				testutils.VerifyError(t, err, 1, []string{tc.expectedErr})
+				return
+			}
+			testutils.VerifyNoError(t, err)
+			testutils.VerifyDeepEqual(t, "EPP", tc.expected, epp)
+		})
+	}
+
+	// An unknown policy is rejected before touching the hardware
+	err := ClosSetupPolicy(&SstPackageInfo{}, 0, "foo", ClosFreqs{})
+	testutils.VerifyError(t, err, 1, []string{`unknown EPP policy "foo"`})
+}
+
+func TestValidateClosFreqs(t *testing.T) {
+	tcs := []struct {
+		name        string
+		minRatio    int
+		maxRatio    int
+		clos        SstClosInfo
+		expectedErr string
+	}{
+		{
+			name:     "within range",
+			minRatio: 8,
+			maxRatio: 35,
+			clos:     SstClosInfo{MinFreq: 8, MaxFreq: 35, DesiredFreq: 20},
+		},
+		{
+			name:     "no limits",
+			minRatio: 8,
+			maxRatio: 35,
+			clos:     SstClosInfo{MinFreq: 0, MaxFreq: 255, DesiredFreq: 0},
+		},
+		{
+			name:        "min freq too low",
+			minRatio:    8,
+			maxRatio:    35,
+			clos:        SstClosInfo{MinFreq: 7, MaxFreq: 255},
+			expectedErr: "min freq 7 out of range for package 1, allowed range is 8-35",
+		},
+		{
+			name:        "max freq too high",
+			minRatio:    8,
+			maxRatio:    35,
+			clos:        SstClosInfo{MinFreq: 8, MaxFreq: 36},
+			expectedErr: "max freq 36 out of range for package 1, allowed range is 8-35",
+		},
+		{
+			name:        "desired freq out of range",
+			minRatio:    8,
+			maxRatio:    35,
+			clos:        SstClosInfo{MaxFreq: 255, DesiredFreq: 254},
+			expectedErr: "desired freq 254 out of range for package 1, allowed range is 8-35",
+		},
+		{
+			name:     "unknown range",
+			minRatio: 8,
+			maxRatio: 0,
+			clos:     SstClosInfo{MinFreq: 100, MaxFreq: 200},
+		},
+		{
+			name:     "inverted range",
+			minRatio: 36,
+			maxRatio: 35,
+			clos:     SstClosInfo{MinFreq: 100, MaxFreq: 200},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateClosFreqs(1, tc.minRatio, tc.maxRatio, &tc.clos)
+			if tc.expectedErr != "" {
+				testutils.VerifyError(t, err, 1, []string{tc.expectedErr})
+				return
+			}
+			testutils.VerifyNoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	goresctrlpath "github.com/intel/goresctrl/pkg/path"
+	"github.com/intel/goresctrl/pkg/utils"
+)
+
+// PackageTelemetry contains a snapshot of the power and frequency status of
+// one CPU package. Fields that are not available on the system are left
+// zero.
+type PackageTelemetry struct {
+	Package   int
+	Timestamp time.Time
+
+	// TDP of the currently active SST-PP level, as reported by the punit
+	TDPRatio int
+	TDPPower int // Watts
+
+	// Current frequency of the package CPUs in kHz, as reported by cpufreq
+	MinCurFreq int
+	MaxCurFreq int
+	AvgCurFreq int
+
+	// Cumulative package energy consumption in microjoules as reported by
+	// RAPL, and the range (maximum value) of the wrapping energy counter
+	Energy      uint64
+	EnergyRange uint64
+}
+
+// PowerSince returns the average package power consumption in Watts between
+// an earlier snapshot and this one.
+func (t PackageTelemetry) PowerSince(prev PackageTelemetry) (float64, error) {
+	if t.Package != prev.Package {
+		return 0, fmt.Errorf("telemetry snapshots from different packages (%d and %d)", prev.Package, t.Package)
+	}
+	if t.EnergyRange == 0 {
+		return 0, fmt.Errorf("package energy counter not available")
+	}
+	elapsed := t.Timestamp.Sub(prev.Timestamp)
+	if elapsed <= 0 {
+		return 0, fmt.Errorf("invalid telemetry sampling interval %v", elapsed)
+	}
+
+	energy := t.Energy - prev.Energy
+	if t.Energy < prev.Energy {
+		// Counter wrapped around, EnergyRange being its maximum value
+		energy = t.EnergyRange - prev.Energy + t.Energy + 1
+	}
+
+	return float64(energy) / 1e6 / elapsed.Seconds(), nil
+}
+
+// GetPackageTelemetry returns the current power and frequency telemetry of a
+// CPU package. TDP information is read from the punit via the isst
+// interface, frequencies from cpufreq and energy consumption from the RAPL
+// powercap interface. Sources that are not available are skipped.
+func GetPackageTelemetry(pkg int) (PackageTelemetry, error) {
+	t := PackageTelemetry{Package: pkg}
+
+	packages, err := getOnlineCpuPackages()
+	if err != nil {
+		return t, fmt.Errorf("failed to determine cpu topology: %w", err)
+	}
+	p, ok := packages[pkg]
+	if !ok {
+		return t, fmt.Errorf("cpu package %d not present", pkg)
+	}
+
+	t.Timestamp = time.Now()
+
+	if err := t.readRapl(); err != nil {
+		sstlog.Debugf("package %d energy not available: %v", pkg, err)
+	}
+
+	if SstSupported() {
+		if err := t.readTDP(p); err != nil {
+			sstlog.Debugf("package %d TDP not available: %v", pkg, err)
+			t.TDPRatio, t.TDPPower = 0, 0
+		}
+	}
+
+	n, sum := 0, 0
+	for _, cpu := range p.cpus {
+		freq, err := utils.GetCPUFreqValue(cpu, "scaling_cur_freq")
+		if err != nil {
+			sstlog.Debugf("current frequency of cpu %d not available: %v", cpu, err)
+			continue
+		}
+		if n == 0 || freq < t.MinCurFreq {
+			t.MinCurFreq = freq
+		}
+		if freq > t.MaxCurFreq {
+			t.MaxCurFreq = freq
+		}
+		sum += freq
+		n++
+	}
+	if n > 0 {
+		t.AvgCurFreq = sum / n
+	}
+
+	return t, nil
+}
+
+// readTDP reads the TDP of the currently active SST-PP level from the punit.
+func (t *PackageTelemetry) readTDP(pkg *cpuPackageInfo) error {
+	cpu := pkg.cpus[0]
+
+	rsp, err := sendMboxCmd(cpu, CONFIG_TDP, CONFIG_TDP_GET_LEVELS_INFO, 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read SST PP info: %v", err)
+	}
+	level := getBits(rsp, 16, 23)
+
+	if rsp, err = sendMboxCmd(cpu, CONFIG_TDP, CONFIG_TDP_GET_TDP_INFO, 0, level); err != nil {
+		return fmt.Errorf("failed to read SST PP TDP info: %v", err)
+	}
+	t.TDPRatio = int(getBits(rsp, 0, 7))
+	t.TDPPower = int(getBits(rsp, 16, 30))
+
+	return nil
+}
+
+// readRapl reads the package energy counter from the RAPL powercap zone of
+// the package.
+func (t *PackageTelemetry) readRapl() error {
+	zones, err := filepath.Glob(goresctrlpath.Path("sys/class/powercap/intel-rapl:*"))
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("package-%d", t.Package)
+	for _, zone := range zones {
+		// Skip sub-zones (e.g. intel-rapl:0:0)
+		if strings.Count(filepath.Base(zone), ":") != 1 {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(zone, "name"))
+		if err != nil || strings.TrimSpace(string(data)) != name {
+			continue
+		}
+
+		if t.Energy, err = readRaplValue(zone, "energy_uj"); err != nil {
+			return err
+		}
+		if t.EnergyRange, err = readRaplValue(zone, "max_energy_range_uj"); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no RAPL powercap zone %q found", name)
+}
+
+func readRaplValue(zone, attr string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(zone, attr))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
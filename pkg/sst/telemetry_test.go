@@ -0,0 +1,133 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	goresctrlpath "github.com/intel/goresctrl/pkg/path"
+	"github.com/intel/goresctrl/pkg/testutils"
+)
+
+func TestPowerSince(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+
+	tcs := []struct {
+		name        string
+		prev        PackageTelemetry
+		cur         PackageTelemetry
+		expected    float64
+		expectedErr string
+	}{
+		{
+			name:     "no wrap",
+			prev:     PackageTelemetry{Timestamp: t0, Energy: 1000000, EnergyRange: 9999999},
+			cur:      PackageTelemetry{Timestamp: t0.Add(2 * time.Second), Energy: 5000000, EnergyRange: 9999999},
+			expected: 2,
+		},
+		{
+			name:     "wrap",
+			prev:     PackageTelemetry{Timestamp: t0, Energy: 9000000, EnergyRange: 9999999},
+			cur:      PackageTelemetry{Timestamp: t0.Add(time.Second), Energy: 1000000, EnergyRange: 9999999},
+			expected: 2,
+		},
+		{
+			name:     "wrap to zero",
+			prev:     PackageTelemetry{Timestamp: t0, Energy: 9999999, EnergyRange: 9999999},
+			cur:      PackageTelemetry{Timestamp: t0.Add(time.Second), Energy: 0, EnergyRange: 9999999},
+			expected: 0.000001,
+		},
+		{
+			name:        "different packages",
+			prev:        PackageTelemetry{Package: 0, Timestamp: t0, EnergyRange: 9999999},
+			cur:         PackageTelemetry{Package: 1, Timestamp: t0.Add(time.Second), EnergyRange: 9999999},
+			expectedErr: "different packages",
+		},
+		{
+			name:        "no energy counter",
+			prev:        PackageTelemetry{Timestamp: t0},
+			cur:         PackageTelemetry{Timestamp: t0.Add(time.Second)},
+			expectedErr: "energy counter not available",
+		},
+		{
+			name:        "invalid interval",
+			prev:        PackageTelemetry{Timestamp: t0, EnergyRange: 9999999},
+			cur:         PackageTelemetry{Timestamp: t0, EnergyRange: 9999999},
+			expectedErr: "invalid telemetry sampling interval",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			power, err := tc.cur.PowerSince(tc.prev)
+			if tc.expectedErr != "" {
+				testutils.VerifyError(t, err, 1, []string{tc.expectedErr})
+				return
+			}
+			testutils.VerifyNoError(t, err)
+			testutils.VerifyDeepEqual(t, "power", tc.expected, power)
+		})
+	}
+}
+
+func TestGetPackageTelemetry(t *testing.T) {
+	// Mock system where the isst device exists but TDP cannot be read from
+	// it, i.e. ioctls on it fail
+	root := t.TempDir()
+	for path, data := range map[string]string{
+		"sys/bus/cpu/devices/cpu0/topology/physical_package_id": "0",
+		"sys/bus/cpu/devices/cpu1/topology/physical_package_id": "0",
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_cur_freq":  "1000000",
+		"sys/devices/system/cpu/cpu1/cpufreq/scaling_cur_freq":  "2000000",
+		"sys/class/powercap/intel-rapl:0/name":                  "package-0",
+		"sys/class/powercap/intel-rapl:0/energy_uj":             "12345",
+		"sys/class/powercap/intel-rapl:0/max_energy_range_uj":   "99999",
+		"sys/class/powercap/intel-rapl:0:0/name":                "core",
+		"sys/class/powercap/intel-rapl:0:0/energy_uj":           "1",
+		"sys/class/powercap/intel-rapl:0:0/max_energy_range_uj": "2",
+		"dev/isst_interface":                                    "",
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create mock sysfs: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("failed to create mock sysfs: %v", err)
+		}
+	}
+	goresctrlpath.SetPrefix(root)
+	defer goresctrlpath.SetPrefix("/")
+	defer CloseDevice()
+
+	tel, err := GetPackageTelemetry(0)
+	testutils.VerifyNoError(t, err)
+	tel.Timestamp = time.Time{}
+	testutils.VerifyDeepEqual(t, "telemetry", PackageTelemetry{
+		MinCurFreq:  1000000,
+		MaxCurFreq:  2000000,
+		AvgCurFreq:  1500000,
+		Energy:      12345,
+		EnergyRange: 99999,
+	}, tel)
+
+	if _, err := GetPackageTelemetry(1); err == nil {
+		t.Errorf("GetPackageTelemetry() of non-existent package succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	goresctrlpath "github.com/intel/goresctrl/pkg/path"
+	"github.com/intel/goresctrl/pkg/utils"
+)
+
+// UncoreFreq contains the uncore frequency limits of one die of a CPU
+// package. All frequencies are in kHz.
+type UncoreFreq struct {
+	Die int
+	// Currently effective limits
+	MinFreq int
+	MaxFreq int
+	// Hardware limits
+	InitialMinFreq int
+	InitialMaxFreq int
+}
+
+// getUncoreDies returns the ids of the dies of a package that have uncore
+// frequency control available.
+func getUncoreDies(pkg int) ([]int, error) {
+	if !utils.UncoreFreqAvailable() {
+		return nil, fmt.Errorf("uncore frequency control not available")
+	}
+
+	pattern := goresctrlpath.Path(utils.SysfsUncoreBasepath, fmt.Sprintf("package_%02d_die_*", pkg))
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	dies := make([]int, 0, len(matches))
+	for _, m := range matches {
+		var p, d int
+		if _, err := fmt.Sscanf(filepath.Base(m), "package_%02d_die_%02d", &p, &d); err != nil {
+			return nil, fmt.Errorf("failed to parse uncore directory name %q: %w", m, err)
+		}
+		dies = append(dies, d)
+	}
+	if len(dies) == 0 {
+		return nil, fmt.Errorf("no uncore frequency control found for package %d", pkg)
+	}
+	sort.Ints(dies)
+
+	return dies, nil
+}
+
+// GetUncoreFreq returns the uncore frequency limits of all dies of a CPU
+// package.
+func GetUncoreFreq(pkg int) ([]UncoreFreq, error) {
+	dies, err := getUncoreDies(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]UncoreFreq, 0, len(dies))
+	for _, die := range dies {
+		u := UncoreFreq{Die: die}
+		for attr, val := range map[string]*int{
+			"min_freq_khz":         &u.MinFreq,
+			"max_freq_khz":         &u.MaxFreq,
+			"initial_min_freq_khz": &u.InitialMinFreq,
+			"initial_max_freq_khz": &u.InitialMaxFreq,
+		} {
+			if *val, err = utils.GetUncoreFreqValue(pkg, die, attr); err != nil {
+				return nil, fmt.Errorf("failed to read uncore %s of package %d die %d: %w", attr, pkg, die, err)
+			}
+		}
+		ret = append(ret, u)
+	}
+
+	return ret, nil
+}
+
+// SetUncoreFreq sets the uncore minimum and maximum frequency (in kHz) of all
+// dies of a CPU package. The values are clamped to the hardware limits of
+// each die, i.e. its InitialMinFreq and InitialMaxFreq.
+func SetUncoreFreq(pkg int, minFreq, maxFreq int) error {
+	if minFreq > maxFreq {
+		return fmt.Errorf("invalid uncore frequency limits, min (%d) greater than max (%d)", minFreq, maxFreq)
+	}
+
+	current, err := GetUncoreFreq(pkg)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range current {
+		dieMin := clampUncoreFreq(minFreq, u)
+		dieMax := clampUncoreFreq(maxFreq, u)
+		if dieMin != minFreq || dieMax != maxFreq {
+			sstlog.Debugf("uncore frequency limits of package %d die %d clamped to %d-%d kHz", pkg, u.Die, dieMin, dieMax)
+		}
+
+		// Write in an order that never results in min > max in between
+		setMin := func() error { return utils.SetUncoreMinFreq(pkg, u.Die, dieMin) }
+		setMax := func() error { return utils.SetUncoreMaxFreq(pkg, u.Die, dieMax) }
+		steps := []func() error{setMin, setMax}
+		if dieMin > u.MaxFreq {
+			steps = []func() error{setMax, setMin}
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return fmt.Errorf("failed to set uncore frequency of package %d die %d: %w", pkg, u.Die, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// clampUncoreFreq clamps a frequency to the hardware limits of a die.
+func clampUncoreFreq(freq int, u UncoreFreq) int {
+	if freq < u.InitialMinFreq {
+		return u.InitialMinFreq
+	}
+	if freq > u.InitialMaxFreq {
+		return u.InitialMaxFreq
+	}
+	return freq
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sst
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	goresctrlpath "github.com/intel/goresctrl/pkg/path"
+	"github.com/intel/goresctrl/pkg/testutils"
+	"github.com/intel/goresctrl/pkg/utils"
+)
+
+// mockUncoreSysfs creates a mock uncore frequency sysfs tree of package 0,
+// with one die per element of dies.
+func mockUncoreSysfs(t *testing.T, dies []UncoreFreq) string {
+	root := t.TempDir()
+	for _, u := range dies {
+		dir := filepath.Join(root, utils.SysfsUncoreBasepath, fmt.Sprintf("package_00_die_%02d", u.Die))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create mock sysfs: %v", err)
+		}
+		for attr, val := range map[string]int{
+			"min_freq_khz":         u.MinFreq,
+			"max_freq_khz":         u.MaxFreq,
+			"initial_min_freq_khz": u.InitialMinFreq,
+			"initial_max_freq_khz": u.InitialMaxFreq,
+		} {
+			if err := os.WriteFile(filepath.Join(dir, attr), []byte(strconv.Itoa(val)), 0644); err != nil {
+				t.Fatalf("failed to create mock sysfs: %v", err)
+			}
+		}
+	}
+	return root
+}
+
+func TestSetUncoreFreq(t *testing.T) {
+	dies := []UncoreFreq{
+		{Die: 0, MinFreq: 800000, MaxFreq: 2400000, InitialMinFreq: 800000, InitialMaxFreq: 2400000},
+		{Die: 1, MinFreq: 1000000, MaxFreq: 2000000, InitialMinFreq: 1000000, InitialMaxFreq: 2000000},
+	}
+
+	tcs := []struct {
+		name        string
+		minFreq     int
+		maxFreq     int
+		expected    [][2]int
+		expectedErr string
+	}{
+		{
+			name:     "within limits",
+			minFreq:  1200000,
+			maxFreq:  1800000,
+			expected: [][2]int{{1200000, 1800000}, {1200000, 1800000}},
+		},
+		{
+			name:     "clamped per die",
+			minFreq:  900000,
+			maxFreq:  2200000,
+			expected: [][2]int{{900000, 2200000}, {1000000, 2000000}},
+		},
+		{
+			name:     "out of range",
+			minFreq:  100000,
+			maxFreq:  9000000,
+			expected: [][2]int{{800000, 2400000}, {1000000, 2000000}},
+		},
+		{
+			name:     "above max",
+			minFreq:  3000000,
+			maxFreq:  4000000,
+			expected: [][2]int{{2400000, 2400000}, {2000000, 2000000}},
+		},
+		{
+			name:        "min greater than max",
+			minFreq:     2000000,
+			maxFreq:     1000000,
+			expectedErr: "min (2000000) greater than max (1000000)",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			goresctrlpath.SetPrefix(mockUncoreSysfs(t, dies))
+			defer goresctrlpath.SetPrefix("/")
+
+			err := SetUncoreFreq(0, tc.minFreq, tc.maxFreq)
+			if tc.expectedErr != "" {
+				testutils.VerifyError(t, err, 1, []string{tc.expectedErr})
+				return
+			}
+			testutils.VerifyNoError(t, err)
+
+			freqs, err := GetUncoreFreq(0)
+			testutils.VerifyNoError(t, err)
+			for i, u := range freqs {
+				testutils.VerifyDeepEqual(t, fmt.Sprintf("die %d limits", u.Die), tc.expected[i], [2]int{u.MinFreq, u.MaxFreq})
+			}
+		})
+	}
+}
@@ -116,6 +116,12 @@ func SetUncoreMaxFreq(pkg, die ID, freqKhz int) error {
 	return setUncoreFreqValue(pkg, die, "max_freq_khz", freqKhz)
 }
 
+// GetUncoreFreqValue reads an uncore frequency attribute (e.g. min_freq_khz)
+// of a CPU die.
+func GetUncoreFreqValue(pkg, die ID, attribute string) (int, error) {
+	return getUncoreFreqValue(pkg, die, attribute)
+}
+
 func uncoreFreqPath(pkg, die ID, attribute string) string {
 	return goresctrlpath.Path(SysfsUncoreBasepath, fmt.Sprintf("package_%02d_die_%02d", pkg, die), attribute)
 }